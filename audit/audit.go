@@ -0,0 +1,240 @@
+// Package audit keeps the append-only audit trail of the mutating requests handled by the
+// car-pooling services: who made the change, from where, when, and the entity before and after.
+// Records are stored one JSON object per line so the trail can be exported as is.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a single audited request
+type Record struct {
+	Time      time.Time       `json:"time"`
+	Actor     string          `json:"actor"`
+	SourceIP  string          `json:"source_ip"`
+	RequestID string          `json:"request_id,omitempty"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Status    int             `json:"status"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
+// Log is an append-only audit trail, optionally backed by a file
+type Log struct {
+	mu      sync.Mutex
+	file    *os.File
+	records []Record
+}
+
+// Open reads the records already stored in the file at path and opens it for appending,
+// the file is created if it does not exist. An empty path gives a log kept in memory only.
+func Open(path string) (*Log, error) {
+	l := &Log{}
+	if path == "" {
+		return l, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+		}
+		l.records = append(l.records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	l.file = file
+	return l, nil
+}
+
+// Append adds a record to the trail
+func (l *Log) Append(record Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	l.records = append(l.records, record)
+	return nil
+}
+
+// Query returns the records for the entity type and ID, oldest first. Empty arguments match everything.
+func (l *Log) Query(entity, entityID string) []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := []Record{}
+	for _, record := range l.records {
+		if (entity == "" || record.Entity == entity) && (entityID == "" || record.EntityID == entityID) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Close flushes and closes the file backing the log, later records are kept in memory only
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := errors.Join(l.file.Sync(), l.file.Close())
+	l.file = nil
+	return err
+}
+
+// Sort orders records from different logs by time
+func Sort(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+}
+
+// WriteJSONL writes the records one JSON object per line
+func WriteJSONL(w io.Writer, records []Record) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteResponse writes the records as a JSON array, or as JSON lines for an export when the
+// request asks for format=jsonl
+func WriteResponse(w http.ResponseWriter, r *http.Request, records []Record) {
+	if r.URL.Query().Get("format") == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		WriteJSONL(w, records)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+// Handler serves GET requests to query the trail by the entity and id query parameters
+func (l *Log) Handler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	WriteResponse(w, r, l.Query(query.Get("entity"), query.Get("id")))
+}
+
+// Target identifies the entity a request changes. Snapshot returns its current value, or nil
+// if it does not exist, and is called before and after the request is handled.
+type Target struct {
+	Entity   string
+	EntityID string
+	Snapshot func() interface{}
+}
+
+// Middleware records every POST, PUT, PATCH and DELETE request in the trail. target returns the
+// entity changed by the request, requests for which it returns false are not audited.
+func (l *Log) Middleware(target func(r *http.Request) (Target, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			t, ok := target(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			record := Record{
+				Time:      time.Now().UTC(),
+				Actor:     r.Header.Get("X-Client-ID"),
+				SourceIP:  sourceIP(r),
+				RequestID: r.Header.Get("X-Request-ID"),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Entity:    t.Entity,
+				EntityID:  t.EntityID,
+				Before:    snapshot(t),
+			}
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			record.Status = recorder.status
+			record.After = snapshot(t)
+			if err := l.Append(record); err != nil {
+				slog.Error("recording in the audit log", "method", r.Method, "path", r.URL.Path, "error", err)
+			}
+		})
+	}
+}
+
+func snapshot(t Target) json.RawMessage {
+	value, err := json.Marshal(t.Snapshot())
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
+
+// sourceIP returns the address of the client. The gateway passes it on as the last entry of
+// X-Forwarded-For, earlier entries come from the client and are not trusted.
+func sourceIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		addresses := strings.Split(forwarded, ",")
+		return strings.TrimSpace(addresses[len(addresses)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
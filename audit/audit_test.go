@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestHandler returns a handler audited by l that sets the seats of the trip to the request
+// body. Requests to /trips/T1 change the trip, other paths are not audited.
+func newTestHandler(l *Log) http.Handler {
+	seats := map[string]string{"T1": "3"}
+	target := func(r *http.Request) (Target, bool) {
+		if r.URL.Path != "/trips/T1" {
+			return Target{}, false
+		}
+		return Target{Entity: "trip", EntityID: "T1", Snapshot: func() interface{} {
+			return map[string]string{"seats": seats["T1"]}
+		}}, true
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		seats["T1"] = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return l.Middleware(target)(handler)
+}
+
+func TestMutationsAreRecorded(t *testing.T) {
+	l, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newTestHandler(l)
+
+	r := httptest.NewRequest("PUT", "/trips/T1", strings.NewReader("4"))
+	r.RemoteAddr = "10.0.0.5:41000"
+	r.Header.Set("X-Client-ID", "console")
+	r.Header.Set("X-Request-ID", "req-1")
+	r.Header.Set("X-Forwarded-For", "203.0.113.9")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	// Reads and requests for no entity are not recorded
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/trips/T1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/trips", strings.NewReader("5")))
+
+	records := l.Query("", "")
+	if len(records) != 1 {
+		t.Fatalf("recorded %d requests, want 1: %+v", len(records), records)
+	}
+	record := records[0]
+	if record.Actor != "console" || record.SourceIP != "203.0.113.9" || record.RequestID != "req-1" {
+		t.Errorf("recorded actor %q from %q with request ID %q, want console from 203.0.113.9 with req-1",
+			record.Actor, record.SourceIP, record.RequestID)
+	}
+	if record.Method != "PUT" || record.Path != "/trips/T1" || record.Status != http.StatusAccepted ||
+		record.Entity != "trip" || record.EntityID != "T1" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if string(record.Before) != `{"seats":"3"}` || string(record.After) != `{"seats":"4"}` {
+		t.Errorf("recorded the trip changing from %s to %s, want 3 seats to 4", record.Before, record.After)
+	}
+	if record.Time.IsZero() {
+		t.Error("the record has no time")
+	}
+}
+
+func TestRejectedMutationsAreRecorded(t *testing.T) {
+	l, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTestHandler(l).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/trips/T1", nil))
+
+	// The failed attempt is kept too, with the entity as it was
+	records := l.Query("trip", "T1")
+	if len(records) != 1 || records[0].Status != http.StatusBadRequest || string(records[0].Before) != string(records[0].After) {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarded string
+		want      string
+	}{
+		{"direct", "", "10.0.0.5"},
+		{"through the gateway", "203.0.113.9", "203.0.113.9"},
+		{"address sent by the client", "198.51.100.1, 203.0.113.9", "203.0.113.9"},
+		{"several proxies", "198.51.100.1,198.51.100.2 , 203.0.113.9 ", "203.0.113.9"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/trips/T1", nil)
+		r.RemoteAddr = "10.0.0.5:41000"
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if got := sourceIP(r); got != test.want {
+			t.Errorf("%s: the source IP is %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReopeningTheLogKeepsItsRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("PUT", "/trips/T1", strings.NewReader("4"))
+	r.Header.Set("X-Client-ID", "console")
+	newTestHandler(l).ServeHTTP(httptest.NewRecorder(), r)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if records := l.Query("trip", "T1"); len(records) != 1 || records[0].Actor != "console" || string(records[0].After) != `{"seats":"4"}` {
+		t.Errorf("the reopened log has %+v", records)
+	}
+}
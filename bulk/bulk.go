@@ -0,0 +1,252 @@
+// Package bulk exports every user or trip of a service and imports them back, so test data can be
+// loaded again after a restart. Records are exchanged as a JSON array, or as CSV with a header row
+// naming the columns. An import checks every row before it stores them together, and answers
+// with the result of each row.
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// What an import does with a record whose ID is already taken, set with the on_conflict parameter
+const (
+	Fail      = "fail"      // the row fails, so nothing is imported
+	Skip      = "skip"      // the existing record is kept
+	Overwrite = "overwrite" // the imported record replaces it
+)
+
+// maxImportSize limits the body of an import
+const maxImportSize = 10 << 20
+
+var (
+	errConflict = errors.New("already exists")
+	errChanged  = errors.New("was changed during the import")
+)
+
+// unavailableError is a service the checks depend on that could not be reached
+type unavailableError struct{ err error }
+
+func (e unavailableError) Error() string { return e.err.Error() }
+func (e unavailableError) Unwrap() error { return e.err }
+
+// Unavailable marks an error returned by Check as a service that could not be reached. Rather
+// than failing the row, it fails the whole import with 502 Bad Gateway.
+func Unavailable(err error) error {
+	return unavailableError{err}
+}
+
+// Records exports and imports one kind of record, such as the users of the User service
+type Records[T any] struct {
+	Name    string   // plural, names the export's file, e.g. "users"
+	Entity  string   // singular, for the row errors, e.g. "user"
+	Columns []string // the CSV columns
+
+	All     func() []T // every record in ID order
+	Get     func(id string) (T, bool)
+	ID      func(record T) string
+	CSV     func(records ...T) [][]string // the records under a CSV header
+	FromCSV func(row model.CSVRow) (T, error)
+
+	// Check checks and normalises a record before it is stored. existing is the record it
+	// overwrites, or nil for a new one.
+	Check func(record, existing *T) error
+	// PutAll stores the records as a single change. It first calls check with the records as they
+	// are under the store's lock, and stores nothing if check returns an error.
+	PutAll func(records []T, check func(stored func(id string) (T, bool)) error) error
+}
+
+// Export handles GET requests for every record as a JSON array, or as CSV with format=csv
+func (rs Records[T]) Export(w http.ResponseWriter, r *http.Request) {
+	records := rs.All()
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", rs.Name+".json"))
+		json.NewEncoder(w).Encode(records)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", rs.Name+".csv"))
+		csv.NewWriter(w).WriteAll(rs.CSV(records...))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error - Invalid format %q, want json or csv", format)
+	}
+}
+
+// Import handles POST requests with records to store, as exported. Every row is checked first and
+// nothing is stored if any row fails or dry_run is true. The response reports each row: 202 when
+// the records were stored, 200 for a dry run, 409 when the only failures are IDs already taken
+// with on_conflict=fail, and 400 for any other failure. The checks call other services, so they
+// run without the store's lock; if another request changes one of the records before they are
+// stored, nothing is stored and the import fails with 409.
+func (rs Records[T]) Import(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	report := model.ImportReport{OnConflict: query.Get("on_conflict"), Rows: []model.ImportRow{}}
+	if report.OnConflict == "" {
+		report.OnConflict = Fail
+	}
+	if report.OnConflict != Fail && report.OnConflict != Skip && report.OnConflict != Overwrite {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error - Invalid on_conflict %q, want fail, skip or overwrite", report.OnConflict)
+		return
+	}
+	if dryRun := query.Get("dry_run"); dryRun != "" {
+		var err error
+		if report.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Error - Invalid dry_run %q, want true or false", dryRun)
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	records, readErrs, err := rs.read(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Invalid request payload: %v", err)
+		return
+	}
+
+	var changes []T
+	checked := map[string]*T{}
+	conflicts := 0
+	for i := range records {
+		row := model.ImportRow{Row: i + 1, ID: rs.ID(records[i])}
+		err := readErrs[i]
+		if err == nil {
+			row.Result, err = rs.check(&records[i], report.OnConflict, checked)
+		}
+
+		var unavailable unavailableError
+		switch {
+		case errors.As(err, &unavailable):
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, model.ErrorMessage(err))
+			return
+		case err != nil:
+			row.Result, row.Error = "failed", err.Error()
+			report.Failed++
+			if errors.Is(err, errConflict) {
+				conflicts++
+			}
+		case row.Result == "created":
+			report.Created++
+			changes = append(changes, records[i])
+		case row.Result == "updated":
+			report.Updated++
+			changes = append(changes, records[i])
+		default:
+			report.Skipped++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	status := http.StatusAccepted
+	switch {
+	case report.Failed > 0 && report.Failed == conflicts:
+		status = http.StatusConflict
+	case report.Failed > 0:
+		status = http.StatusBadRequest
+	case report.DryRun:
+		status = http.StatusOK
+	default:
+		err := rs.PutAll(changes, func(stored func(id string) (T, bool)) error {
+			return rs.unchanged(changes, checked, stored)
+		})
+		if errors.Is(err, errChanged) {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, "Error - The %v, nothing was imported", err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "Error - Could not record the change: %v", err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// read decodes the records of an import from JSON, or CSV when the request says it is text/csv.
+// A record that cannot be decoded is returned with its error, so it is reported with the others.
+func (rs Records[T]) read(r *http.Request) ([]T, []error, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		rows, err := model.ReadCSV(r.Body, rs.Columns)
+		if err != nil {
+			return nil, nil, err
+		}
+		records, errs := make([]T, len(rows)), make([]error, len(rows))
+		for i, row := range rows {
+			records[i], errs[i] = rs.FromCSV(row)
+		}
+		return records, errs, nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	records, errs := make([]T, len(raw)), make([]error, len(raw))
+	for i, data := range raw {
+		errs[i] = json.Unmarshal(data, &records[i])
+	}
+	return records, errs, nil
+}
+
+// check decides what to do with a record and checks it, returning created, updated or skipped.
+// The record stored under its ID is added to checked, or nil if there is none.
+func (rs Records[T]) check(record *T, onConflict string, checked map[string]*T) (string, error) {
+	id := rs.ID(*record)
+	_, seen := checked[id]
+	switch {
+	case id == "":
+		return "", errors.New("the id is required")
+	case strings.ContainsAny(id, "/?# "):
+		return "", fmt.Errorf("invalid id %q", id)
+	case seen:
+		return "", fmt.Errorf("the id %s appears more than once", id)
+	}
+
+	existing, exists := rs.Get(id)
+	checked[id] = nil
+	if exists {
+		checked[id] = &existing
+	}
+	switch {
+	case !exists:
+		return "created", rs.Check(record, nil)
+	case onConflict == Skip:
+		return "skipped", nil
+	case onConflict == Overwrite:
+		return "updated", rs.Check(record, &existing)
+	}
+	return "", fmt.Errorf("%s %s %w", rs.Entity, id, errConflict)
+}
+
+// unchanged returns errChanged if a record to be created or overwritten is no longer stored as it
+// was when it was checked
+func (rs Records[T]) unchanged(changes []T, checked map[string]*T, stored func(id string) (T, bool)) error {
+	for _, record := range changes {
+		id := rs.ID(record)
+		current, exists := stored(id)
+		if before := checked[id]; exists != (before != nil) || exists && !reflect.DeepEqual(current, *before) {
+			return fmt.Errorf("%s %s %w", rs.Entity, id, errChanged)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Package certs sets up HTTPS for the car-pooling programs. The servers present the configured
+// certificate, or a self-signed development certificate that is generated on first use and shared
+// by every program through a file. With mutual TLS the services also verify the certificate of
+// every caller against the CA, and the gateway and the services present theirs when they call them.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+)
+
+// devCertValidity is how long a generated development certificate is valid for
+const devCertValidity = 365 * 24 * time.Hour
+
+// ServerTLS returns the TLS configuration a server listens with, or nil if HTTPS is off.
+// With requireClientCerts the server only accepts clients presenting a certificate signed by the CA.
+func ServerTLS(c config.TLSConfig, requireClientCerts bool) (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+
+	certificate, err := certificate(c)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+	}
+
+	if requireClientCerts {
+		clientCAs, err := certPool(c)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// ClientTLS returns the TLS configuration for calls to the other programs, it trusts the CA as well as
+// the system's certificate authorities. With presentCert and mutual TLS on, the program's own certificate
+// is presented to the server. It returns nil if the defaults will do.
+func ClientTLS(c config.TLSConfig, presentCert bool) (*tls.Config, error) {
+	rootCAs, err := certPool(c)
+	if err != nil {
+		return nil, err
+	}
+	presentCert = presentCert && c.Mutual
+	if rootCAs == nil && !presentCert {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    rootCAs,
+	}
+	if presentCert {
+		certificate, err := certificate(c)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// Transport returns an HTTP transport that uses tlsConfig, or the default transport if it is nil
+func Transport(tlsConfig *tls.Config) http.RoundTripper {
+	if tlsConfig == nil {
+		return http.DefaultTransport
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+// certificate returns the configured certificate, or the development certificate
+func certificate(c config.TLSConfig) (tls.Certificate, error) {
+	if c.CertFile != "" {
+		return tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	}
+	return DevCertificate(c.DevCertFile)
+}
+
+// certPool returns the CA file's certificates together with the system's, or nil if no CA is configured.
+// The development certificate is its own CA, so it is trusted when self_signed is on.
+func certPool(c config.TLSConfig) (*x509.CertPool, error) {
+	path := c.CAFile
+	if path == "" && c.SelfSigned {
+		path = c.DevCertFile
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path == c.DevCertFile {
+		return nil, fmt.Errorf("the development certificate %s does not exist yet, start the gateway or a service first", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s has no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// DevCertificate returns the self-signed development certificate kept at path, together with its
+// key. A new one is generated if the file does not exist or the certificate has expired. The
+// certificate is valid for localhost and this host, and for both servers and clients.
+func DevCertificate(path string) (tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		certificate, err := tls.X509KeyPair(data, data)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("%s: %v", path, err)
+		}
+		leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("%s: %v", path, err)
+		}
+		if time.Now().Before(leaf.NotAfter) {
+			return certificate, nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return tls.Certificate{}, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return tls.Certificate{}, err
+	}
+
+	data, err = generateDevCertificate()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := writeDevCertificate(path, data); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	// Another program may have written its certificate first, in which case that one is used
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(data, data)
+}
+
+// generateDevCertificate returns a new self-signed certificate and its key, PEM encoded in one file
+func generateDevCertificate() ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		hosts = append(hosts, hostname)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Car-pooling development"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(devCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              hosts,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return append(data, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})...), nil
+}
+
+// writeDevCertificate creates the file at path in one step, so programs starting at the same time
+// never read a half written certificate, and leaves it alone if another program created it first.
+// Only the owner can read it as it holds the key.
+func writeDevCertificate(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Link(file.Name(), path); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return nil
+}
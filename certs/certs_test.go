@@ -0,0 +1,137 @@
+package certs
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+)
+
+// devTLS returns the settings of a program using a development certificate kept in a temporary directory
+func devTLS(t *testing.T, mutual bool) config.TLSConfig {
+	return config.TLSConfig{SelfSigned: true, DevCertFile: filepath.Join(t.TempDir(), "dev-cert.pem"), Mutual: mutual}
+}
+
+// startServer starts an HTTPS server listening with the TLS configuration of c
+func startServer(t *testing.T, c config.TLSConfig, requireClientCerts bool) *httptest.Server {
+	t.Helper()
+	tlsConfig, err := ServerTLS(c, requireClientCerts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = tlsConfig
+	// The handshakes the tests expect to fail are not logged
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get calls the server with the client TLS configuration of c
+func get(t *testing.T, server *httptest.Server, c config.TLSConfig, presentCert bool) error {
+	t.Helper()
+	tlsConfig, err := ClientTLS(c, presentCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: Transport(tlsConfig)}
+	response, err := client.Get(server.URL)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+func TestTheDevCertificateIsGeneratedOnceAndReused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dev-cert.pem")
+
+	first, err := DevCertificate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file holds the key, so only the owner can read it
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("the development certificate has mode %v, want -rw-------", mode)
+	}
+
+	// The next start, or another program, uses the certificate already in the file
+	second, err := DevCertificate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Certificate[0], second.Certificate[0]) {
+		t.Error("a new development certificate was generated although the file has a valid one")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("the directory has %d files, want only the certificate", len(entries))
+	}
+}
+
+func TestAnInvalidDevCertificateIsAnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dev-cert.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DevCertificate(path); err == nil {
+		t.Error("DevCertificate accepted a file without a certificate")
+	}
+}
+
+func TestClientsTrustTheDevCertificate(t *testing.T) {
+	c := devTLS(t, false)
+	server := startServer(t, c, false)
+
+	if err := get(t, server, c, false); err != nil {
+		t.Errorf("a client trusting the development certificate could not call the server: %v", err)
+	}
+	// Without the development certificate as its CA, the server is not trusted
+	if err := get(t, server, config.TLSConfig{}, false); err == nil {
+		t.Error("a client with only the system's certificate authorities trusted the development certificate")
+	}
+}
+
+func TestMutualTLSRejectsClientsWithoutACertificate(t *testing.T) {
+	c := devTLS(t, true)
+	server := startServer(t, c, true)
+
+	if err := get(t, server, c, false); err == nil {
+		t.Error("the server accepted a client without a certificate")
+	}
+	if err := get(t, server, c, true); err != nil {
+		t.Errorf("the server rejected a client presenting the development certificate: %v", err)
+	}
+
+	// A certificate from another CA is rejected as well
+	other := devTLS(t, true)
+	other.CAFile = c.DevCertFile
+	if _, err := DevCertificate(other.DevCertFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(t, server, other, true); err == nil {
+		t.Error("the server accepted a certificate it did not issue")
+	}
+}
+
+func TestClientTLSNeedsTheDevCertificate(t *testing.T) {
+	// The console can only trust the development certificate once a server has generated it
+	if _, err := ClientTLS(devTLS(t, false), false); err == nil {
+		t.Error("ClientTLS trusted a development certificate that does not exist")
+	}
+	if tlsConfig, err := ClientTLS(config.TLSConfig{}, true); tlsConfig != nil || err != nil {
+		t.Errorf("ClientTLS without HTTPS returned %v, %v, want the defaults", tlsConfig, err)
+	}
+}
@@ -0,0 +1,179 @@
+// Package client is a Go client for the car-pooling API. It sends every request through the gateway
+// with the client's API key. The methods for the API's operations are generated from the OpenAPI
+// document into operations.go, they take a context and return the model types. A response with an
+// error status is returned as an *Error, which matches ErrNotFound, ErrConflict and so on with errors.Is.
+package client
+
+//go:generate go run gen.go
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/health"
+)
+
+// Doer sends HTTP requests, it is satisfied by *http.Client and by the resilient package's Client
+type Doer interface {
+	Do(request *http.Request) (*http.Response, error)
+}
+
+// Client calls the car-pooling API through the gateway
+type Client struct {
+	gatewayURL string
+	apiKey     string
+	doer       Doer
+}
+
+// New returns a client for the gateway at gatewayURL that authenticates with apiKey.
+// Requests are sent with doer, or with http.DefaultClient if it is nil.
+func New(gatewayURL, apiKey string, doer Doer) *Client {
+	if doer == nil {
+		doer = http.DefaultClient
+	}
+	return &Client{gatewayURL: strings.TrimSuffix(gatewayURL, "/"), apiKey: apiKey, doer: doer}
+}
+
+// GatewayURL returns the address of the gateway the client calls
+func (c *Client) GatewayURL() string {
+	return c.gatewayURL
+}
+
+// Errors an *Error matches with errors.Is, by the status of the response
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrUnavailable     = errors.New("service unavailable")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:         ErrBadRequest,
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrForbidden,
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusConflict:           ErrConflict,
+	http.StatusTooManyRequests:    ErrTooManyRequests,
+	http.StatusBadGateway:         ErrUnavailable,
+	http.StatusServiceUnavailable: ErrUnavailable,
+}
+
+// Error is a response with an error status. Message is the explanation the server gave.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return e.Message
+}
+
+// Is reports whether target is the error for the response's status, e.g. ErrNotFound for a 404
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// Ready asks the gateway whether it and the services behind it are ready. The status is returned
+// with an error matching ErrUnavailable if they are not, its checks say what is wrong.
+func (c *Client) Ready(ctx context.Context) (health.Status, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", c.gatewayURL+"/readyz", nil)
+	if err != nil {
+		return health.Status{}, err
+	}
+	response, err := c.doer.Do(request)
+	if err != nil {
+		return health.Status{}, err
+	}
+	defer response.Body.Close()
+
+	var status health.Status
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		return health.Status{}, fmt.Errorf("decoding the readiness of %s: %w", c.gatewayURL, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return status, &Error{StatusCode: response.StatusCode, Message: status.Status}
+	}
+	return status, nil
+}
+
+// do sends a request to the API and decodes the response into result. A *string result receives
+// the text of the response, anything else is decoded from JSON, or from JSON lines into a slice.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, result interface{}) error {
+	var requestBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		requestBody = bytes.NewReader(data)
+	}
+
+	requestURL := c.gatewayURL + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, requestBody)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	request.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	response, err := c.doer.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= 300 {
+		return &Error{StatusCode: response.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+
+	if text, ok := result.(*string); ok {
+		*text = string(data)
+		return nil
+	}
+	if strings.HasPrefix(response.Header.Get("Content-Type"), "application/x-ndjson") {
+		data = jsonLinesToArray(data)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("decoding the response to %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// jsonLinesToArray turns JSON lines into a JSON array of the lines
+func jsonLinesToArray(data []byte) []byte {
+	var lines [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, append([]byte(nil), line...))
+		}
+	}
+	return append(append([]byte("["), bytes.Join(lines, []byte(","))...), ']')
+}
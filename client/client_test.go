@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi/openapitest"
+)
+
+const (
+	userJSON   = `{"id":"U1","first_name":"Jane","last_name":"Tan","mobile_number":"91234567","email":"jane@example.com","is_car_owner":false,"created_at":"2024-01-02T03:04:05Z"}`
+	tripJSON   = `{"id":"T1","car_owner_id":"O1","pickup_location":"Ang Mo Kio","start_time":"2024-01-02T03:04:05Z","destination":"Ngee Ann Polytechnic","available_seats":2,"enrolled_passengers":["U1"],"total_seats":3,"started":false,"cancelled":false}`
+	seriesJSON = `{"id":"S1","car_owner_id":"O1","pickup_location":"Ang Mo Kio","destination":"Ngee Ann Polytechnic","total_seats":3,"time_of_day":"08:15","days":["WEEKDAYS"]}`
+	policyJSON = `{"publish_lead_time":"30m0s","start_window_before":"15m0s","start_window_after":"1h0m0s","cancel_cutoff":"30m0s","enrollment_cutoff":"30m0s"}`
+	reportJSON = `{"dry_run":true,"on_conflict":"skip","created":0,"updated":0,"skipped":1,"failed":0,"rows":[{"row":1,"id":"U1","result":"skipped"}]}`
+	recordJSON = `{"time":"2024-01-02T03:04:05Z","actor":"console","source_ip":"127.0.0.1","method":"POST","path":"/api/v1/users/U1","status":202,"entity":"user","entity_id":"U1","before":null,"after":{"id":"U1"}}`
+)
+
+// stubResponses are the gateway's answers by "METHOD /path", any other request is not found
+var stubResponses = map[string]struct {
+	status      int
+	contentType string
+	body        string
+}{
+	"GET /readyz":                            {503, "application/json", `{"status":"not ready","version":"dev","uptime":"1s","checks":{"trip_service":"connection refused"}}`},
+	"GET /api/v1/users":                      {200, "application/json", `{"U1":` + userJSON + `}`},
+	"GET /api/v1/users/U1":                   {200, "application/json", userJSON},
+	"POST /api/v1/users/U1":                  {202, "text/plain", "User POST U1 successfully"},
+	"PUT /api/v1/users/U1":                   {202, "text/plain", "User PUT U1 successfully"},
+	"DELETE /api/v1/users/U1":                {200, "text/plain", "User U1 deleted"},
+	"POST /api/v1/users/U1/become-owner":     {202, "text/plain", "User U1 is now a car owner"},
+	"POST /api/v1/users/U1/become-passenger": {202, "text/plain", "User U1 is now a passenger"},
+	"GET /api/v1/users/U1/notifications":     {200, "application/json", `[{"trip_id":"T1","message":"Trip T1 was cancelled","created_at":"2024-01-02T03:04:05Z"}]`},
+	"GET /api/v1/trips":                      {200, "application/json", `{"T1":` + tripJSON + `}`},
+	"GET /api/v1/trips/T1":                   {200, "application/json", tripJSON},
+	"POST /api/v1/trips/T1":                  {202, "text/plain", "Trip POST T1 successfully"},
+	"PUT /api/v1/trips/T1":                   {202, "text/plain", "Trip PUT T1 successfully"},
+	"DELETE /api/v1/trips/T1":                {200, "text/plain", "Trip T1 deleted"},
+	"PUT /api/v1/trips/T1/enroll":            {202, "text/plain", "User U1 enrolled in trip T1 successfully"},
+	"PUT /api/v1/trips/T1/withdraw":          {202, "text/plain", "User U1 withdrew from trip T1 successfully"},
+	"PUT /api/v1/trips/T1/start":             {400, "text/plain", "Error - Trip cannot start without any enrolled passengers"},
+	"GET /api/v1/series":                     {200, "application/json", `{"S1":` + seriesJSON + `}`},
+	"GET /api/v1/series/S1":                  {200, "application/json", seriesJSON},
+	"POST /api/v1/series/S1":                 {409, "text/plain", "Error - Series already exists"},
+	"PUT /api/v1/series/S1":                  {202, "text/plain", "Series PUT S1 successfully"},
+	"DELETE /api/v1/series/S1":               {200, "text/plain", "Series S1 deleted"},
+	"POST /api/v1/series/S1/skip":            {202, "text/plain", "Occurrence 2024-01-03 of series S1 skipped"},
+	"GET /api/v1/policy":                     {200, "application/json", policyJSON},
+	"GET /api/v1/admin/users/export":         {200, "application/json", `[` + userJSON + `]`},
+	"POST /api/v1/admin/users/import":        {200, "application/json", reportJSON},
+	"GET /api/v1/admin/trips/export":         {200, "application/json", `[` + tripJSON + `]`},
+	"POST /api/v1/admin/trips/import":        {409, "application/json", `{"dry_run":false,"on_conflict":"fail","created":0,"updated":0,"skipped":0,"failed":1,"rows":[{"row":1,"id":"T1","result":"failed","error":"trip T1 already exists"}]}`},
+}
+
+// newTestClient returns a client whose requests are checked against the OpenAPI document and
+// answered with stubResponses. The last request the stub received is kept in last.
+func newTestClient(t *testing.T) (*Client, *openapitest.Validator, **http.Request) {
+	var last *http.Request
+	stub := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		if r.URL.Path == "/api/v1/audit" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.WriteString(w, recordJSON+"\n"+recordJSON+"\n")
+			return
+		}
+		response, ok := stubResponses[r.Method+" "+r.URL.Path]
+		if !ok {
+			response.status, response.contentType, response.body = 404, "text/plain", "Invalid ID"
+		}
+		w.Header().Set("Content-Type", response.contentType)
+		w.WriteHeader(response.status)
+		io.WriteString(w, response.body)
+	})
+
+	v := openapitest.New(t)
+	return New("http://gateway.test/", "secret", &http.Client{Transport: v.Transport(stub)}), v, &last
+}
+
+func TestOperations(t *testing.T) {
+	c, v, _ := newTestClient(t)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Jane", LastName: "Tan", MobileNumber: "91234567", Email: "jane@example.com"}
+	trip := model.Trip{CarOwnerID: "O1", PickupLocation: "Ang Mo Kio", StartTime: time.Now(), Destination: "Ngee Ann Polytechnic", TotalSeats: 3}
+	series := model.TripSeries{CarOwnerID: "O1", PickupLocation: "Ang Mo Kio", Destination: "Ngee Ann Polytechnic", TotalSeats: 3, TimeOfDay: "08:15", Days: []string{"WEEKDAYS"}}
+
+	calls := []struct {
+		name string
+		call func() (interface{}, error)
+		want error
+	}{
+		{"ListUsers", func() (interface{}, error) { return c.ListUsers(ctx) }, nil},
+		{"GetUser", func() (interface{}, error) { return c.GetUser(ctx, "U1") }, nil},
+		{"CreateUser", func() (interface{}, error) { return c.CreateUser(ctx, "U1", user) }, nil},
+		{"UpdateUser", func() (interface{}, error) { return c.UpdateUser(ctx, "U1", user) }, nil},
+		{"DeleteUser", func() (interface{}, error) { return c.DeleteUser(ctx, "U1") }, nil},
+		{"BecomeCarOwner", func() (interface{}, error) {
+			return c.BecomeCarOwner(ctx, "U1", BecomeCarOwnerRequest{DriverLicense: "S1234567A", CarPlateNumber: "SBA1234A"})
+		}, nil},
+		{"BecomePassenger", func() (interface{}, error) { return c.BecomePassenger(ctx, "U1") }, nil},
+		{"ListNotifications", func() (interface{}, error) { return c.ListNotifications(ctx, "U1") }, nil},
+		{"ListTrips", func() (interface{}, error) { return c.ListTrips(ctx) }, nil},
+		{"GetTrip", func() (interface{}, error) { return c.GetTrip(ctx, "T1") }, nil},
+		{"CreateTrip", func() (interface{}, error) { return c.CreateTrip(ctx, "T1", trip) }, nil},
+		{"UpdateTrip", func() (interface{}, error) { return c.UpdateTrip(ctx, "T1", trip) }, nil},
+		{"DeleteTrip", func() (interface{}, error) { return c.DeleteTrip(ctx, "T1") }, nil},
+		{"EnrollPassenger", func() (interface{}, error) { return c.EnrollPassenger(ctx, "T1", EnrollPassengerRequest{UserID: "U1"}) }, nil},
+		{"WithdrawPassenger", func() (interface{}, error) {
+			return c.WithdrawPassenger(ctx, "T1", WithdrawPassengerRequest{UserID: "U1"})
+		}, nil},
+		{"StartTrip", func() (interface{}, error) { return c.StartTrip(ctx, "T1", "O1") }, ErrBadRequest},
+		{"ListSeries", func() (interface{}, error) { return c.ListSeries(ctx) }, nil},
+		{"GetSeries", func() (interface{}, error) { return c.GetSeries(ctx, "S1") }, nil},
+		{"CreateSeries", func() (interface{}, error) { return c.CreateSeries(ctx, "S1", series) }, ErrConflict},
+		{"UpdateSeries", func() (interface{}, error) { return c.UpdateSeries(ctx, "S1", series) }, nil},
+		{"DeleteSeries", func() (interface{}, error) { return c.DeleteSeries(ctx, "S1") }, nil},
+		{"SkipSeriesDate", func() (interface{}, error) {
+			return c.SkipSeriesDate(ctx, "S1", SkipSeriesDateRequest{Date: "2024-01-03"})
+		}, nil},
+		{"GetPolicy", func() (interface{}, error) { return c.GetPolicy(ctx) }, nil},
+		{"ExportUsers", func() (interface{}, error) { return c.ExportUsers(ctx, ExportUsersParams{}) }, nil},
+		{"ImportUsers", func() (interface{}, error) { return c.ImportUsers(ctx, []model.User{user}, ImportUsersParams{}) }, nil},
+		{"ExportTrips", func() (interface{}, error) { return c.ExportTrips(ctx, ExportTripsParams{Format: "json"}) }, nil},
+		{"ImportTrips", func() (interface{}, error) { return c.ImportTrips(ctx, []model.Trip{trip}, ImportTripsParams{}) }, ErrConflict},
+		{"QueryAuditLog", func() (interface{}, error) { return c.QueryAuditLog(ctx, QueryAuditLogParams{Format: "jsonl"}) }, nil},
+		{"GetTrip of a missing trip", func() (interface{}, error) { return c.GetTrip(ctx, "T9") }, ErrNotFound},
+	}
+	for _, call := range calls {
+		if _, err := call.call(); !errors.Is(err, call.want) {
+			t.Errorf("%s returned %v, want %v", call.name, err, call.want)
+		}
+	}
+
+	v.CheckCoverage("users", "notifications", "trips", "series", "policy", "audit")
+}
+
+func TestRequestsAndResponses(t *testing.T) {
+	c, _, last := newTestClient(t)
+	ctx := context.Background()
+
+	message, err := c.EnrollPassenger(ctx, "T1", EnrollPassengerRequest{UserID: "U1"})
+	if err != nil || message != "User U1 enrolled in trip T1 successfully" {
+		t.Errorf("EnrollPassenger returned %q, %v", message, err)
+	}
+	var body map[string]string
+	json.NewDecoder((*last).Body).Decode(&body)
+	if body["user_id"] != "U1" {
+		t.Errorf("EnrollPassenger sent %v", body)
+	}
+	if got := (*last).Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization is %q", got)
+	}
+
+	_, err = c.StartTrip(ctx, "T1", "O1")
+	if got := (*last).Header.Get("car-owner-id"); got != "O1" {
+		t.Errorf("car-owner-id is %q, want O1", got)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 || err.Error() != "Error - Trip cannot start without any enrolled passengers" {
+		t.Errorf("StartTrip returned %#v", err)
+	}
+
+	trip, err := c.GetTrip(ctx, "T1")
+	if err != nil || trip.TotalSeats != 3 || len(trip.EnrolledPassengers) != 1 || !trip.StartTime.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("GetTrip returned %+v, %v", trip, err)
+	}
+
+	policy, err := c.GetPolicy(ctx)
+	if err != nil || policy.StartWindowBefore != 15*time.Minute || policy.StartWindowAfter != time.Hour {
+		t.Errorf("GetPolicy returned %+v, %v", policy, err)
+	}
+
+	records, err := c.QueryAuditLog(ctx, QueryAuditLogParams{Entity: "user", Format: "jsonl"})
+	if err != nil || len(records) != 2 || records[0].EntityID != "U1" {
+		t.Errorf("QueryAuditLog returned %+v, %v", records, err)
+	}
+	if got := (*last).URL.RawQuery; got != "entity=user&format=jsonl" {
+		t.Errorf("QueryAuditLog sent the query %q", got)
+	}
+
+	report, err := c.ImportUsers(ctx, []model.User{{ID: "U1"}}, ImportUsersParams{OnConflict: "skip", DryRun: "true"})
+	if err != nil || !report.DryRun || report.Skipped != 1 || report.Rows[0].Result != "skipped" {
+		t.Errorf("ImportUsers returned %+v, %v", report, err)
+	}
+	if got := (*last).URL.RawQuery; got != "dry_run=true&on_conflict=skip" {
+		t.Errorf("ImportUsers sent the query %q", got)
+	}
+
+	status, err := c.Ready(ctx)
+	if !errors.Is(err, ErrUnavailable) || status.Checks["trip_service"] != "connection refused" {
+		t.Errorf("Ready returned %+v, %v", status, err)
+	}
+}
@@ -0,0 +1,273 @@
+//go:build ignore
+
+// gen.go writes operations.go, a method on Client for each public operation of the OpenAPI
+// document. Run it with "go generate ./client" after changing openapi/openapi.json.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// skippedTags are the operations the client has no methods for: the calls between the services,
+// and the endpoints meant for people and monitoring rather than programs
+var skippedTags = map[string]bool{"internal": true, "docs": true, "health": true}
+
+// schemaTypes maps the document's schemas to the Go types sent and received for them
+var schemaTypes = map[string]string{
+	"User":            "model.User",
+	"UserInput":       "model.User",
+	"UserRecord":      "model.User",
+	"Notification":    "model.Notification",
+	"Trip":            "model.Trip",
+	"TripInput":       "model.Trip",
+	"TripRecord":      "model.Trip",
+	"TripSeries":      "model.TripSeries",
+	"TripSeriesInput": "model.TripSeries",
+	"Policy":          "model.Policy",
+	"AuditRecord":     "audit.Record",
+	"ImportReport":    "model.ImportReport",
+}
+
+var methodOrder = map[string]int{"GET": 0, "POST": 1, "PUT": 2, "PATCH": 3, "DELETE": 4}
+
+func main() {
+	doc, err := openapi3.NewLoader().LoadFromFile("../openapi/openapi.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	type operation struct {
+		method, path string
+		*openapi3.Operation
+	}
+	var operations []operation
+	for path, item := range doc.Paths {
+		for method, op := range item.Operations() {
+			if len(op.Tags) > 0 && !skippedTags[op.Tags[0]] {
+				operations = append(operations, operation{method, path, op})
+			}
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].path != operations[j].path {
+			return operations[i].path < operations[j].path
+		}
+		return methodOrder[operations[i].method] < methodOrder[operations[j].method]
+	})
+
+	var code bytes.Buffer
+	for _, op := range operations {
+		writeOperation(&code, op.method, op.path, op.Operation)
+	}
+
+	// Only the packages the methods use are imported
+	var out bytes.Buffer
+	out.WriteString("// Code generated by gen.go from openapi/openapi.json; DO NOT EDIT.\n\n")
+	out.WriteString("package client\n\nimport (\n")
+	for _, path := range []string{"context", "net/http", "net/url", "", "github.com/Zachisastudent/ETI_Assignment-1/audit", "github.com/Zachisastudent/ETI_Assignment-1/model"} {
+		if path == "" {
+			out.WriteString("\n")
+		} else if bytes.Contains(code.Bytes(), []byte(path[strings.LastIndex(path, "/")+1:]+".")) {
+			fmt.Fprintf(&out, "%q\n", path)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(code.Bytes())
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("formatting the generated code: %v\n%s", err, out.Bytes())
+	}
+	if err := os.WriteFile("operations.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeOperation writes the method for one operation, with the types of its body and query parameters
+func writeOperation(out *bytes.Buffer, method, path string, op *openapi3.Operation) {
+	name := exported(op.OperationID)
+	args := []string{"ctx context.Context"}
+
+	// The path is built from its literal parts and the escaped path parameters
+	var pathExpr []string
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	literal := ""
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") {
+			literal += "/" + segment
+			continue
+		}
+		arg := pathParamName(segments[i-1])
+		args = append(args, arg+" string")
+		pathExpr = append(pathExpr, fmt.Sprintf("%q", literal+"/"), "url.PathEscape("+arg+")")
+		literal = ""
+	}
+	if literal != "" {
+		pathExpr = append(pathExpr, fmt.Sprintf("%q", literal))
+	}
+
+	var headers, queries []*openapi3.Parameter
+	for _, ref := range op.Parameters {
+		switch ref.Value.In {
+		case "header":
+			headers = append(headers, ref.Value)
+			args = append(args, camel(ref.Value.Name, false)+" string")
+		case "query":
+			queries = append(queries, ref.Value)
+		}
+	}
+
+	bodyExpr := "nil"
+	if op.RequestBody != nil && op.RequestBody.Value.Required {
+		schema := op.RequestBody.Value.Content.Get("application/json").Schema
+		bodyType := goType(schema)
+		if bodyType == "" {
+			bodyType = name + "Request"
+			writeStruct(out, bodyType, "is the body of "+name, schema.Value)
+		}
+		args = append(args, "body "+bodyType)
+		bodyExpr = "body"
+	}
+
+	queryExpr := "nil"
+	if len(queries) > 0 {
+		paramsType := name + "Params"
+		fmt.Fprintf(out, "\n// %s holds the query parameters of %s, empty ones are left out\n", paramsType, name)
+		fmt.Fprintf(out, "type %s struct {\n", paramsType)
+		for _, param := range queries {
+			fmt.Fprintf(out, "%s string // %s\n", camel(param.Name, true), param.Description)
+		}
+		out.WriteString("}\n")
+		args = append(args, "params "+paramsType)
+		queryExpr = "query"
+	}
+
+	resultType := "string"
+	for code, ref := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			if media := ref.Value.Content.Get("application/json"); media != nil {
+				resultType = goType(media.Schema)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "\n// %s %s, %s %s\n", name, describe(op.Summary), method, path)
+	fmt.Fprintf(out, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), resultType)
+	headerExpr := "nil"
+	if len(headers) > 0 {
+		out.WriteString("header := http.Header{}\n")
+		for _, param := range headers {
+			fmt.Fprintf(out, "header.Set(%q, %s)\n", param.Name, camel(param.Name, false))
+		}
+		headerExpr = "header"
+	}
+	if len(queries) > 0 {
+		out.WriteString("query := url.Values{}\n")
+		for _, param := range queries {
+			field := "params." + camel(param.Name, true)
+			fmt.Fprintf(out, "if %s != \"\" {\nquery.Set(%q, %s)\n}\n", field, param.Name, field)
+		}
+	}
+	fmt.Fprintf(out, "var result %s\n", resultType)
+	fmt.Fprintf(out, "err := c.do(ctx, %q, %s, %s, %s, %s, &result)\n", method, strings.Join(pathExpr, "+"), queryExpr, headerExpr, bodyExpr)
+	out.WriteString("return result, err\n}\n")
+}
+
+// writeStruct writes a struct type for an object schema declared inline
+func writeStruct(out *bytes.Buffer, name, doc string, schema *openapi3.Schema) {
+	fmt.Fprintf(out, "\n// %s %s\n", name, doc)
+	fmt.Fprintf(out, "type %s struct {\n", name)
+	var keys []string
+	for key := range schema.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(out, "%s %s `json:%q`\n", camel(key, true), goType(schema.Properties[key]), key)
+	}
+	out.WriteString("}\n")
+}
+
+// goType returns the Go type of a schema, or "" for an object declared inline
+func goType(ref *openapi3.SchemaRef) string {
+	if ref.Ref != "" {
+		name := ref.Ref[strings.LastIndex(ref.Ref, "/")+1:]
+		goName, ok := schemaTypes[name]
+		if !ok {
+			log.Fatalf("no Go type for schema %s, add it to schemaTypes", name)
+		}
+		return goName
+	}
+
+	schema := ref.Value
+	switch schema.Type {
+	case "string":
+		return "string"
+	case "integer":
+		return "int"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + goType(schema.Items)
+	case "object":
+		if schema.AdditionalProperties.Schema != nil {
+			return "map[string]" + goType(schema.AdditionalProperties.Schema)
+		}
+		return ""
+	}
+	log.Fatalf("unsupported schema type %q", schema.Type)
+	return ""
+}
+
+// pathParamName names a path parameter after the collection it is in, e.g. "users" gives "userID"
+func pathParamName(collection string) string {
+	if collection != "series" {
+		collection = strings.TrimSuffix(collection, "s")
+	}
+	return collection + "ID"
+}
+
+// camel turns a name such as "car_plate_number" or "car-owner-id" into "CarPlateNumber" or "carOwnerID"
+func camel(name string, export bool) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' })
+	for i, word := range words {
+		switch {
+		case word == "id":
+			words[i] = "ID"
+		case i > 0 || export:
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+func exported(operationID string) string {
+	return strings.ToUpper(operationID[:1]) + operationID[1:]
+}
+
+// describe turns a summary such as "Get a user" into "gets a user" for the method's comment.
+// A second verb joined with "and", as in "Cancel and remove a trip", is turned too.
+func describe(summary string) string {
+	verb, rest, _ := strings.Cut(summary, " ")
+	if second, ok := strings.CutPrefix(rest, "and "); ok {
+		rest = "and " + describe(second)
+	}
+	verb = strings.ToLower(verb)
+	switch {
+	case strings.HasSuffix(verb, "sh"), strings.HasSuffix(verb, "ch"), strings.HasSuffix(verb, "s"):
+		verb += "es"
+	case strings.HasSuffix(verb, "y") && !strings.ContainsAny(verb[len(verb)-2:len(verb)-1], "aeiou"):
+		verb = verb[:len(verb)-1] + "ies"
+	default:
+		verb += "s"
+	}
+	return verb + " " + rest
+}
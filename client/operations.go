@@ -0,0 +1,292 @@
+// Code generated by gen.go from openapi/openapi.json; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// ExportTripsParams holds the query parameters of ExportTrips, empty ones are left out
+type ExportTripsParams struct {
+	Format string // csv exports the trips as CSV
+}
+
+// ExportTrips exports every trip, GET /api/v1/admin/trips/export
+func (c *Client) ExportTrips(ctx context.Context, params ExportTripsParams) ([]model.Trip, error) {
+	query := url.Values{}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	var result []model.Trip
+	err := c.do(ctx, "GET", "/api/v1/admin/trips/export", query, nil, nil, &result)
+	return result, err
+}
+
+// ImportTripsParams holds the query parameters of ImportTrips, empty ones are left out
+type ImportTripsParams struct {
+	OnConflict string // What to do with a trip whose ID is taken: fail the row, which is the default, skip it or overwrite the trip
+	DryRun     string // true checks the rows without storing them
+}
+
+// ImportTrips imports trips, POST /api/v1/admin/trips/import
+func (c *Client) ImportTrips(ctx context.Context, body []model.Trip, params ImportTripsParams) (model.ImportReport, error) {
+	query := url.Values{}
+	if params.OnConflict != "" {
+		query.Set("on_conflict", params.OnConflict)
+	}
+	if params.DryRun != "" {
+		query.Set("dry_run", params.DryRun)
+	}
+	var result model.ImportReport
+	err := c.do(ctx, "POST", "/api/v1/admin/trips/import", query, nil, body, &result)
+	return result, err
+}
+
+// ExportUsersParams holds the query parameters of ExportUsers, empty ones are left out
+type ExportUsersParams struct {
+	Format string // csv exports the users as CSV
+}
+
+// ExportUsers exports every user, GET /api/v1/admin/users/export
+func (c *Client) ExportUsers(ctx context.Context, params ExportUsersParams) ([]model.User, error) {
+	query := url.Values{}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	var result []model.User
+	err := c.do(ctx, "GET", "/api/v1/admin/users/export", query, nil, nil, &result)
+	return result, err
+}
+
+// ImportUsersParams holds the query parameters of ImportUsers, empty ones are left out
+type ImportUsersParams struct {
+	OnConflict string // What to do with a user whose ID is taken: fail the row, which is the default, skip it or overwrite the user
+	DryRun     string // true checks the rows without storing them
+}
+
+// ImportUsers imports users, POST /api/v1/admin/users/import
+func (c *Client) ImportUsers(ctx context.Context, body []model.User, params ImportUsersParams) (model.ImportReport, error) {
+	query := url.Values{}
+	if params.OnConflict != "" {
+		query.Set("on_conflict", params.OnConflict)
+	}
+	if params.DryRun != "" {
+		query.Set("dry_run", params.DryRun)
+	}
+	var result model.ImportReport
+	err := c.do(ctx, "POST", "/api/v1/admin/users/import", query, nil, body, &result)
+	return result, err
+}
+
+// QueryAuditLogParams holds the query parameters of QueryAuditLog, empty ones are left out
+type QueryAuditLogParams struct {
+	Entity string // Only records of this kind of entity
+	ID     string // Only records of the entity with this ID
+	Format string // jsonl exports the records as JSON lines
+}
+
+// QueryAuditLog queries the audit log, GET /api/v1/audit
+func (c *Client) QueryAuditLog(ctx context.Context, params QueryAuditLogParams) ([]audit.Record, error) {
+	query := url.Values{}
+	if params.Entity != "" {
+		query.Set("entity", params.Entity)
+	}
+	if params.ID != "" {
+		query.Set("id", params.ID)
+	}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	var result []audit.Record
+	err := c.do(ctx, "GET", "/api/v1/audit", query, nil, nil, &result)
+	return result, err
+}
+
+// GetPolicy gets the trip timing policy, GET /api/v1/policy
+func (c *Client) GetPolicy(ctx context.Context) (model.Policy, error) {
+	var result model.Policy
+	err := c.do(ctx, "GET", "/api/v1/policy", nil, nil, nil, &result)
+	return result, err
+}
+
+// ListSeries lists every recurring trip, GET /api/v1/series
+func (c *Client) ListSeries(ctx context.Context) (map[string]model.TripSeries, error) {
+	var result map[string]model.TripSeries
+	err := c.do(ctx, "GET", "/api/v1/series", nil, nil, nil, &result)
+	return result, err
+}
+
+// GetSeries gets a recurring trip, GET /api/v1/series/{id}
+func (c *Client) GetSeries(ctx context.Context, seriesID string) (model.TripSeries, error) {
+	var result model.TripSeries
+	err := c.do(ctx, "GET", "/api/v1/series/"+url.PathEscape(seriesID), nil, nil, nil, &result)
+	return result, err
+}
+
+// CreateSeries publishes a recurring trip, POST /api/v1/series/{id}
+func (c *Client) CreateSeries(ctx context.Context, seriesID string, body model.TripSeries) (string, error) {
+	var result string
+	err := c.do(ctx, "POST", "/api/v1/series/"+url.PathEscape(seriesID), nil, nil, body, &result)
+	return result, err
+}
+
+// UpdateSeries updates a recurring trip, PUT /api/v1/series/{id}
+func (c *Client) UpdateSeries(ctx context.Context, seriesID string, body model.TripSeries) (string, error) {
+	var result string
+	err := c.do(ctx, "PUT", "/api/v1/series/"+url.PathEscape(seriesID), nil, nil, body, &result)
+	return result, err
+}
+
+// DeleteSeries removes a recurring trip, DELETE /api/v1/series/{id}
+func (c *Client) DeleteSeries(ctx context.Context, seriesID string) (string, error) {
+	var result string
+	err := c.do(ctx, "DELETE", "/api/v1/series/"+url.PathEscape(seriesID), nil, nil, nil, &result)
+	return result, err
+}
+
+// SkipSeriesDateRequest is the body of SkipSeriesDate
+type SkipSeriesDateRequest struct {
+	Date string `json:"date"`
+}
+
+// SkipSeriesDate skips one date of a recurring trip, POST /api/v1/series/{id}/skip
+func (c *Client) SkipSeriesDate(ctx context.Context, seriesID string, body SkipSeriesDateRequest) (string, error) {
+	var result string
+	err := c.do(ctx, "POST", "/api/v1/series/"+url.PathEscape(seriesID)+"/skip", nil, nil, body, &result)
+	return result, err
+}
+
+// ListTrips lists every trip, GET /api/v1/trips
+func (c *Client) ListTrips(ctx context.Context) (map[string]model.Trip, error) {
+	var result map[string]model.Trip
+	err := c.do(ctx, "GET", "/api/v1/trips", nil, nil, nil, &result)
+	return result, err
+}
+
+// GetTrip gets a trip, GET /api/v1/trips/{id}
+func (c *Client) GetTrip(ctx context.Context, tripID string) (model.Trip, error) {
+	var result model.Trip
+	err := c.do(ctx, "GET", "/api/v1/trips/"+url.PathEscape(tripID), nil, nil, nil, &result)
+	return result, err
+}
+
+// CreateTrip publishes a trip, POST /api/v1/trips/{id}
+func (c *Client) CreateTrip(ctx context.Context, tripID string, body model.Trip) (string, error) {
+	var result string
+	err := c.do(ctx, "POST", "/api/v1/trips/"+url.PathEscape(tripID), nil, nil, body, &result)
+	return result, err
+}
+
+// UpdateTrip updates a trip, PUT /api/v1/trips/{id}
+func (c *Client) UpdateTrip(ctx context.Context, tripID string, body model.Trip) (string, error) {
+	var result string
+	err := c.do(ctx, "PUT", "/api/v1/trips/"+url.PathEscape(tripID), nil, nil, body, &result)
+	return result, err
+}
+
+// DeleteTrip cancels and removes a trip, DELETE /api/v1/trips/{id}
+func (c *Client) DeleteTrip(ctx context.Context, tripID string) (string, error) {
+	var result string
+	err := c.do(ctx, "DELETE", "/api/v1/trips/"+url.PathEscape(tripID), nil, nil, nil, &result)
+	return result, err
+}
+
+// EnrollPassengerRequest is the body of EnrollPassenger
+type EnrollPassengerRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// EnrollPassenger enrolls a passenger in a trip, PUT /api/v1/trips/{id}/enroll
+func (c *Client) EnrollPassenger(ctx context.Context, tripID string, body EnrollPassengerRequest) (string, error) {
+	var result string
+	err := c.do(ctx, "PUT", "/api/v1/trips/"+url.PathEscape(tripID)+"/enroll", nil, nil, body, &result)
+	return result, err
+}
+
+// StartTrip starts a trip, PUT /api/v1/trips/{id}/start
+func (c *Client) StartTrip(ctx context.Context, tripID string, carOwnerID string) (string, error) {
+	header := http.Header{}
+	header.Set("car-owner-id", carOwnerID)
+	var result string
+	err := c.do(ctx, "PUT", "/api/v1/trips/"+url.PathEscape(tripID)+"/start", nil, header, nil, &result)
+	return result, err
+}
+
+// WithdrawPassengerRequest is the body of WithdrawPassenger
+type WithdrawPassengerRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// WithdrawPassenger withdraws a passenger from a trip, PUT /api/v1/trips/{id}/withdraw
+func (c *Client) WithdrawPassenger(ctx context.Context, tripID string, body WithdrawPassengerRequest) (string, error) {
+	var result string
+	err := c.do(ctx, "PUT", "/api/v1/trips/"+url.PathEscape(tripID)+"/withdraw", nil, nil, body, &result)
+	return result, err
+}
+
+// ListUsers lists every user, GET /api/v1/users
+func (c *Client) ListUsers(ctx context.Context) (map[string]model.User, error) {
+	var result map[string]model.User
+	err := c.do(ctx, "GET", "/api/v1/users", nil, nil, nil, &result)
+	return result, err
+}
+
+// GetUser gets a user, GET /api/v1/users/{id}
+func (c *Client) GetUser(ctx context.Context, userID string) (model.User, error) {
+	var result model.User
+	err := c.do(ctx, "GET", "/api/v1/users/"+url.PathEscape(userID), nil, nil, nil, &result)
+	return result, err
+}
+
+// CreateUser registers a user, POST /api/v1/users/{id}
+func (c *Client) CreateUser(ctx context.Context, userID string, body model.User) (string, error) {
+	var result string
+	err := c.do(ctx, "POST", "/api/v1/users/"+url.PathEscape(userID), nil, nil, body, &result)
+	return result, err
+}
+
+// UpdateUser updates a user, PUT /api/v1/users/{id}
+func (c *Client) UpdateUser(ctx context.Context, userID string, body model.User) (string, error) {
+	var result string
+	err := c.do(ctx, "PUT", "/api/v1/users/"+url.PathEscape(userID), nil, nil, body, &result)
+	return result, err
+}
+
+// DeleteUser deletes a user, DELETE /api/v1/users/{id}
+func (c *Client) DeleteUser(ctx context.Context, userID string) (string, error) {
+	var result string
+	err := c.do(ctx, "DELETE", "/api/v1/users/"+url.PathEscape(userID), nil, nil, nil, &result)
+	return result, err
+}
+
+// BecomeCarOwnerRequest is the body of BecomeCarOwner
+type BecomeCarOwnerRequest struct {
+	CarPlateNumber string `json:"car_plate_number"`
+	DriverLicense  string `json:"driver_license"`
+}
+
+// BecomeCarOwner upgrades a passenger to a car owner, POST /api/v1/users/{id}/become-owner
+func (c *Client) BecomeCarOwner(ctx context.Context, userID string, body BecomeCarOwnerRequest) (string, error) {
+	var result string
+	err := c.do(ctx, "POST", "/api/v1/users/"+url.PathEscape(userID)+"/become-owner", nil, nil, body, &result)
+	return result, err
+}
+
+// BecomePassenger downgrades a car owner to a passenger, POST /api/v1/users/{id}/become-passenger
+func (c *Client) BecomePassenger(ctx context.Context, userID string) (string, error) {
+	var result string
+	err := c.do(ctx, "POST", "/api/v1/users/"+url.PathEscape(userID)+"/become-passenger", nil, nil, nil, &result)
+	return result, err
+}
+
+// ListNotifications lists the notifications left for a user, oldest first, GET /api/v1/users/{id}/notifications
+func (c *Client) ListNotifications(ctx context.Context, userID string) ([]model.Notification, error) {
+	var result []model.Notification
+	err := c.do(ctx, "GET", "/api/v1/users/"+url.PathEscape(userID)+"/notifications", nil, nil, nil, &result)
+	return result, err
+}
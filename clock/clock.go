@@ -0,0 +1,54 @@
+// Package clock tells the programs the time. The handlers read it through a Clock rather than
+// calling time.Now, so the rules that depend on the time, such as the publishing lead time and the
+// account retention, can be tested at exact times with a Fake.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock returns the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the clock of the machine the program runs on
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a clock for tests that stands still until it is set or advanced
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake returns a fake clock showing now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to now
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// Advance moves the clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
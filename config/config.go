@@ -0,0 +1,490 @@
+// Package config loads the configuration of the car-pooling programs. Every setting has a default,
+// which can be overridden by an optional YAML or TOML file, then by an environment variable and
+// finally by a command line flag. The file is named with -config or CARPOOL_CONFIG and can be
+// shared by every program, each program only reads the sections it uses:
+//
+//	gateway:
+//	  listen_addr: ":8222"
+//	user_service:
+//	  url: "http://localhost:8223"
+//	  event_log: "file:user-events.jsonl"
+//	policy:
+//	  cancel_cutoff: "1h"
+//
+// The same setting is USER_SERVICE_URL in the environment and -user-service.url on the command line.
+// -print-config prints the settings a program would run with, and where each came from, and exits.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPrinted is returned by Load when -print-config was given and the configuration has been printed
+var ErrPrinted = errors.New("configuration printed")
+
+// Config holds every setting of the car-pooling programs, grouped in sections
+type Config struct {
+	Gateway     GatewayConfig `key:"gateway"`
+	UserService ServiceConfig `key:"user_service" env:"USER"`
+	TripService ServiceConfig `key:"trip_service" env:"TRIP"`
+	Console     ConsoleConfig `key:"console"`
+	Policy      PolicyConfig  `key:"policy"`
+	Display     DisplayConfig `key:"display"`
+	HTTP        HTTPConfig    `key:"http"`
+	Log         LogConfig     `key:"log"`
+	TLS         TLSConfig     `key:"tls"`
+}
+
+// GatewayConfig holds the settings of the gateway
+type GatewayConfig struct {
+	ListenAddr   string            `key:"listen_addr" env:"GATEWAY_LISTEN_ADDR" usage:"address the gateway listens on"`
+	APIKeys      map[string]string `key:"api_keys" env:"GATEWAY_API_KEYS" secret:"true" usage:"client API keys as client=key,client=key"`
+	AdminClients []string          `key:"admin_clients" env:"GATEWAY_ADMIN_CLIENTS" usage:"clients allowed to query the audit log"`
+	RateLimit    float64           `key:"rate_limit" env:"GATEWAY_RATE_LIMIT" usage:"requests per second allowed for each client"`
+	RateBurst    int               `key:"rate_burst" env:"GATEWAY_RATE_BURST" usage:"requests a client can send in a burst"`
+}
+
+// ServiceConfig holds the settings of the User service or the Trip service. A * in an
+// environment variable is replaced by the service's prefix, USER or TRIP.
+type ServiceConfig struct {
+	ListenAddr string `key:"listen_addr" env:"*_SERVICE_LISTEN_ADDR" usage:"address the service listens on"`
+	URL        string `key:"url" env:"*_SERVICE_URL" usage:"base URL the other programs reach the service at"`
+	EventLog   string `key:"event_log" env:"*_EVENT_LOG" usage:"storage DSN of the event log, file:<path> or memory:"`
+	AuditLog   string `key:"audit_log" env:"*_AUDIT_LOG" usage:"path of the audit log"`
+}
+
+// ConsoleConfig holds the settings of the console
+type ConsoleConfig struct {
+	GatewayURL      string        `key:"gateway_url" env:"CARPOOL_GATEWAY_URL" usage:"base URL of the gateway"`
+	APIKey          string        `key:"api_key" env:"CARPOOL_API_KEY" secret:"true" usage:"API key sent to the gateway"`
+	RefreshInterval time.Duration `key:"refresh_interval" env:"CARPOOL_REFRESH_INTERVAL" usage:"how often the terminal UI reloads the users and trips, 0 turns it off"`
+}
+
+// PolicyConfig holds the timing rules for trips and accounts
+type PolicyConfig struct {
+	PublishLeadTime   time.Duration `key:"publish_lead_time" env:"POLICY_PUBLISH_LEAD_TIME" usage:"how long before departure trips must be published"`
+	StartWindowBefore time.Duration `key:"start_window_before" env:"POLICY_START_WINDOW_BEFORE" usage:"how long before departure trips can be started"`
+	StartWindowAfter  time.Duration `key:"start_window_after" env:"POLICY_START_WINDOW_AFTER" usage:"how long after departure trips can be started"`
+	CancelCutoff      time.Duration `key:"cancel_cutoff" env:"POLICY_CANCEL_CUTOFF" usage:"how long before departure trips can be cancelled"`
+	EnrollmentCutoff  time.Duration `key:"enrollment_cutoff" env:"POLICY_ENROLLMENT_CUTOFF" usage:"how long before departure passengers can enroll"`
+	AccountRetention  time.Duration `key:"account_retention" env:"POLICY_ACCOUNT_RETENTION" usage:"how long accounts are kept before they can be deleted"`
+}
+
+// DisplayConfig holds how times are shown to people
+type DisplayConfig struct {
+	TimeZone string `key:"time_zone" env:"CARPOOL_DISPLAY_TZ" usage:"IANA time zone trip times are shown in, the local zone if empty"`
+}
+
+// HTTPConfig holds the timeouts of the HTTP servers
+type HTTPConfig struct {
+	ReadTimeout     time.Duration `key:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"how long a server waits to read a request"`
+	WriteTimeout    time.Duration `key:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"how long a server may take to write a response"`
+	IdleTimeout     time.Duration `key:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"how long idle keep-alive connections are kept open"`
+	ShutdownTimeout time.Duration `key:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"how long requests in flight get to finish on shutdown"`
+}
+
+// LogConfig holds the level and format of the logs
+type LogConfig struct {
+	Level  string `key:"level" env:"LOG_LEVEL" usage:"debug, info, warn or error"`
+	Format string `key:"format" env:"LOG_FORMAT" usage:"text or json"`
+}
+
+// TLSConfig holds the certificates used for HTTPS. The servers use HTTPS when a certificate is
+// configured or self_signed is set, and mutual makes the services accept only callers that
+// present a certificate signed by the CA.
+type TLSConfig struct {
+	CertFile    string `key:"cert_file" env:"TLS_CERT_FILE" usage:"PEM certificate the servers present"`
+	KeyFile     string `key:"key_file" env:"TLS_KEY_FILE" usage:"PEM private key of the certificate"`
+	CAFile      string `key:"ca_file" env:"TLS_CA_FILE" usage:"PEM certificate authority clients trust"`
+	SelfSigned  bool   `key:"self_signed" env:"TLS_SELF_SIGNED" usage:"serve HTTPS with a generated self-signed development certificate"`
+	DevCertFile string `key:"dev_cert_file" env:"TLS_DEV_CERT_FILE" usage:"file the self-signed development certificate and its key are kept in"`
+	Mutual      bool   `key:"mutual" env:"TLS_MUTUAL" usage:"require the programs to present their certificate when they call the services"`
+}
+
+// Enabled reports whether the servers use HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.SelfSigned
+}
+
+// Default returns the configuration the programs run with when nothing is configured. It has no
+// API keys, the gateway and the console refuse to start until they are set.
+func Default() *Config {
+	return &Config{
+		Gateway: GatewayConfig{
+			ListenAddr:   ":8222",
+			AdminClients: []string{"console"},
+			RateLimit:    10,
+			RateBurst:    20,
+		},
+		UserService: ServiceConfig{
+			ListenAddr: ":8223",
+			URL:        "http://localhost:8223",
+			EventLog:   "file:user-events.jsonl",
+			AuditLog:   "user-audit.jsonl",
+		},
+		TripService: ServiceConfig{
+			ListenAddr: ":8224",
+			URL:        "http://localhost:8224",
+			EventLog:   "file:trip-events.jsonl",
+			AuditLog:   "trip-audit.jsonl",
+		},
+		Console: ConsoleConfig{
+			GatewayURL:      "http://localhost:8222",
+			RefreshInterval: 5 * time.Second,
+		},
+		Policy: PolicyConfig{
+			PublishLeadTime:   30 * time.Minute,
+			StartWindowBefore: 30 * time.Minute,
+			StartWindowAfter:  30 * time.Minute,
+			CancelCutoff:      30 * time.Minute,
+			EnrollmentCutoff:  30 * time.Minute,
+			AccountRetention:  365 * 24 * time.Hour,
+		},
+		HTTP: HTTPConfig{
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		TLS: TLSConfig{
+			DevCertFile: "carpool-dev-cert.pem",
+		},
+	}
+}
+
+// programSections lists the sections each program reads
+var programSections = map[string][]string{
+	"gateway":      {"gateway", "user_service", "trip_service", "http", "log", "tls"},
+	"user-service": {"user_service", "trip_service", "policy", "http", "log", "tls"},
+	"trip-service": {"trip_service", "user_service", "policy", "display", "http", "log", "tls"},
+	"console":      {"console", "policy", "display", "tls"},
+}
+
+// Load returns the configuration of program, one of gateway, user-service, trip-service or console.
+// It registers -config, -print-config and a flag for every setting the program reads on flags and
+// parses args with it, so the program's own flags must be defined first. If -print-config was given
+// the configuration is printed to standard output and ErrPrinted is returned if it is valid, or the
+// validation errors if it is not.
+func Load(program string, flags *flag.FlagSet, args []string) (*Config, error) {
+	sections, ok := programSections[program]
+	if !ok {
+		return nil, fmt.Errorf("unknown program %q", program)
+	}
+
+	c := Default()
+	settings := c.settings(sections)
+
+	configFile := flags.String("config", "", "YAML or TOML configuration file (env CARPOOL_CONFIG)")
+	printConfig := flags.Bool("print-config", false, "print the configuration and exit")
+
+	type flagValue struct {
+		setting *setting
+		value   string
+	}
+	var flagValues []flagValue
+	for _, s := range settings {
+		s := s
+		usage := s.usage + " (env " + s.env
+		if !s.secret && !s.value.IsZero() {
+			usage += ", default " + s.format()
+		}
+		record := func(value string) error {
+			flagValues = append(flagValues, flagValue{s, value})
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			flags.BoolFunc(s.flagName(), usage+")", record)
+		} else {
+			flags.Func(s.flagName(), usage+")", record)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv("CARPOOL_CONFIG")
+	}
+	if *configFile != "" {
+		if err := loadFile(*configFile, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(value, "env "+s.env); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, f := range flagValues {
+		if err := f.setting.set(f.value, "flag -"+f.setting.flagName()); err != nil {
+			return nil, err
+		}
+	}
+
+	// With HTTPS on the programs reach each other over it, unless their URLs are configured
+	if c.TLS.Enabled() {
+		for _, s := range settings {
+			if s.source == "default" && (s.key == "url" || s.key == "gateway_url") {
+				s.value.SetString(strings.Replace(s.value.String(), "http://", "https://", 1))
+				s.source = "default, https as TLS is on"
+			}
+		}
+	}
+
+	// The settings are printed before they are validated, so an invalid one can be traced to its source
+	if *printConfig {
+		if err := printSettings(os.Stdout, settings); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.validate(sections); err != nil {
+		return nil, err
+	}
+	if *printConfig {
+		return nil, ErrPrinted
+	}
+	return c, nil
+}
+
+// loadFile applies the settings in a YAML or TOML file, chosen by its extension
+func loadFile(path string, settings []*setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file map[string]map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	default:
+		return fmt.Errorf("%s: unknown configuration format %q, expected .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	known := map[string]*setting{}
+	knownSections := map[string]bool{}
+	for _, s := range (&Config{}).settings(nil) {
+		knownSections[s.section] = true
+	}
+	for _, s := range settings {
+		known[s.section+"."+s.key] = s
+	}
+
+	for section, values := range file {
+		if !knownSections[section] {
+			return fmt.Errorf("%s: unknown section %q", path, section)
+		}
+		for key, value := range values {
+			s, ok := known[section+"."+key]
+			if !ok {
+				if (&Config{}).hasSetting(section, key) {
+					continue // read by another program
+				}
+				return fmt.Errorf("%s: unknown setting %s.%s", path, section, key)
+			}
+			if err := s.set(fileValue(value), "file "+path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fileValue turns a value decoded from a file into the form used by environment variables and flags
+func fileValue(value interface{}) string {
+	switch value := value.(type) {
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(value))
+		for key, item := range value {
+			pairs = append(pairs, key+"="+fmt.Sprint(item))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// setting is a single configuration value, addressed as section.key
+type setting struct {
+	section string
+	key     string
+	env     string
+	usage   string
+	secret  bool
+	source  string
+	value   reflect.Value
+}
+
+// settings returns the settings of the given sections, or of every section if sections is nil
+func (c *Config) settings(sections []string) []*setting {
+	wanted := map[string]bool{}
+	for _, section := range sections {
+		wanted[section] = true
+	}
+
+	var settings []*setting
+	config := reflect.ValueOf(c).Elem()
+	for i := 0; i < config.NumField(); i++ {
+		sectionField := config.Type().Field(i)
+		section := sectionField.Tag.Get("key")
+		if sections != nil && !wanted[section] {
+			continue
+		}
+
+		values := config.Field(i)
+		for j := 0; j < values.NumField(); j++ {
+			field := values.Type().Field(j)
+			settings = append(settings, &setting{
+				section: section,
+				key:     field.Tag.Get("key"),
+				env:     strings.Replace(field.Tag.Get("env"), "*", sectionField.Tag.Get("env"), 1),
+				usage:   field.Tag.Get("usage"),
+				secret:  field.Tag.Get("secret") == "true",
+				source:  "default",
+				value:   values.Field(j),
+			})
+		}
+	}
+
+	// Keep the order of the program's sections so the most relevant ones are printed first
+	if sections != nil {
+		order := map[string]int{}
+		for i, section := range sections {
+			order[section] = i
+		}
+		sort.SliceStable(settings, func(i, j int) bool {
+			return order[settings[i].section] < order[settings[j].section]
+		})
+	}
+	return settings
+}
+
+func (c *Config) hasSetting(section, key string) bool {
+	for _, s := range c.settings(nil) {
+		if s.section == section && s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// flagName is the setting's command line flag, e.g. user-service.listen-addr
+func (s *setting) flagName() string {
+	return strings.ReplaceAll(s.section+"."+s.key, "_", "-")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses value into the setting and records where it came from
+func (s *setting) set(value, source string) error {
+	value = strings.TrimSpace(value)
+	invalid := func(expected string) error {
+		shown := value
+		if s.secret {
+			shown = "********"
+		}
+		return fmt.Errorf("invalid %s.%s %q from %s, expected %s", s.section, s.key, shown, source, expected)
+	}
+
+	switch {
+	case s.value.Type() == durationType:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return invalid("a duration such as 30s or 1h")
+		}
+		s.value.SetInt(int64(duration))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(value)
+	case s.value.Kind() == reflect.Int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return invalid("a whole number")
+		}
+		s.value.SetInt(int64(number))
+	case s.value.Kind() == reflect.Bool:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid("true or false")
+		}
+		s.value.SetBool(enabled)
+	case s.value.Kind() == reflect.Float64:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return invalid("a number")
+		}
+		s.value.SetFloat(number)
+	case s.value.Kind() == reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	case s.value.Kind() == reflect.Map:
+		pairs := map[string]string{}
+		for _, entry := range strings.Split(value, ",") {
+			key, item, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || key == "" || item == "" {
+				return invalid("name=value pairs separated by commas")
+			}
+			pairs[key] = item
+		}
+		s.value.Set(reflect.ValueOf(pairs))
+	default:
+		panic("config: unsupported setting type " + s.value.Type().String())
+	}
+	s.source = source
+	return nil
+}
+
+// format returns the setting's value in the form it is set with
+func (s *setting) format() string {
+	switch {
+	case s.value.Type() == durationType:
+		return time.Duration(s.value.Int()).String()
+	case s.value.Kind() == reflect.Slice:
+		return strings.Join(s.value.Interface().([]string), ",")
+	case s.value.Kind() == reflect.Map:
+		var pairs []string
+		for key, item := range s.value.Interface().(map[string]string) {
+			pairs = append(pairs, key+"="+item)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(s.value.Interface())
+	}
+}
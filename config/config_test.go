@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load runs Load for the program with the given arguments, it writes files into a temporary
+// directory first so -config can name them
+func load(t *testing.T, program string, files map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	args = append([]string(nil), args...)
+	for i, arg := range args {
+		args[i] = strings.ReplaceAll(arg, "$DIR", dir)
+	}
+
+	flags := flag.NewFlagSet(program, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return Load(program, flags, args)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := map[string]string{"carpool.yaml": "policy:\n  cancel_cutoff: \"1h\"\n"}
+	tomlFile := map[string]string{"carpool.toml": "[policy]\ncancel_cutoff = \"4h\"\n"}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		env   map[string]string
+		args  []string
+		want  time.Duration
+	}{
+		{"default", nil, nil, nil, 30 * time.Minute},
+		{"YAML file", yamlFile, nil, []string{"-config", "$DIR/carpool.yaml"}, time.Hour},
+		{"TOML file", tomlFile, nil, []string{"-config", "$DIR/carpool.toml"}, 4 * time.Hour},
+		{"file named in the environment", yamlFile, map[string]string{"CARPOOL_CONFIG": "$DIR/carpool.yaml"}, nil, time.Hour},
+		{"env over the default", nil, map[string]string{"POLICY_CANCEL_CUTOFF": "2h"}, nil, 2 * time.Hour},
+		{"env over the file", yamlFile, map[string]string{"POLICY_CANCEL_CUTOFF": "2h"}, []string{"-config", "$DIR/carpool.yaml"}, 2 * time.Hour},
+		{"flag over the file", yamlFile, nil, []string{"-config", "$DIR/carpool.yaml", "-policy.cancel-cutoff", "3h"}, 3 * time.Hour},
+		{"flag over env and the file", yamlFile, map[string]string{"POLICY_CANCEL_CUTOFF": "2h"}, []string{"-policy.cancel-cutoff", "3h", "-config", "$DIR/carpool.yaml"}, 3 * time.Hour},
+		{"last flag wins", nil, nil, []string{"-policy.cancel-cutoff", "3h", "-policy.cancel-cutoff", "5h"}, 5 * time.Hour},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := ""
+			if test.files != nil {
+				// CARPOOL_CONFIG names the file before load writes it, so write it here instead
+				dir = t.TempDir()
+				for name, content := range test.files {
+					if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			for key, value := range test.env {
+				t.Setenv(key, strings.ReplaceAll(value, "$DIR", dir))
+			}
+			args := make([]string, len(test.args))
+			for i, arg := range test.args {
+				args[i] = strings.ReplaceAll(arg, "$DIR", dir)
+			}
+
+			c, err := load(t, "user-service", nil, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Policy.CancelCutoff != test.want {
+				t.Errorf("policy.cancel_cutoff is %v, want %v", c.Policy.CancelCutoff, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadReadsOnlyTheProgramsSections(t *testing.T) {
+	// The console's section is read by the console only, the user service leaves it alone
+	files := map[string]string{"carpool.yaml": "console:\n  gateway_url: \"http://gateway:8222\"\nuser_service:\n  url: \"http://users:8223\"\n"}
+	c, err := load(t, "user-service", files, "-config", "$DIR/carpool.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.UserService.URL != "http://users:8223" || c.Console.GatewayURL != Default().Console.GatewayURL {
+		t.Errorf("the user service read user_service.url %q and console.gateway_url %q", c.UserService.URL, c.Console.GatewayURL)
+	}
+	if _, err := load(t, "user-service", nil, "-console.gateway-url", "http://gateway:8222"); err == nil {
+		t.Error("the user service accepted the console's flag")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		files   map[string]string
+		args    []string
+		want    []string
+	}{
+		{"unknown program", "billing", nil, nil, []string{`unknown program "billing"`}},
+		{"gateway without API keys", "gateway", nil, nil, []string{"gateway.api_keys must have at least one client=key pair"}},
+		{"console without an API key", "console", nil, nil, []string{"console.api_key must be set"}},
+		{"invalid duration", "user-service", nil, []string{"-policy.cancel-cutoff", "soon"},
+			[]string{`invalid policy.cancel_cutoff "soon" from flag -policy.cancel-cutoff, expected a duration`}},
+		{"invalid number", "gateway", nil, []string{"-gateway.rate-burst", "many"},
+			[]string{`invalid gateway.rate_burst "many" from flag -gateway.rate-burst, expected a whole number`}},
+		{"invalid API keys are masked", "gateway", nil, []string{"-gateway.api-keys", "console"},
+			[]string{`invalid gateway.api_keys "********" from flag -gateway.api-keys, expected name=value pairs`}},
+		{"unknown file format", "user-service", map[string]string{"carpool.json": "{}"}, []string{"-config", "$DIR/carpool.json"},
+			[]string{`unknown configuration format ".json"`}},
+		{"unknown section", "user-service", map[string]string{"carpool.yaml": "billing:\n  url: \"x\"\n"}, []string{"-config", "$DIR/carpool.yaml"},
+			[]string{`unknown section "billing"`}},
+		{"unknown setting", "user-service", map[string]string{"carpool.yaml": "policy:\n  cancel_cut_off: \"1h\"\n"}, []string{"-config", "$DIR/carpool.yaml"},
+			[]string{"unknown setting policy.cancel_cut_off"}},
+		{"every problem at once", "user-service", nil,
+			[]string{"-policy.cancel-cutoff", "-1h", "-log.level", "loud", "-user-service.url", "users:8223", "-http.read-timeout", "0s"},
+			[]string{"policy.cancel_cutoff must not be negative", `log.level "loud" is not debug`, `user_service.url "users:8223" is not an http or https URL`, "http.read_timeout must be greater than zero"}},
+		{"invalid event log", "trip-service", nil, []string{"-trip-service.event-log", "s3:bucket"},
+			[]string{`trip_service.event_log: unknown storage "s3"`}},
+		{"mutual TLS without HTTPS", "gateway", nil, []string{"-gateway.api-keys", "console=key", "-tls.mutual"},
+			[]string{"tls.mutual needs tls.cert_file or tls.self_signed", "user_service.url must be https with tls.mutual"}},
+		{"certificate without a key", "gateway", nil, []string{"-gateway.api-keys", "console=key", "-tls.cert-file", "$DIR/cert.pem"},
+			[]string{"tls.cert_file and tls.key_file must be set together", "tls.cert_file: "}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := load(t, test.program, test.files, test.args...)
+			if err == nil {
+				t.Fatal("Load returned no error")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load returned %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// printConfig runs the program with -print-config and returns what it printed
+func printConfig(t *testing.T, program string, args ...string) string {
+	t.Helper()
+
+	printed, err := printInvalidConfig(t, program, args...)
+	if !errors.Is(err, ErrPrinted) {
+		t.Fatalf("Load returned %v, want %v", err, ErrPrinted)
+	}
+	return printed
+}
+
+// printInvalidConfig runs the program with -print-config and returns what it printed and the error from Load
+func printInvalidConfig(t *testing.T, program string, args ...string) (string, error) {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	_, loadErr := load(t, program, nil, append(args, "-print-config")...)
+	os.Stdout = stdout
+	writer.Close()
+
+	printed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(printed), loadErr
+}
+
+func TestPrintConfigMasksAPIKeys(t *testing.T) {
+	t.Setenv("GATEWAY_API_KEYS", "console=console-secret,mobile=mobile-secret")
+	printed := printConfig(t, "gateway", "-gateway.rate-limit", "5")
+
+	for _, want := range []string{
+		`api_keys: {console: '********', mobile: '********'} # env GATEWAY_API_KEYS`,
+		"rate_limit: 5 # flag -gateway.rate-limit",
+		`listen_addr: ":8222" # default`,
+	} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("-print-config printed\n%s\nwant a line with %q", printed, want)
+		}
+	}
+	if strings.Contains(printed, "secret") {
+		t.Errorf("-print-config printed an API key:\n%s", printed)
+	}
+
+	printed = printConfig(t, "console", "-console.api-key", "console-secret")
+	if !strings.Contains(printed, `api_key: "********" # flag -console.api-key`) || strings.Contains(printed, "secret") {
+		t.Errorf("-print-config printed the console's API key:\n%s", printed)
+	}
+}
+
+func TestPrintConfigPrintsInvalidSettings(t *testing.T) {
+	// The gateway has no API keys and an invalid URL, the settings are printed before the errors are reported
+	printed, err := printInvalidConfig(t, "gateway", "-user-service.url", "users:8223")
+
+	if err == nil || errors.Is(err, ErrPrinted) {
+		t.Fatalf("Load returned %v, want the validation errors", err)
+	}
+	for _, want := range []string{"gateway.api_keys must have at least one client=key pair", `user_service.url "users:8223" is not an http or https URL`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load returned %q, want it to contain %q", err, want)
+		}
+	}
+	if !strings.Contains(printed, `url: "users:8223" # flag -user-service.url`) {
+		t.Errorf("-print-config printed\n%s\nwant the invalid user_service.url and its source", printed)
+	}
+}
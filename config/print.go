@@ -0,0 +1,79 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// printSettings writes the settings as a YAML file that can be loaded with -config, each value is
+// commented with where it came from. Secrets are masked.
+func printSettings(w io.Writer, settings []*setting) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	var section *yaml.Node
+	for i, s := range settings {
+		if i == 0 || settings[i-1].section != s.section {
+			section = &yaml.Node{Kind: yaml.MappingNode}
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s.section}, section)
+		}
+		value := s.node()
+		value.LineComment = s.source
+		section.Content = append(section.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s.key}, value)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// node returns the setting's value as a YAML node
+func (s *setting) node() *yaml.Node {
+	switch s.value.Kind() {
+	case reflect.Slice:
+		list := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range s.value.Interface().([]string) {
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+		}
+		return list
+	case reflect.Map:
+		pairs := s.value.Interface().(map[string]string)
+		keys := make([]string, 0, len(pairs))
+		for key := range pairs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		mapping := &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+		for _, key := range keys {
+			value := pairs[key]
+			if s.secret {
+				value = mask(value)
+			}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &yaml.Node{Kind: yaml.ScalarNode, Value: value})
+		}
+		return mapping
+	}
+
+	value := s.format()
+	if s.secret {
+		value = mask(value)
+	}
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if s.value.Kind() == reflect.String || s.value.Type() == durationType {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	return node
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return strings.Repeat("*", 8)
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/server"
+)
+
+// validate checks the settings of the given sections, it reports every problem at once
+func (c *Config) validate(sections []string) error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	for _, section := range sections {
+		switch section {
+		case "gateway":
+			check(validAddr(c.Gateway.ListenAddr), "gateway.listen_addr %q is not a host:port address", c.Gateway.ListenAddr)
+			check(len(c.Gateway.APIKeys) > 0, "gateway.api_keys must have at least one client=key pair, there are no default keys")
+			check(c.Gateway.RateLimit > 0, "gateway.rate_limit must be greater than zero")
+			check(c.Gateway.RateBurst > 0, "gateway.rate_burst must be greater than zero")
+		case "user_service", "trip_service":
+			service := c.UserService
+			if section == "trip_service" {
+				service = c.TripService
+			}
+			check(validAddr(service.ListenAddr), "%s.listen_addr %q is not a host:port address", section, service.ListenAddr)
+			check(validURL(service.URL), "%s.url %q is not an http or https URL", section, service.URL)
+			_, err := service.EventLogPath()
+			check(err == nil, "%s.event_log: %v", section, err)
+			check(!c.TLS.Mutual || strings.HasPrefix(service.URL, "https://"), "%s.url must be https with tls.mutual", section)
+		case "console":
+			check(validURL(c.Console.GatewayURL), "console.gateway_url %q is not an http or https URL", c.Console.GatewayURL)
+			check(c.Console.APIKey != "", "console.api_key must be set to the key the gateway was given for the console")
+			check(c.Console.RefreshInterval >= 0, "console.refresh_interval must not be negative")
+		case "policy":
+			for name, duration := range map[string]time.Duration{
+				"publish_lead_time":   c.Policy.PublishLeadTime,
+				"start_window_before": c.Policy.StartWindowBefore,
+				"start_window_after":  c.Policy.StartWindowAfter,
+				"cancel_cutoff":       c.Policy.CancelCutoff,
+				"enrollment_cutoff":   c.Policy.EnrollmentCutoff,
+				"account_retention":   c.Policy.AccountRetention,
+			} {
+				check(duration >= 0, "policy.%s must not be negative", name)
+			}
+		case "display":
+			_, err := c.Display.Location()
+			check(err == nil, "display.time_zone: %v", err)
+		case "http":
+			for name, duration := range map[string]time.Duration{
+				"read_timeout":     c.HTTP.ReadTimeout,
+				"write_timeout":    c.HTTP.WriteTimeout,
+				"idle_timeout":     c.HTTP.IdleTimeout,
+				"shutdown_timeout": c.HTTP.ShutdownTimeout,
+			} {
+				check(duration > 0, "http.%s must be greater than zero", name)
+			}
+		case "log":
+			var level slog.Level
+			check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level %q is not debug, info, warn or error", c.Log.Level)
+			check(c.Log.Format == "text" || c.Log.Format == "json", "log.format %q is not text or json", c.Log.Format)
+		case "tls":
+			check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+			check(!c.TLS.SelfSigned || c.TLS.CertFile == "", "tls.self_signed cannot be used with tls.cert_file")
+			check(!c.TLS.SelfSigned || c.TLS.DevCertFile != "", "tls.self_signed needs tls.dev_cert_file")
+			check(!c.TLS.Mutual || c.TLS.Enabled(), "tls.mutual needs tls.cert_file or tls.self_signed")
+			check(!c.TLS.Mutual || c.TLS.CAFile != "" || c.TLS.SelfSigned, "tls.mutual needs tls.ca_file to verify certificates")
+			for name, path := range map[string]string{"cert_file": c.TLS.CertFile, "key_file": c.TLS.KeyFile, "ca_file": c.TLS.CAFile} {
+				if path != "" {
+					_, err := os.Stat(path)
+					check(err == nil, "tls.%s: %v", name, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validAddr(addr string) bool {
+	_, _, err := net.SplitHostPort(addr)
+	return err == nil
+}
+
+func validURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// EventLogPath returns the file the event log is stored in, or "" for a log kept in memory only.
+// The storage DSN is file:<path> or memory:, a plain path is read as a file.
+func (s ServiceConfig) EventLogPath() (string, error) {
+	scheme, path, ok := strings.Cut(s.EventLog, ":")
+	if !ok || len(scheme) == 1 { // a plain path, or a Windows drive letter
+		path, scheme = s.EventLog, "file"
+	}
+	switch scheme {
+	case "memory":
+		return "", nil
+	case "file":
+		if path == "" {
+			return "", errors.New("file: needs a path, use memory: for a log kept in memory")
+		}
+		return path, nil
+	default:
+		return "", fmt.Errorf("unknown storage %q, expected file:<path> or memory:", scheme)
+	}
+}
+
+// Location returns the time zone times are shown in, the local zone if none is set
+func (d DisplayConfig) Location() (*time.Location, error) {
+	if d.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(d.TimeZone)
+}
+
+// Server returns the configuration of a server listening on addr
+func (h HTTPConfig) Server(addr string) server.Config {
+	config := server.DefaultConfig(addr)
+	config.ReadTimeout = h.ReadTimeout
+	config.WriteTimeout = h.WriteTimeout
+	config.IdleTimeout = h.IdleTimeout
+	config.ShutdownTimeout = h.ShutdownTimeout
+	if config.ReadHeaderTimeout > config.ReadTimeout {
+		config.ReadHeaderTimeout = config.ReadTimeout
+	}
+	return config
+}
@@ -0,0 +1,237 @@
+package console
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/client"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// Admins export every user or trip to a JSON or CSV file and import the file again, e.g. to load
+// test data after a restart. The console always sends JSON: it reads and writes CSV itself with
+// the model's columns, so a file it exports is what the API exports with format=csv.
+
+// fileFlags are the file an export writes or an import reads, and its format
+type fileFlags struct {
+	file, format string
+}
+
+func (ff *fileFlags) define(f *flag.FlagSet, usage string) {
+	f.StringVar(&ff.file, "file", "", usage)
+	f.StringVar(&ff.format, "format", "", "json or csv, by default csv for a .csv file and json otherwise")
+}
+
+// formatOf returns the format of the file, from its extension unless --format is given
+func (ff fileFlags) formatOf() (string, error) {
+	if ff.format != "" {
+		return ff.format, oneOf("format", ff.format, "json", "csv")
+	}
+	if strings.EqualFold(filepath.Ext(ff.file), ".csv") {
+		return "csv", nil
+	}
+	return "json", nil
+}
+
+func (c *Console) exportUsersCommand(f *flag.FlagSet, args []string) error {
+	var ff fileFlags
+	ff.define(f, "file to write the users to, the output by default")
+	if err := parse(f, args); err != nil {
+		return err
+	}
+
+	users, err := c.api.ExportUsers(context.Background(), client.ExportUsersParams{})
+	if err != nil {
+		return err
+	}
+	return c.writeExport(ff, "users", len(users), users, model.UsersCSV(users...))
+}
+
+func (c *Console) exportTripsCommand(f *flag.FlagSet, args []string) error {
+	var ff fileFlags
+	ff.define(f, "file to write the trips to, the output by default")
+	if err := parse(f, args); err != nil {
+		return err
+	}
+
+	trips, err := c.api.ExportTrips(context.Background(), client.ExportTripsParams{})
+	if err != nil {
+		return err
+	}
+	return c.writeExport(ff, "trips", len(trips), trips, model.TripsCSV(trips...))
+}
+
+// writeExport writes the records to the file as JSON, or as CSV, or to the output without a file
+func (c *Console) writeExport(ff fileFlags, what string, count int, v interface{}, records [][]string) error {
+	format, err := ff.formatOf()
+	if err != nil {
+		return err
+	}
+
+	w := c.out
+	if ff.file != "" {
+		file, err := os.Create(ff.file)
+		if err != nil {
+			return commandError{fmt.Sprintf("cannot write the %s: %v", what, err), ExitFailed}
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if format == "csv" {
+		err = csv.NewWriter(w).WriteAll(records)
+	} else {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(v)
+	}
+	if err != nil {
+		return commandError{fmt.Sprintf("cannot write the %s: %v", what, err), ExitFailed}
+	}
+
+	if w != c.out {
+		fmt.Fprintf(c.out, "Exported %d %s to %s\n", count, what, ff.file)
+	}
+	return nil
+}
+
+// importFlags are the flags of an import
+type importFlags struct {
+	fileFlags
+	onConflict string
+	dryRun     bool
+}
+
+func (flags *importFlags) define(f *flag.FlagSet, what string) {
+	flags.fileFlags.define(f, "file to read the "+what+" from, - for the input")
+	f.StringVar(&flags.onConflict, "on-conflict", "fail", "what to do with an ID that is taken: fail, skip or overwrite")
+	f.BoolVar(&flags.dryRun, "dry-run", false, "check the "+what+" without importing them")
+}
+
+// parse parses the flags of an import, which needs a file
+func (flags *importFlags) parse(f *flag.FlagSet, args []string) error {
+	if err := parse(f, args, "file"); err != nil {
+		return err
+	}
+	return oneOf("on-conflict", flags.onConflict, "fail", "skip", "overwrite")
+}
+
+func (c *Console) importUsersCommand(f *flag.FlagSet, args []string) error {
+	var flags importFlags
+	flags.define(f, "users")
+	if err := flags.parse(f, args); err != nil {
+		return err
+	}
+
+	users, err := readImport(c, "users", flags, model.UserColumns, model.CSVRow.User)
+	if err != nil {
+		return err
+	}
+	report, err := c.api.ImportUsers(context.Background(), users, client.ImportUsersParams{
+		OnConflict: flags.onConflict,
+		DryRun:     strconv.FormatBool(flags.dryRun),
+	})
+	return c.printImport("users", report, err)
+}
+
+func (c *Console) importTripsCommand(f *flag.FlagSet, args []string) error {
+	var flags importFlags
+	flags.define(f, "trips")
+	if err := flags.parse(f, args); err != nil {
+		return err
+	}
+
+	trips, err := readImport(c, "trips", flags, model.TripColumns, model.CSVRow.Trip)
+	if err != nil {
+		return err
+	}
+	report, err := c.api.ImportTrips(context.Background(), trips, client.ImportTripsParams{
+		OnConflict: flags.onConflict,
+		DryRun:     strconv.FormatBool(flags.dryRun),
+	})
+	return c.printImport("trips", report, err)
+}
+
+// readImport reads the records of an import from its file. CSV rows that cannot be read are
+// reported like the rows the API rejects, and nothing is sent.
+func readImport[T any](c *Console, what string, flags importFlags, columns []string, fromCSV func(model.CSVRow) (T, error)) ([]T, error) {
+	format, err := flags.formatOf()
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = c.input
+	if flags.file != "-" {
+		file, err := os.Open(flags.file)
+		if err != nil {
+			return nil, commandError{fmt.Sprintf("cannot read the %s: %v", what, err), ExitUsage}
+		}
+		defer file.Close()
+		r = file
+	}
+
+	records := []T{}
+	if format == "json" {
+		if err := json.NewDecoder(r).Decode(&records); err != nil {
+			return nil, commandError{fmt.Sprintf("cannot read the %s from %s: %v", what, flags.file, err), ExitUsage}
+		}
+		return records, nil
+	}
+
+	rows, err := model.ReadCSV(r, columns)
+	if err != nil {
+		return nil, commandError{fmt.Sprintf("cannot read the %s from %s: %v", what, flags.file, err), ExitUsage}
+	}
+	var failed []model.ImportRow
+	for i, row := range rows {
+		record, err := fromCSV(row)
+		if err != nil {
+			failed = append(failed, model.ImportRow{Row: i + 1, ID: row["id"], Result: "failed", Error: err.Error()})
+		}
+		records = append(records, record)
+	}
+	if len(failed) > 0 {
+		c.printImportRows(failed)
+		return nil, commandError{fmt.Sprintf("No %s were imported, %d of %d rows could not be read", what, len(failed), len(rows)), ExitRejected}
+	}
+	return records, nil
+}
+
+// printImport prints the result of each row of an import and what was imported
+func (c *Console) printImport(what string, report model.ImportReport, err error) error {
+	// The API answers an import with failed rows with an error whose message is the report
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && json.Unmarshal([]byte(apiErr.Message), &report) == nil {
+		c.printImportRows(report.Rows)
+		return commandError{fmt.Sprintf("No %s were imported, %d of %d rows failed", what, report.Failed, len(report.Rows)), exitCode(err)}
+	}
+	if err != nil {
+		return err
+	}
+
+	c.printImportRows(report.Rows)
+	if report.DryRun {
+		fmt.Fprintf(c.out, "Dry run, nothing was imported: %d %s to create, %d to update, %d to skip\n",
+			report.Created, what, report.Updated, report.Skipped)
+	} else {
+		fmt.Fprintf(c.out, "Imported the %s: %d created, %d updated, %d skipped\n", what, report.Created, report.Updated, report.Skipped)
+	}
+	return nil
+}
+
+func (c *Console) printImportRows(rows []model.ImportRow) {
+	var table [][]string
+	for _, row := range rows {
+		table = append(table, []string{strconv.Itoa(row.Row), row.ID, row.Result, row.Error})
+	}
+	c.printTable([]string{"ROW", "ID", "RESULT", "ERROR"}, table)
+}
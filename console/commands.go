@@ -0,0 +1,674 @@
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/client"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// Exit codes of the commands, so scripts can tell why a command failed
+const (
+	ExitOK          = 0 // the command succeeded
+	ExitFailed      = 1 // the request could not be sent, or the server failed
+	ExitUsage       = 2 // the command line is invalid
+	ExitNotFound    = 3 // the user, trip or recurring trip does not exist
+	ExitRejected    = 4 // the request breaks the rules, e.g. the trip is full or the user already exists
+	ExitDenied      = 5 // the API key is missing or not allowed to make the request
+	ExitUnavailable = 6 // the server is busy or not ready, the command can be retried later
+)
+
+// commandError is a command that cannot be run, with its exit code
+type commandError struct {
+	message string
+	exit    int
+}
+
+func (e commandError) Error() string { return e.message }
+
+// usageError is a command line that cannot be run
+func usageError(format string, args ...interface{}) error {
+	return commandError{fmt.Sprintf(format, args...), ExitUsage}
+}
+
+// command is one of the non-interactive commands, such as "trips enroll"
+type command struct {
+	resource, verb string
+	summary        string
+	run            func(c *Console, f *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"users", "list", "list the users", (*Console).listUsersCommand},
+	{"users", "get", "show a user", (*Console).getUserCommand},
+	{"users", "create", "create a user", (*Console).createUserCommand},
+	{"users", "update", "change the given details of a user", (*Console).updateUserCommand},
+	{"users", "delete", "delete a user and cancel their trips", (*Console).deleteUserCommand},
+	{"users", "become-owner", "make a user a car owner", (*Console).becomeOwnerCommand},
+	{"users", "become-passenger", "make a car owner a passenger", (*Console).becomePassengerCommand},
+	{"users", "notifications", "list a user's notifications", (*Console).notificationsCommand},
+	{"users", "export", "write every user to a JSON or CSV file (admins only)", (*Console).exportUsersCommand},
+	{"users", "import", "check and import the users of a file (admins only)", (*Console).importUsersCommand},
+	{"trips", "list", "list the trips", (*Console).listTripsCommand},
+	{"trips", "get", "show a trip", (*Console).getTripCommand},
+	{"trips", "create", "publish a trip", (*Console).createTripCommand},
+	{"trips", "enroll", "enroll a passenger in a trip", (*Console).enrollCommand},
+	{"trips", "start", "start a trip as its car owner", (*Console).startCommand},
+	{"trips", "cancel", "cancel a trip", (*Console).cancelCommand},
+	{"trips", "export", "write every trip to a JSON or CSV file (admins only)", (*Console).exportTripsCommand},
+	{"trips", "import", "check and import the trips of a file (admins only)", (*Console).importTripsCommand},
+	{"series", "list", "list the recurring trips", (*Console).listSeriesCommand},
+	{"series", "get", "show a recurring trip", (*Console).getSeriesCommand},
+	{"series", "create", "publish a recurring trip", (*Console).createSeriesCommand},
+	{"series", "update", "change the given details of a recurring trip", (*Console).updateSeriesCommand},
+	{"series", "delete", "delete a recurring trip and cancel its upcoming trips", (*Console).deleteSeriesCommand},
+	{"series", "skip", "skip a date of a recurring trip", (*Console).skipCommand},
+	{"policy", "get", "show the timing rules for trips", (*Console).policyCommand},
+}
+
+// RunCommand runs a command such as "trips enroll --trip T1 --user U2" instead of the menu, and returns
+// the exit code. Results are written to the output, errors to ErrOutput.
+func (c *Console) RunCommand(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		c.printUsage(c.out)
+		return ExitOK
+	}
+
+	for _, cmd := range commands {
+		if len(args) < 2 || cmd.resource != args[0] || cmd.verb != args[1] {
+			continue
+		}
+
+		f := flag.NewFlagSet("carpool "+cmd.resource+" "+cmd.verb, flag.ContinueOnError)
+		f.SetOutput(c.errOutput())
+		err := cmd.run(c, f, args[2:])
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		if err != nil {
+			c.printError(err)
+		}
+		return exitCode(err)
+	}
+
+	fmt.Fprintf(c.errOutput(), "Error - Unknown command %q\n", strings.Join(args[:min(2, len(args))], " "))
+	c.printUsage(c.errOutput())
+	return ExitUsage
+}
+
+func (c *Console) printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: carpool [configuration flags] <resource> <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command the console shows its menu. The commands are:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.resource, cmd.verb, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "carpool <resource> <command> -h" for the flags of a command.`)
+}
+
+// exitCode returns the exit code for the error a command returned
+func exitCode(err error) int {
+	var cmdErr commandError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &cmdErr):
+		return cmdErr.exit
+	case errors.Is(err, client.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, client.ErrBadRequest), errors.Is(err, client.ErrConflict):
+		return ExitRejected
+	case errors.Is(err, client.ErrUnauthorized), errors.Is(err, client.ErrForbidden):
+		return ExitDenied
+	case errors.Is(err, client.ErrTooManyRequests), errors.Is(err, client.ErrUnavailable):
+		return ExitUnavailable
+	default:
+		return ExitFailed
+	}
+}
+
+// printError prints the server's explanation of an error, or why the request failed
+func (c *Console) printError(err error) {
+	var apiErr *client.Error
+	var cmdErr commandError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Message != "":
+		fmt.Fprintln(c.errOutput(), apiErr.Message)
+	case errors.As(err, &cmdErr):
+		fmt.Fprintln(c.errOutput(), "Error -", cmdErr.message)
+	default:
+		fmt.Fprintln(c.errOutput(), "Error executing request:", err)
+	}
+}
+
+func (c *Console) errOutput() io.Writer {
+	if c.ErrOutput != nil {
+		return c.ErrOutput
+	}
+	return c.out
+}
+
+// parse parses the flags of a command, the named flags must not be empty
+func parse(f *flag.FlagSet, args []string, required ...string) error {
+	if err := f.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError("%v", err)
+	}
+	if f.NArg() > 0 {
+		return usageError("unexpected argument %q", f.Arg(0))
+	}
+	for _, name := range required {
+		if f.Lookup(name).Value.String() == "" {
+			return usageError("the --%s flag is required", name)
+		}
+	}
+	return nil
+}
+
+// printMessage prints the server's answer to a change
+func (c *Console) printMessage(message string, err error) error {
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, message)
+	return nil
+}
+
+// printJSON prints v as indented JSON
+func (c *Console) printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, string(data))
+	return nil
+}
+
+// printTable prints the rows in aligned columns, under the header if there is one
+func (c *Console) printTable(header []string, rows [][]string) {
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// sortedKeys returns the IDs of a listing in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Console) formatTime(t time.Time) string {
+	return t.In(c.DisplayZone).Format("2006-01-02 15:04 MST")
+}
+
+// userFlags defines the flags of a user profile, which start as the user's details
+func userFlags(f *flag.FlagSet, userID *string, user *model.User) {
+	f.StringVar(userID, "user", *userID, "ID of the user")
+	f.StringVar(&user.FirstName, "first-name", user.FirstName, "first name")
+	f.StringVar(&user.LastName, "last-name", user.LastName, "last name")
+	f.StringVar(&user.MobileNumber, "mobile", user.MobileNumber, "mobile number")
+	f.StringVar(&user.Email, "email", user.Email, "email address")
+	f.BoolVar(&user.IsCarOwner, "car-owner", user.IsCarOwner, "the user is also a car owner")
+	f.StringVar(&user.DriverLicense, "license", user.DriverLicense, "driver's license number of a car owner")
+	f.StringVar(&user.CarPlateNumber, "plate", user.CarPlateNumber, "car plate number of a car owner")
+}
+
+func (c *Console) listUsersCommand(f *flag.FlagSet, args []string) error {
+	output := outputFlags(f, "users")
+	var filter userFilter
+	filter.define(f)
+	if err := parse(f, args); err != nil {
+		return err
+	}
+
+	users, err := c.api.ListUsers(context.Background())
+	if err != nil {
+		return err
+	}
+	listed, err := filter.apply(users)
+	if err != nil {
+		return err
+	}
+	return c.printOutput(output, listed, model.UsersCSV(listed...), func() { c.printUsers(listed) })
+}
+
+func (c *Console) getUserCommand(f *flag.FlagSet, args []string) error {
+	userID := f.String("user", "", "ID of the user")
+	output := outputFlags(f, "user")
+	if err := parse(f, args, "user"); err != nil {
+		return err
+	}
+
+	user, err := c.api.GetUser(context.Background(), *userID)
+	if err != nil {
+		return err
+	}
+	return c.printOutput(output, user, model.UsersCSV(user), func() { c.printUser(user) })
+}
+
+func (c *Console) printUser(user model.User) {
+
+	rows := [][]string{
+		{"User:", user.ID},
+		{"Name:", user.FirstName + " " + user.LastName},
+		{"Mobile:", user.MobileNumber},
+		{"Email:", user.Email},
+		{"Car owner:", fmt.Sprint(user.IsCarOwner)},
+	}
+	if user.IsCarOwner {
+		rows = append(rows, []string{"License:", user.DriverLicense}, []string{"Plate:", user.CarPlateNumber})
+	}
+	rows = append(rows, []string{"Created:", c.formatTime(user.CreatedAt)})
+	c.printTable(nil, rows)
+}
+
+func (c *Console) createUserCommand(f *flag.FlagSet, args []string) error {
+	var userID string
+	var user model.User
+	userFlags(f, &userID, &user)
+	if err := parse(f, args, "user", "first-name", "last-name", "mobile", "email"); err != nil {
+		return err
+	}
+
+	// Creating a user that exists would replace them, as the menu does the command refuses
+	if _, err := c.api.GetUser(context.Background(), userID); !errors.Is(err, client.ErrNotFound) {
+		if err == nil {
+			err = commandError{"User already exists", ExitRejected}
+		}
+		return err
+	}
+
+	return c.printMessage(c.api.CreateUser(context.Background(), userID, user))
+}
+
+func (c *Console) updateUserCommand(f *flag.FlagSet, args []string) error {
+	var userID string
+	userFlags(f, &userID, &model.User{})
+	if err := parse(f, args, "user"); err != nil {
+		return err
+	}
+
+	// The details that are not given stay as they are
+	user, err := c.api.GetUser(context.Background(), userID)
+	if err != nil {
+		return err
+	}
+	update := flag.NewFlagSet(f.Name(), flag.ContinueOnError)
+	userFlags(update, &userID, &user)
+	update.Parse(args)
+
+	return c.printMessage(c.api.UpdateUser(context.Background(), userID, user))
+}
+
+func (c *Console) deleteUserCommand(f *flag.FlagSet, args []string) error {
+	userID := f.String("user", "", "ID of the user")
+	if err := parse(f, args, "user"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.DeleteUser(context.Background(), *userID))
+}
+
+func (c *Console) becomeOwnerCommand(f *flag.FlagSet, args []string) error {
+	userID := f.String("user", "", "ID of the user")
+	license := f.String("license", "", "driver's license number")
+	plate := f.String("plate", "", "car plate number")
+	if err := parse(f, args, "user", "license", "plate"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.BecomeCarOwner(context.Background(), *userID, client.BecomeCarOwnerRequest{
+		DriverLicense:  *license,
+		CarPlateNumber: *plate,
+	}))
+}
+
+func (c *Console) becomePassengerCommand(f *flag.FlagSet, args []string) error {
+	userID := f.String("user", "", "ID of the car owner")
+	if err := parse(f, args, "user"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.BecomePassenger(context.Background(), *userID))
+}
+
+func (c *Console) notificationsCommand(f *flag.FlagSet, args []string) error {
+	userID := f.String("user", "", "ID of the user")
+	output := outputFlags(f, "notifications")
+	if err := parse(f, args, "user"); err != nil {
+		return err
+	}
+
+	notifications, err := c.api.ListNotifications(context.Background(), *userID)
+	if err != nil {
+		return err
+	}
+	return c.printOutput(output, notifications, notificationsCSV(notifications), func() { c.printNotifications(notifications) })
+}
+
+// printNotifications prints the notifications in a table
+func (c *Console) printNotifications(notifications []model.Notification) {
+	var rows [][]string
+	for _, notification := range notifications {
+		rows = append(rows, []string{c.formatTime(notification.CreatedAt), notification.TripID, notification.Message})
+	}
+	c.printTable([]string{"TIME", "TRIP", "MESSAGE"}, rows)
+}
+
+// tripStatus describes where a trip is in its life
+func tripStatus(trip model.Trip) string {
+	switch {
+	case trip.Cancelled:
+		return "cancelled"
+	case trip.Started:
+		return "started"
+	default:
+		return "scheduled"
+	}
+}
+
+func (c *Console) listTripsCommand(f *flag.FlagSet, args []string) error {
+	output := outputFlags(f, "trips")
+	var filter tripFilter
+	filter.define(f)
+	if err := parse(f, args); err != nil {
+		return err
+	}
+
+	trips, err := c.api.ListTrips(context.Background())
+	if err != nil {
+		return err
+	}
+	listed, err := filter.apply(trips, c.Clock.Now(), c.DisplayZone)
+	if err != nil {
+		return err
+	}
+	return c.printOutput(output, listed, model.TripsCSV(listed...), func() { c.printTrips(listed) })
+}
+
+func (c *Console) getTripCommand(f *flag.FlagSet, args []string) error {
+	tripID := f.String("trip", "", "ID of the trip")
+	output := outputFlags(f, "trip")
+	if err := parse(f, args, "trip"); err != nil {
+		return err
+	}
+
+	trip, err := c.api.GetTrip(context.Background(), *tripID)
+	if err != nil {
+		return err
+	}
+	return c.printOutput(output, trip, model.TripsCSV(trip), func() { c.printTrip(trip) })
+}
+
+func (c *Console) printTrip(trip model.Trip) {
+
+	rows := [][]string{
+		{"Trip:", trip.ID},
+		{"Car owner:", trip.CarOwnerID},
+		{"Pickup:", trip.PickupLocation},
+	}
+	if trip.AltPickupLocation != "" {
+		rows = append(rows, []string{"Alternative pickup:", trip.AltPickupLocation})
+	}
+	rows = append(rows,
+		[]string{"Destination:", trip.Destination},
+		[]string{"Start time:", c.formatTime(trip.StartTime)},
+		[]string{"Seats left:", fmt.Sprintf("%d of %d", trip.AvailableSeats, trip.TotalSeats)},
+		[]string{"Passengers:", strings.Join(trip.EnrolledPassengers, ", ")},
+		[]string{"Status:", tripStatus(trip)},
+	)
+	if trip.SeriesID != "" {
+		rows = append(rows, []string{"Recurring trip:", trip.SeriesID})
+	}
+	c.printTable(nil, rows)
+}
+
+func (c *Console) createTripCommand(f *flag.FlagSet, args []string) error {
+	tripID := f.String("trip", "", "ID of the trip")
+	carOwnerID := f.String("owner", "", "ID of the car owner")
+	pickup := f.String("pickup", "", "pickup location")
+	altPickup := f.String("alt-pickup", "", "alternative pickup location")
+	start := f.String("start", "", "start time, e.g. '2006-01-02 15:04', 'tomorrow 08:15 Asia/Singapore' or RFC 3339")
+	destination := f.String("destination", "", "destination")
+	seats := f.Int("seats", 0, "total seats in the car")
+	if err := parse(f, args, "trip", "owner", "pickup", "start", "destination"); err != nil {
+		return err
+	}
+
+	if _, err := c.api.GetTrip(context.Background(), *tripID); !errors.Is(err, client.ErrNotFound) {
+		if err == nil {
+			err = commandError{"Trip already exists", ExitRejected}
+		}
+		return err
+	}
+
+	startTime, err := time.Parse(time.RFC3339, *start)
+	if err != nil {
+		if startTime, err = parseStartTime(*start, c.Clock.Now(), c.DisplayZone); err != nil {
+			return usageError("invalid --start: %v", err)
+		}
+	}
+
+	return c.printMessage(c.api.CreateTrip(context.Background(), *tripID, model.Trip{
+		CarOwnerID:        *carOwnerID,
+		PickupLocation:    *pickup,
+		AltPickupLocation: *altPickup,
+		StartTime:         startTime.UTC(),
+		Destination:       *destination,
+		TotalSeats:        *seats,
+	}))
+}
+
+func (c *Console) enrollCommand(f *flag.FlagSet, args []string) error {
+	tripID := f.String("trip", "", "ID of the trip")
+	userID := f.String("user", "", "ID of the passenger")
+	if err := parse(f, args, "trip", "user"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.EnrollPassenger(context.Background(), *tripID, client.EnrollPassengerRequest{UserID: *userID}))
+}
+
+func (c *Console) startCommand(f *flag.FlagSet, args []string) error {
+	tripID := f.String("trip", "", "ID of the trip")
+	carOwnerID := f.String("owner", "", "ID of the car owner starting the trip")
+	if err := parse(f, args, "trip", "owner"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.StartTrip(context.Background(), *tripID, *carOwnerID))
+}
+
+func (c *Console) cancelCommand(f *flag.FlagSet, args []string) error {
+	tripID := f.String("trip", "", "ID of the trip")
+	if err := parse(f, args, "trip"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.DeleteTrip(context.Background(), *tripID))
+}
+
+// daysFlag is the comma-separated days a recurring trip runs on
+type daysFlag []string
+
+func (d *daysFlag) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *daysFlag) Set(days string) error {
+	*d = strings.Split(days, ",")
+	return nil
+}
+
+// seriesFlags defines the flags of a recurring trip, which start as its details
+func seriesFlags(f *flag.FlagSet, seriesID *string, series *model.TripSeries) {
+	f.StringVar(seriesID, "series", *seriesID, "ID of the recurring trip")
+	f.StringVar(&series.CarOwnerID, "owner", series.CarOwnerID, "ID of the car owner")
+	f.StringVar(&series.PickupLocation, "pickup", series.PickupLocation, "pickup location")
+	f.StringVar(&series.AltPickupLocation, "alt-pickup", series.AltPickupLocation, "alternative pickup location")
+	f.StringVar(&series.Destination, "destination", series.Destination, "destination")
+	f.StringVar(&series.TimeOfDay, "time", series.TimeOfDay, "start time, e.g. 08:15")
+	f.StringVar(&series.TimeZone, "time-zone", series.TimeZone, "time zone of the start time, e.g. Asia/Singapore")
+	f.Var((*daysFlag)(&series.Days), "days", "days the trip runs on, e.g. weekdays or MO,WE,FR")
+	f.StringVar(&series.Until, "until", series.Until, "last date of the recurring trip, e.g. 2006-01-02")
+	f.IntVar(&series.TotalSeats, "seats", series.TotalSeats, "total seats in the car")
+}
+
+func (c *Console) listSeriesCommand(f *flag.FlagSet, args []string) error {
+	output := outputFlags(f, "recurring trips")
+	if err := parse(f, args); err != nil {
+		return err
+	}
+
+	series, err := c.api.ListSeries(context.Background())
+	if err != nil {
+		return err
+	}
+	listed := make([]model.TripSeries, 0, len(series))
+	for _, id := range sortedKeys(series) {
+		s := series[id]
+		s.ID = id
+		listed = append(listed, s)
+	}
+	return c.printOutput(output, listed, seriesCSV(listed...), func() {
+		var rows [][]string
+		for _, s := range listed {
+			rows = append(rows, []string{s.ID, s.CarOwnerID, s.PickupLocation, s.Destination, strings.TrimSpace(s.TimeOfDay + " " + s.TimeZone),
+				strings.Join(s.Days, ","), s.Until, fmt.Sprint(s.TotalSeats)})
+		}
+		c.printTable([]string{"ID", "OWNER", "PICKUP", "DESTINATION", "TIME", "DAYS", "UNTIL", "SEATS"}, rows)
+	})
+}
+
+func (c *Console) getSeriesCommand(f *flag.FlagSet, args []string) error {
+	seriesID := f.String("series", "", "ID of the recurring trip")
+	output := outputFlags(f, "recurring trip")
+	if err := parse(f, args, "series"); err != nil {
+		return err
+	}
+
+	series, err := c.api.GetSeries(context.Background(), *seriesID)
+	if err != nil {
+		return err
+	}
+	return c.printOutput(output, series, seriesCSV(series), func() { c.printSeries(series) })
+}
+
+func (c *Console) printSeries(series model.TripSeries) {
+
+	rows := [][]string{
+		{"Recurring trip:", series.ID},
+		{"Car owner:", series.CarOwnerID},
+		{"Pickup:", series.PickupLocation},
+	}
+	if series.AltPickupLocation != "" {
+		rows = append(rows, []string{"Alternative pickup:", series.AltPickupLocation})
+	}
+	rows = append(rows,
+		[]string{"Destination:", series.Destination},
+		[]string{"Time:", strings.TrimSpace(series.TimeOfDay + " " + series.TimeZone)},
+		[]string{"Days:", strings.Join(series.Days, ",")},
+	)
+	if series.Until != "" {
+		rows = append(rows, []string{"Until:", series.Until})
+	}
+	if len(series.SkippedDates) > 0 {
+		rows = append(rows, []string{"Skipped:", strings.Join(series.SkippedDates, ", ")})
+	}
+	rows = append(rows, []string{"Seats:", fmt.Sprint(series.TotalSeats)})
+	c.printTable(nil, rows)
+}
+
+func (c *Console) createSeriesCommand(f *flag.FlagSet, args []string) error {
+	var seriesID string
+	var series model.TripSeries
+	seriesFlags(f, &seriesID, &series)
+	if err := parse(f, args, "series", "owner", "pickup", "destination", "time", "days"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.CreateSeries(context.Background(), seriesID, series))
+}
+
+func (c *Console) updateSeriesCommand(f *flag.FlagSet, args []string) error {
+	var seriesID string
+	seriesFlags(f, &seriesID, &model.TripSeries{})
+	if err := parse(f, args, "series"); err != nil {
+		return err
+	}
+
+	// The details that are not given stay as they are
+	series, err := c.api.GetSeries(context.Background(), seriesID)
+	if err != nil {
+		return err
+	}
+	update := flag.NewFlagSet(f.Name(), flag.ContinueOnError)
+	seriesFlags(update, &seriesID, &series)
+	update.Parse(args)
+
+	return c.printMessage(c.api.UpdateSeries(context.Background(), seriesID, series))
+}
+
+func (c *Console) deleteSeriesCommand(f *flag.FlagSet, args []string) error {
+	seriesID := f.String("series", "", "ID of the recurring trip")
+	if err := parse(f, args, "series"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.DeleteSeries(context.Background(), *seriesID))
+}
+
+func (c *Console) skipCommand(f *flag.FlagSet, args []string) error {
+	seriesID := f.String("series", "", "ID of the recurring trip")
+	date := f.String("date", "", "date to skip, e.g. 2006-01-02")
+	if err := parse(f, args, "series", "date"); err != nil {
+		return err
+	}
+
+	return c.printMessage(c.api.SkipSeriesDate(context.Background(), *seriesID, client.SkipSeriesDateRequest{Date: *date}))
+}
+
+func (c *Console) policyCommand(f *flag.FlagSet, args []string) error {
+	output := outputFlags(f, "policy")
+	if err := parse(f, args); err != nil {
+		return err
+	}
+
+	policy, err := c.api.GetPolicy(context.Background())
+	if err != nil {
+		return err
+	}
+	return c.printOutput(output, policy, nil, func() { c.printPolicy(policy) })
+}
+
+func (c *Console) printPolicy(policy model.Policy) {
+	c.printTable(nil, [][]string{
+		{"Publish:", "at least " + model.FormatDuration(policy.PublishLeadTime) + " before the start"},
+		{"Enroll:", "until " + model.FormatDuration(policy.EnrollmentCutoff) + " before the start"},
+		{"Cancel:", "until " + model.FormatDuration(policy.CancelCutoff) + " before the start"},
+		{"Start:", "from " + model.FormatDuration(policy.StartWindowBefore) + " before until " + model.FormatDuration(policy.StartWindowAfter) + " after the start"},
+	})
+}
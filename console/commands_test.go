@@ -0,0 +1,292 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/client"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// commandLine splits a command line on spaces, except within single quotes
+func commandLine(line string) []string {
+	var args []string
+	for i, part := range strings.Split(line, "'") {
+		if i%2 == 1 {
+			args = append(args, part)
+		} else {
+			args = append(args, strings.Fields(part)...)
+		}
+	}
+	return args
+}
+
+// TestCommands runs the commands one after the other against the same API, so each step sees
+// the changes of the steps before it. The output and the errors must contain out and errOut.
+func TestCommands(t *testing.T) {
+	var out, errOut bytes.Buffer
+	c, _ := newTestConsole(t, "", &out)
+	c.ErrOutput = &errOut
+
+	const trip = "--pickup 'Ang Mo Kio' --destination 'Ngee Ann Polytechnic'"
+	steps := []struct {
+		line   string
+		exit   int
+		out    string
+		errOut string
+	}{
+		{"users create --user O1 --first-name John --last-name Lim --mobile 98765432 --email john@example.com --car-owner --license s1234567a --plate 'sba 1234 a'",
+			ExitOK, "User POST O1 successfully\n", ""},
+		{"users create --user U2 --first-name Jane --last-name Tan --mobile 91234567 --email jane@example.com", ExitOK, "User POST U2 successfully\n", ""},
+		{"users create --user U2 --first-name Jane --last-name Tan --mobile 91234567 --email jane@example.com", ExitRejected, "", "Error - User already exists\n"},
+		{"users create --user U3 --first-name Ali", ExitUsage, "", "Error - the --last-name flag is required\n"},
+		{"users update --user U2 --mobile 99998888", ExitOK, "User PUT U2 successfully\n", ""},
+		{"users get --user U2 --json", ExitOK, `"first_name": "Jane",`, ""},
+		{"users get --user U2", ExitOK, "Mobile:     99998888\n", ""},
+		{"users get --user U9", ExitNotFound, "", "Invalid user ID\n"},
+		{"users list", ExitOK, "ID  NAME      CAR OWNER  MOBILE    EMAIL\nO1  John Lim  yes        98765432  john@example.com\nU2  Jane Tan  no         99998888  jane@example.com\n", ""},
+
+		{"trips create --trip T1 --owner O1 --start 08:30 --seats 1 " + trip, ExitOK, "Trip POST T1 successfully\n", ""},
+		{"trips create --trip T1 --owner O1 --start 09:30 --seats 3 " + trip, ExitRejected, "", "Error - Trip already exists\n"},
+		{"trips create --trip T2 --owner U2 --start 'tomorrow 08:15' --seats 3 " + trip, ExitRejected, "", "Error - Only car owners can create trips\n"},
+		{"trips create --trip T2 --owner O1 --start 08:29 --seats 3 " + trip, ExitRejected, "", "Error - Trips must be scheduled at least 30m in the future\n"},
+		{"trips create --trip T2 --owner O1 --start soon --seats 3 " + trip, ExitUsage, "", "Error - invalid --start: "},
+		{"trips enroll --trip T1 --user U2", ExitOK, "User U2 enrolled in trip T1 successfully\n", ""},
+		{"trips enroll --trip T1 --user O1", ExitRejected, "", "Error - No seats left on this trip\n"},
+		{"trips enroll --trip T9 --user U2", ExitNotFound, "", "Invalid trip ID\n"},
+		{"trips enroll --trip T1 --user U2 now", ExitUsage, "", `Error - unexpected argument "now"`},
+		{"trips start --trip T1 --owner U2", ExitDenied, "", "Error - Only the car owner can start the trip\n"},
+		{"trips start --trip T1 --owner O1", ExitOK, "Trip T1 started successfully\n", ""},
+		{"trips cancel --trip T1", ExitRejected, "", "Error - Trip is already started and cannot be canceled\n"},
+		{"trips get --trip T1", ExitOK, "Passengers:   U2\nStatus:       started\n", ""},
+		{"trips list", ExitOK, "ID  OWNER  ROUTE                              START                 SEATS  STATUS\n" +
+			"T1  O1     Ang Mo Kio → Ngee Ann Polytechnic  2024-01-15 08:30 UTC  0/1    started\n", ""},
+		{"trips list --json", ExitOK, `"enrolled_passengers": [` + "\n" + `      "U2"`, ""},
+
+		{"series create --series S1 --owner O1 --time 08:15 --days MO,WE,FR --seats 3 " + trip, ExitOK, "Series POST S1 successfully\n", ""},
+		{"series create --series S1 --owner O1 --time 08:15 --days MO,WE,FR --seats 3 " + trip, ExitRejected, "", "Error - Series already exists\n"},
+		{"series update --series S1 --time 08:45", ExitOK, "Series PUT S1 successfully\n", ""},
+		{"series skip --series S1 --date 2024-01-17", ExitOK, "Occurrence 2024-01-17 of series S1 skipped\n", ""},
+		{"series get --series S1", ExitOK, "Time:            08:45\nDays:            MO,WE,FR\nSkipped:         2024-01-17\n", ""},
+		{"series list", ExitOK, "S1  O1     Ang Mo Kio  Ngee Ann Polytechnic  08:45  MO,WE,FR", ""},
+
+		{"users become-passenger --user U2", ExitRejected, "", "Error - User is not a car owner\n"},
+		{"users become-owner --user U2 --license T7654321B --plate SGX88", ExitOK, "User U2 is now a car owner\n", ""},
+		{"users notifications --user U2 --json", ExitOK, "[]\n", ""},
+		{"users delete --user U2", ExitRejected, "", "Error - Account cannot be deleted before 1 year\n"},
+		{"policy get", ExitOK, "Publish:  at least 30m before the start\n", ""},
+
+		{"", ExitOK, "Usage: carpool [configuration flags] <resource> <command> [flags]\n", ""},
+		{"trips fly --trip T1", ExitUsage, "", `Error - Unknown command "trips fly"`},
+		{"trips list -h", ExitOK, "", "Usage of carpool trips list:\n"},
+	}
+	for _, step := range steps {
+		out.Reset()
+		errOut.Reset()
+		exit := c.RunCommand(commandLine(step.line))
+		if exit != step.exit || !strings.Contains(out.String(), step.out) || !strings.Contains(errOut.String(), step.errOut) {
+			t.Errorf("carpool %s exited with %d, want %d\noutput:\n%s\nwant:\n%s\nerrors:\n%s\nwant:\n%s",
+				step.line, exit, step.exit, out.String(), step.out, errOut.String(), step.errOut)
+		}
+		if step.errOut == "" && errOut.Len() > 0 {
+			t.Errorf("carpool %s printed the errors %q", step.line, errOut.String())
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitOK},
+		{usageError("the --%s flag is required", "trip"), ExitUsage},
+		{commandError{"Trip already exists", ExitRejected}, ExitRejected},
+		{&client.Error{StatusCode: 400, Message: "Error - Trip has been cancelled"}, ExitRejected},
+		{&client.Error{StatusCode: 401}, ExitDenied},
+		{&client.Error{StatusCode: 403}, ExitDenied},
+		{&client.Error{StatusCode: 404}, ExitNotFound},
+		{&client.Error{StatusCode: 409}, ExitRejected},
+		{&client.Error{StatusCode: 429}, ExitUnavailable},
+		{&client.Error{StatusCode: 500}, ExitFailed},
+		{&client.Error{StatusCode: 502}, ExitUnavailable},
+		{&client.Error{StatusCode: 503}, ExitUnavailable},
+		{errors.New("connection refused"), ExitFailed},
+	}
+	for _, test := range tests {
+		if got := exitCode(test.err); got != test.want {
+			t.Errorf("exitCode(%v) = %d, want %d", test.err, got, test.want)
+		}
+	}
+}
+
+func TestCommandsWithoutTheServer(t *testing.T) {
+	var out, errOut bytes.Buffer
+	c := New(client.New("http://127.0.0.1:1", "console-key", nil), strings.NewReader(""), &out)
+	c.ErrOutput = &errOut
+
+	if exit := c.RunCommand([]string{"trips", "list"}); exit != ExitFailed || !strings.HasPrefix(errOut.String(), "Error executing request:") {
+		t.Errorf("trips list exited with %d and printed %q, want %d", exit, errOut.String(), ExitFailed)
+	}
+}
+
+// TestListings lists users and trips with the sorting, filtering and output flags
+func TestListings(t *testing.T) {
+	var out, errOut bytes.Buffer
+	c, _ := newTestConsole(t, "", &out)
+	c.ErrOutput = &errOut
+
+	ctx := context.Background()
+	for id, user := range map[string]model.User{
+		"O1": {FirstName: "John", LastName: "Lim", MobileNumber: "98765432", Email: "john@example.com", IsCarOwner: true, DriverLicense: "S1234567A", CarPlateNumber: "SBA1234A"},
+		"O2": {FirstName: "Alice", LastName: "Ng", MobileNumber: "97654321", Email: "alice@example.com", IsCarOwner: true, DriverLicense: "T7654321B", CarPlateNumber: "SGX88"},
+		"U3": {FirstName: "Bala", LastName: "Kumar", MobileNumber: "91234567", Email: "bala@example.com"},
+	} {
+		if _, err := c.api.CreateUser(ctx, id, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, trip := range map[string]model.Trip{
+		"T1": {CarOwnerID: "O1", PickupLocation: "Ang Mo Kio", Destination: "Ngee Ann Polytechnic", StartTime: testNow.Add(26 * time.Hour), TotalSeats: 3},
+		"T2": {CarOwnerID: "O2", PickupLocation: "Bishan", AltPickupLocation: "Marymount", Destination: "Orchard", StartTime: testNow.Add(time.Hour), TotalSeats: 1},
+		"T3": {CarOwnerID: "O1", PickupLocation: "Clementi", Destination: "Jurong East", StartTime: testNow.Add(2 * time.Hour), TotalSeats: 2},
+	} {
+		trip.AvailableSeats = trip.TotalSeats
+		if _, err := c.api.CreateTrip(ctx, id, trip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.api.EnrollPassenger(ctx, "T2", client.EnrollPassengerRequest{UserID: "U3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line   string
+		exit   int
+		out    string
+		errOut string
+	}{
+		{"users list --sort name", ExitOK, "ID  NAME        CAR OWNER  MOBILE    EMAIL\n" +
+			"O2  Alice Ng    yes        97654321  alice@example.com\n" +
+			"U3  Bala Kumar  no         91234567  bala@example.com\n" +
+			"O1  John Lim    yes        98765432  john@example.com\n", ""},
+		{"users list --role passenger", ExitOK, "ID  NAME        CAR OWNER  MOBILE    EMAIL\nU3  Bala Kumar  no         91234567  bala@example.com\n", ""},
+		{"users list --role owner --reverse --output csv", ExitOK,
+			"id,first_name,last_name,mobile_number,email,is_car_owner,driver_license,car_plate_number,created_at\n" +
+				"O2,Alice,Ng,97654321,alice@example.com,true,T7654321B,SGX88,2024-01-15T08:00:00Z\n" +
+				"O1,John,Lim,98765432,john@example.com,true,S1234567A,SBA1234A,2024-01-15T08:00:00Z\n", ""},
+		{"users list --search ALICE --output yaml", ExitOK, "- id: O2\n  first_name: Alice\n  last_name: Ng\n  mobile_number: \"97654321\"\n", ""},
+		{"users list --role driver", ExitUsage, "", `Error - invalid --role "driver", want one of owner, passenger`},
+
+		{"trips list --sort start", ExitOK, "ID  OWNER  ROUTE                              START                 SEATS  STATUS\n" +
+			"T2  O2     Bishan → Orchard                   2024-01-15 09:00 UTC  0/1    full\n" +
+			"T3  O1     Clementi → Jurong East             2024-01-15 10:00 UTC  2/2    scheduled\n" +
+			"T1  O1     Ang Mo Kio → Ngee Ann Polytechnic  2024-01-16 10:00 UTC  3/3    scheduled\n", ""},
+		{"trips list --sort seats --reverse --owner O1", ExitOK, "T1  O1     Ang Mo Kio → Ngee Ann Polytechnic  2024-01-16 10:00 UTC  3/3    scheduled\n" +
+			"T3  O1     Clementi → Jurong East             2024-01-15 10:00 UTC  2/2    scheduled\n", ""},
+		{"trips list --status full,started", ExitOK, "ID  OWNER  ROUTE             START                 SEATS  STATUS\nT2  O2     Bishan → Orchard  2024-01-15 09:00 UTC  0/1    full\n", ""},
+		{"trips list --passenger U3 --output csv", ExitOK,
+			"id,car_owner_id,pickup_location,alt_pickup_location,start_time,destination,available_seats,enrolled_passengers,total_seats,started,cancelled,series_id\n" +
+				"T2,O2,Bishan,Marymount,2024-01-15T09:00:00Z,Orchard,0,U3,1,false,false,\n", ""},
+		{"trips list --date tomorrow --output json", ExitOK, "[\n  {\n    \"id\": \"T1\",", ""},
+		{"trips list --date 2024-01-15 --search marymount", ExitOK, "T2  O2     Bishan → Orchard", ""},
+		{"trips list --date monday", ExitUsage, "", `Error - invalid --date "monday"`},
+		{"trips list --status late", ExitUsage, "", `Error - invalid --status "late", want one of scheduled, full, started, cancelled`},
+		{"trips list --sort price", ExitUsage, "", `Error - invalid --sort "price"`},
+		{"trips list --output xml", ExitUsage, "", `Error - invalid value "xml" for flag -output: unknown format "xml"`},
+		{"trips get --trip T3 --output yaml", ExitOK, "id: T3\ncar_owner_id: O1\npickup_location: Clementi\nstart_time: \"2024-01-15T10:00:00Z\"\n", ""},
+		{"policy get --output yaml", ExitOK, "publish_lead_time: 30m0s\n", ""},
+		{"policy get --output csv", ExitUsage, "", "Error - this command cannot print CSV\n"},
+	}
+	for _, test := range tests {
+		out.Reset()
+		errOut.Reset()
+		exit := c.RunCommand(commandLine(test.line))
+		if exit != test.exit || !strings.Contains(out.String(), test.out) || !strings.Contains(errOut.String(), test.errOut) {
+			t.Errorf("carpool %s exited with %d, want %d\noutput:\n%s\nwant:\n%s\nerrors:\n%s\nwant:\n%s",
+				test.line, exit, test.exit, out.String(), test.out, errOut.String(), test.errOut)
+		}
+	}
+}
+
+// TestExportAndImport exports the users and trips of one console to files and imports them with
+// another, as after a restart
+func TestExportAndImport(t *testing.T) {
+	var out, errOut bytes.Buffer
+	c, _ := newTestConsole(t, "", &out)
+	c.ErrOutput = &errOut
+
+	ctx := context.Background()
+	for id, user := range map[string]model.User{
+		"O1": {FirstName: "John", LastName: "Lim", MobileNumber: "98765432", Email: "john@example.com", IsCarOwner: true, DriverLicense: "S1234567A", CarPlateNumber: "SBA1234A"},
+		"U2": {FirstName: "Jane", LastName: "Tan", MobileNumber: "91234567", Email: "jane@example.com"},
+	} {
+		if _, err := c.api.CreateUser(ctx, id, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trip := model.Trip{CarOwnerID: "O1", PickupLocation: "Ang Mo Kio", Destination: "Ngee Ann Polytechnic", StartTime: testNow.Add(2 * time.Hour), TotalSeats: 2, AvailableSeats: 2}
+	if _, err := c.api.CreateTrip(ctx, "T1", trip); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.api.EnrollPassenger(ctx, "T1", client.EnrollPassengerRequest{UserID: "U2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	users, trips, bad := filepath.Join(dir, "users.csv"), filepath.Join(dir, "trips.json"), filepath.Join(dir, "bad.csv")
+	if err := os.WriteFile(bad, []byte("id,first_name,is_car_owner\nU3,Bala,maybe\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, _ := newTestConsole(t, "", &out)
+	imported.ErrOutput = &errOut
+
+	steps := []struct {
+		console *Console
+		line    string
+		exit    int
+		out     string
+		errOut  string
+	}{
+		{c, "users export --file " + users, ExitOK, "Exported 2 users to " + users + "\n", ""},
+		{c, "trips export --file " + trips, ExitOK, "Exported 1 trips to " + trips + "\n", ""},
+		{c, "users export", ExitOK, "[\n  {\n    \"id\": \"O1\",", ""},
+		{c, "trips export --format csv", ExitOK, "T1,O1,Ang Mo Kio,,2024-01-15T10:00:00Z,Ngee Ann Polytechnic,1,U2,2,false,false,\n", ""},
+		{c, "users export --format xml", ExitUsage, "", `Error - invalid --format "xml", want one of json, csv`},
+
+		{imported, "trips import --file " + trips, ExitRejected, "1    T1  failed  car owner O1 does not exist\n",
+			"Error - No trips were imported, 1 of 1 rows failed\n"},
+		{imported, "users import --file " + users + " --dry-run", ExitOK,
+			"ROW  ID  RESULT   ERROR\n1    O1  created  \n2    U2  created  \nDry run, nothing was imported: 2 users to create, 0 to update, 0 to skip\n", ""},
+		{imported, "users import --file " + users, ExitOK, "Imported the users: 2 created, 0 updated, 0 skipped\n", ""},
+		{imported, "users import --file " + users, ExitRejected, "1    O1  failed  user O1 already exists\n",
+			"Error - No users were imported, 2 of 2 rows failed\n"},
+		{imported, "users import --file " + users + " --on-conflict skip", ExitOK, "Imported the users: 0 created, 0 updated, 2 skipped\n", ""},
+		{imported, "users import --file " + users + " --on-conflict overwrite", ExitOK, "Imported the users: 0 created, 2 updated, 0 skipped\n", ""},
+		{imported, "trips import --file " + trips, ExitOK, "Imported the trips: 1 created, 0 updated, 0 skipped\n", ""},
+		{imported, "trips get --trip T1", ExitOK, "Passengers:   U2\n", ""},
+
+		{imported, "users import --file " + bad, ExitRejected, "1    U3  failed  invalid is_car_owner \"maybe\", want true or false\n",
+			"Error - No users were imported, 1 of 1 rows could not be read\n"},
+		{imported, "users import --file " + filepath.Join(dir, "missing.json"), ExitUsage, "", "Error - cannot read the users: "},
+		{imported, "users import --file " + trips + " --format csv", ExitUsage, "", "Error - cannot read the users from " + trips + ": "},
+		{imported, "users import --file " + users + " --on-conflict keep", ExitUsage, "", `Error - invalid --on-conflict "keep", want one of fail, skip, overwrite`},
+		{imported, "users import", ExitUsage, "", "Error - the --file flag is required\n"},
+	}
+	for _, step := range steps {
+		out.Reset()
+		errOut.Reset()
+		exit := step.console.RunCommand(commandLine(step.line))
+		if exit != step.exit || !strings.Contains(out.String(), step.out) || !strings.Contains(errOut.String(), step.errOut) {
+			t.Errorf("carpool %s exited with %d, want %d\noutput:\n%s\nwant:\n%s\nerrors:\n%s\nwant:\n%s",
+				step.line, exit, step.exit, out.String(), step.out, errOut.String(), step.errOut)
+		}
+	}
+}
@@ -0,0 +1,694 @@
+// Package console is the car-pooling console, a numbered menu of the operations that prompts for
+// their details and calls the API through the gateway. It also runs single commands for scripts
+// and a full-screen terminal UI. consolesub.go runs it on the terminal, the tests run it on a
+// script against an in-process API.
+package console
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/client"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// Console reads the chosen options and the answers to its prompts line by line from its input,
+// and writes the menu, the prompts and the results to its output
+type Console struct {
+	api   *client.Client
+	input io.Reader
+	in    *bufio.Scanner
+	out   io.Writer
+	echo  bool // write the answers to the output, so a scripted session reads like a typed one
+
+	// AccountRetention is how long accounts are kept before they can be deleted
+	AccountRetention time.Duration
+	// DisplayZone is the time zone trip times are entered and shown in
+	DisplayZone *time.Location
+	// Clock tells the console the time for the rules it checks before sending a request
+	Clock clock.Clock
+	// ErrOutput is where commands write their errors, the output if it is nil
+	ErrOutput io.Writer
+	// RefreshInterval is how often the terminal UI reloads the users and trips, 0 turns it off
+	RefreshInterval time.Duration
+}
+
+// New returns a console that calls the API with api, reading from in and writing to out
+func New(api *client.Client, in io.Reader, out io.Writer) *Console {
+	return &Console{
+		api:             api,
+		input:           in,
+		in:              bufio.NewScanner(in),
+		out:             out,
+		DisplayZone:     time.Local,
+		Clock:           clock.System,
+		RefreshInterval: 5 * time.Second,
+	}
+}
+
+// Run shows the menu and carries out the chosen operations until the user quits or the input ends
+func (c *Console) Run() {
+	for {
+		c.printMenu()
+
+		option, ok := c.readLine("Enter an option: ")
+		if !ok {
+			return
+		}
+
+		switch option {
+		case "1":
+			c.listAllUsers()
+		case "2":
+			c.createNewUser()
+		case "3":
+			c.updateUser()
+		case "4":
+			c.deleteUser()
+		case "5":
+			c.listAllTrips()
+		case "6":
+			c.createNewTrip()
+		case "7":
+			c.enrollPassenger()
+		case "8":
+			c.startTrip()
+		case "9":
+			c.cancelTrip()
+		case "10":
+			c.listTripStatus()
+		case "11":
+			c.upgradeToCarOwner()
+		case "12":
+			c.downgradeToPassenger()
+		case "13":
+			c.listNotifications()
+		case "14":
+			c.createNewSeries()
+		case "15":
+			c.updateSeries()
+		case "16":
+			c.skipSeriesDate()
+		case "17":
+			fmt.Fprintln(c.out, "Exiting the program.")
+			return
+		default:
+			fmt.Fprintln(c.out, "Invalid option. Please try again.")
+		}
+	}
+}
+
+// readLine prints the prompt and reads the answer, it returns false once the input has ended
+func (c *Console) readLine(prompt string) (string, bool) {
+	fmt.Fprint(c.out, prompt)
+	if !c.in.Scan() {
+		fmt.Fprintln(c.out)
+		return "", false
+	}
+	if c.echo {
+		fmt.Fprintln(c.out, c.in.Text())
+	}
+	return c.in.Text(), true
+}
+
+// prompt prints the prompt and reads the answer, which is empty once the input has ended
+func (c *Console) prompt(prompt string) string {
+	answer, _ := c.readLine(prompt)
+	return answer
+}
+
+func (c *Console) printMenu() {
+	fmt.Fprintln(c.out, "1. List all users")
+	fmt.Fprintln(c.out, "2. Create new user")
+	fmt.Fprintln(c.out, "3. Update user")
+	fmt.Fprintln(c.out, "4. Delete user")
+	fmt.Fprintln(c.out, "5. List all trips")
+	fmt.Fprintln(c.out, "6. Create new trip")
+	fmt.Fprintln(c.out, "7. Enroll passenger in a trip")
+	fmt.Fprintln(c.out, "8. Start a trip")
+	fmt.Fprintln(c.out, "9. Delete/cancel a trip")
+	fmt.Fprintln(c.out, "10.List trip status")
+	fmt.Fprintln(c.out, "11. Upgrade user to car owner")
+	fmt.Fprintln(c.out, "12. Downgrade car owner to passenger")
+	fmt.Fprintln(c.out, "13. View user notifications")
+	fmt.Fprintln(c.out, "14. Publish recurring trip")
+	fmt.Fprintln(c.out, "15. Update recurring trip")
+	fmt.Fprintln(c.out, "16. Skip a date of a recurring trip")
+	fmt.Fprintln(c.out, "17. Quit")
+}
+
+func (c *Console) listAllUsers() {
+	users, err := c.api.ListUsers(context.Background())
+	c.printListing(err, func() {
+		listed, _ := userFilter{sort: "id"}.apply(users)
+		c.printUsers(listed)
+	})
+}
+
+func (c *Console) createNewUser() {
+	userID := c.prompt("Enter the ID of the user to be created: ")
+
+	// Check if the user already exists
+	if c.userExists(userID) {
+		fmt.Fprintln(c.out, "Error - User already exists")
+		return
+	}
+
+	newUser, ok := c.readUser(userID)
+	if !ok {
+		return
+	}
+
+	c.printResult(c.api.CreateUser(context.Background(), userID, newUser))
+}
+
+func (c *Console) updateUser() {
+	userID := c.prompt("Enter the ID of the user to be updated: ")
+
+	// Check if the user exists
+	if !c.userExists(userID) {
+		fmt.Fprintln(c.out, "Error - User does not exist")
+		return
+	}
+
+	updatedUser, ok := c.readUser(userID)
+	if !ok {
+		return
+	}
+
+	c.printResult(c.api.UpdateUser(context.Background(), userID, updatedUser))
+}
+
+// readUser prompts for the details of a user profile
+func (c *Console) readUser(userID string) (model.User, bool) {
+	user := model.User{ID: userID}
+
+	user.FirstName = c.prompt("Enter the first name: ")
+	user.LastName = c.prompt("Enter the last name: ")
+	user.MobileNumber = c.prompt("Enter the mobile number: ")
+	user.Email = c.prompt("Enter the email address: ")
+
+	isCarOwner, err := strconv.ParseBool(c.prompt("Is the user also a car owner? (true/false): "))
+	if err != nil {
+		fmt.Fprintln(c.out, "Invalid input for car owner. Please enter true or false.")
+		return model.User{}, false
+	}
+	user.IsCarOwner = isCarOwner
+
+	if isCarOwner {
+		user.DriverLicense = c.prompt("Enter the driver's license number: ")
+		user.CarPlateNumber = c.prompt("Enter the car plate number: ")
+	}
+
+	return user, true
+}
+
+func (c *Console) deleteUser() {
+	userID := c.prompt("Enter the ID of the user to be deleted: ")
+
+	// Check if the user exists
+	if !c.userExists(userID) {
+		fmt.Fprintln(c.out, "Error - User does not exist")
+		return
+	}
+
+	// Retrieve user information from the server
+	user, err := c.api.GetUser(context.Background(), userID)
+	if err != nil {
+		fmt.Fprintln(c.out, "Error retrieving user information:", err)
+		return
+	}
+
+	// Check if the account has been active for at least the retention period
+	if err := user.CheckDelete(c.AccountRetention, c.Clock.Now()); err != nil {
+		fmt.Fprintln(c.out, model.ErrorMessage(err))
+		return
+	}
+
+	c.printResult(c.api.DeleteUser(context.Background(), userID))
+}
+
+func (c *Console) upgradeToCarOwner() {
+	userID := c.prompt("Enter the ID of the user to upgrade: ")
+
+	// Check if the user exists
+	if !c.userExists(userID) {
+		fmt.Fprintln(c.out, "Error - User does not exist")
+		return
+	}
+
+	driverLicense := c.prompt("Enter the driver's license number: ")
+	carPlateNumber := c.prompt("Enter the car plate number: ")
+
+	c.printResult(c.api.BecomeCarOwner(context.Background(), userID, client.BecomeCarOwnerRequest{
+		DriverLicense:  driverLicense,
+		CarPlateNumber: carPlateNumber,
+	}))
+}
+
+func (c *Console) downgradeToPassenger() {
+	userID := c.prompt("Enter the ID of the car owner to downgrade: ")
+
+	// Check if the user exists
+	if !c.userExists(userID) {
+		fmt.Fprintln(c.out, "Error - User does not exist")
+		return
+	}
+
+	c.printResult(c.api.BecomePassenger(context.Background(), userID))
+}
+
+func (c *Console) listNotifications() {
+	userID := c.prompt("Enter the ID of the user: ")
+
+	// Check if the user exists
+	if !c.userExists(userID) {
+		fmt.Fprintln(c.out, "Error - User does not exist")
+		return
+	}
+
+	notifications, err := c.api.ListNotifications(context.Background(), userID)
+	c.printListing(err, func() { c.printNotifications(notifications) })
+}
+
+func (c *Console) listAllTrips() {
+	trips, err := c.api.ListTrips(context.Background())
+	c.printListing(err, func() {
+		listed, _ := tripFilter{sort: "id"}.apply(trips, c.Clock.Now(), c.DisplayZone)
+		c.printTrips(listed)
+	})
+}
+
+func (c *Console) createNewTrip() {
+	tripID := c.prompt("Enter the ID of the trip to be created: ")
+
+	// Check if the trip already exists
+	if c.tripExists(tripID) {
+		fmt.Fprintln(c.out, "Error - Trip already exists")
+		return
+	}
+
+	carOwnerID := c.prompt("Enter the ID of the car owner: ")
+
+	// Retrieve car owner information from the server
+	carOwner, err := c.api.GetUser(context.Background(), carOwnerID)
+	if errors.Is(err, client.ErrNotFound) {
+		fmt.Fprintln(c.out, "Error - Car owner does not exist")
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(c.out, "Error retrieving car owner information:", err)
+		return
+	}
+
+	// Check that the user has a complete car owner profile
+	if err := carOwner.CheckCarOwner(); err != nil {
+		fmt.Fprintln(c.out, model.ErrorMessage(err))
+		return
+	}
+
+	pickupLocation := c.prompt("Enter the pickup location: ")
+	altPickupLocation := c.prompt("Enter the alternative pickup location (optional, press enter to skip): ")
+	startTimeStr := c.prompt("Enter the start time (e.g., '2006-01-02 15:04', 'tomorrow 08:15' or '15:04' for today, optionally followed by a time zone such as 'Asia/Singapore'): ")
+	startTime, err := parseStartTime(startTimeStr, c.Clock.Now(), c.DisplayZone)
+	if err != nil {
+		fmt.Fprintln(c.out, "Invalid input for start time:", err)
+		return
+	}
+
+	policy, err := c.api.GetPolicy(context.Background())
+	if err != nil {
+		fmt.Fprintln(c.out, "Error retrieving trip policy:", err)
+		return
+	}
+
+	// Validate that the start time is far enough in the future
+	if !policy.CanPublish(startTime, c.Clock.Now()) {
+		fmt.Fprintf(c.out, "Error - Trips must be scheduled at least %s in the future\n", model.FormatDuration(policy.PublishLeadTime))
+		return
+	}
+
+	destination := c.prompt("Enter the destination: ")
+
+	// Enter the number of available seats
+	totalSeatsStr := c.prompt("Enter the number of total seats in the car: ")
+	totalSeats, err := strconv.Atoi(totalSeatsStr)
+	if err != nil {
+		fmt.Fprintln(c.out, "Invalid input for total seats. Please enter a valid number.")
+		return
+	}
+
+	newTrip := model.Trip{
+		ID:                tripID,
+		CarOwnerID:        carOwnerID,
+		PickupLocation:    pickupLocation,
+		AltPickupLocation: altPickupLocation,
+		StartTime:         startTime.UTC(),
+		Destination:       destination,
+		TotalSeats:        totalSeats,
+	}
+
+	c.printResult(c.api.CreateTrip(context.Background(), tripID, newTrip))
+}
+
+func (c *Console) createNewSeries() {
+	seriesID := c.prompt("Enter the ID of the recurring trip to be created: ")
+
+	// Check if the series already exists
+	if c.seriesExists(seriesID) {
+		fmt.Fprintln(c.out, "Error - Recurring trip already exists")
+		return
+	}
+
+	newSeries, ok := c.readSeries()
+	if !ok {
+		return
+	}
+
+	c.printResult(c.api.CreateSeries(context.Background(), seriesID, newSeries))
+}
+
+func (c *Console) updateSeries() {
+	seriesID := c.prompt("Enter the ID of the recurring trip to be updated: ")
+
+	// Check if the series exists
+	if !c.seriesExists(seriesID) {
+		fmt.Fprintln(c.out, "Error - Recurring trip does not exist")
+		return
+	}
+
+	fmt.Fprintln(c.out, "Changes apply to upcoming trips only, trips that have already departed are kept as they were.")
+	updatedSeries, ok := c.readSeries()
+	if !ok {
+		return
+	}
+
+	c.printResult(c.api.UpdateSeries(context.Background(), seriesID, updatedSeries))
+}
+
+// readSeries prompts for the details of a recurring trip template
+func (c *Console) readSeries() (model.TripSeries, bool) {
+	carOwnerID := c.prompt("Enter the ID of the car owner: ")
+
+	// Check if the car owner exists
+	if !c.userExists(carOwnerID) {
+		fmt.Fprintln(c.out, "Error - Car owner does not exist")
+		return model.TripSeries{}, false
+	}
+
+	pickupLocation := c.prompt("Enter the pickup location: ")
+	altPickupLocation := c.prompt("Enter the alternative pickup location (optional, press enter to skip): ")
+	destination := c.prompt("Enter the destination: ")
+
+	timeOfDay := c.prompt("Enter the start time (e.g., '08:15' for 8:15 AM): ")
+	if _, err := time.Parse("15:04", timeOfDay); err != nil {
+		fmt.Fprintln(c.out, "Invalid input for start time. Please enter a valid time in '15:04' format.")
+		return model.TripSeries{}, false
+	}
+
+	timeZone := c.prompt("Enter the time zone of the start time (e.g., 'Asia/Singapore', press enter for the server's display zone): ")
+	if timeZone != "" {
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			fmt.Fprintln(c.out, "Invalid input for time zone:", err)
+			return model.TripSeries{}, false
+		}
+	}
+
+	days := strings.Split(c.prompt("Enter the days the trip runs on (e.g., 'weekdays' or 'MO,WE,FR'): "), ",")
+
+	until := c.prompt("Enter the last date of the recurring trip (e.g., '2006-01-02', press enter for no end date): ")
+	if until != "" {
+		if _, err := time.Parse("2006-01-02", until); err != nil {
+			fmt.Fprintln(c.out, "Invalid input for last date. Please enter a valid date in '2006-01-02' format.")
+			return model.TripSeries{}, false
+		}
+	}
+
+	totalSeatsStr := c.prompt("Enter the number of total seats in the car: ")
+	totalSeats, err := strconv.Atoi(totalSeatsStr)
+	if err != nil {
+		fmt.Fprintln(c.out, "Invalid input for total seats. Please enter a valid number.")
+		return model.TripSeries{}, false
+	}
+
+	return model.TripSeries{
+		CarOwnerID:        carOwnerID,
+		PickupLocation:    pickupLocation,
+		AltPickupLocation: altPickupLocation,
+		Destination:       destination,
+		TimeOfDay:         timeOfDay,
+		TimeZone:          timeZone,
+		Days:              days,
+		Until:             until,
+		TotalSeats:        totalSeats,
+	}, true
+}
+
+func (c *Console) skipSeriesDate() {
+	seriesID := c.prompt("Enter the ID of the recurring trip: ")
+
+	// Check if the series exists
+	if !c.seriesExists(seriesID) {
+		fmt.Fprintln(c.out, "Error - Recurring trip does not exist")
+		return
+	}
+
+	date := c.prompt("Enter the date to skip (e.g., '2006-01-02'): ")
+
+	c.printResult(c.api.SkipSeriesDate(context.Background(), seriesID, client.SkipSeriesDateRequest{Date: date}))
+}
+
+// parseStartTime reads a start time such as "2006-01-02 15:04", "tomorrow 08:15" or "15:04" (today),
+// optionally followed by a time zone name. Times without a zone are taken in displayZone.
+func parseStartTime(input string, now time.Time, displayZone *time.Location) (time.Time, error) {
+	fields := strings.Fields(input)
+	zone := displayZone
+	if len(fields) > 1 {
+		if loc, err := time.LoadLocation(fields[len(fields)-1]); err == nil {
+			zone = loc
+			fields = fields[:len(fields)-1]
+		}
+	}
+	now = now.In(zone)
+
+	var day time.Time
+	var clock string
+	switch len(fields) {
+	case 1:
+		day, clock = now, fields[0]
+	case 2:
+		switch strings.ToLower(fields[0]) {
+		case "today":
+			day = now
+		case "tomorrow":
+			day = now.AddDate(0, 0, 1)
+		default:
+			date, err := time.ParseInLocation("2006-01-02", fields[0], zone)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid date %q, use the '2006-01-02' format", fields[0])
+			}
+			day = date
+		}
+		clock = fields[1]
+	default:
+		return time.Time{}, errors.New("expected a time such as '2006-01-02 15:04', 'tomorrow 08:15' or '15:04'")
+	}
+
+	clockTime, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use the '15:04' format", clock)
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), clockTime.Hour(), clockTime.Minute(), 0, 0, zone), nil
+}
+
+func (c *Console) enrollPassenger() {
+	tripID := c.prompt("Enter the ID of the trip to enroll in: ")
+
+	// Check if the trip exists
+	if !c.tripExists(tripID) {
+		fmt.Fprintln(c.out, "Error - Trip does not exist")
+		return
+	}
+
+	userID := c.prompt("Enter the ID of the user to enroll in the trip: ")
+
+	c.printResult(c.api.EnrollPassenger(context.Background(), tripID, client.EnrollPassengerRequest{UserID: userID}))
+}
+
+// startTrip handles the starting of a trip
+func (c *Console) startTrip() {
+	tripID := c.prompt("Enter the ID of the trip to start: ")
+
+	// Retrieve trip information from the server
+	trip, ok := c.getTrip(tripID)
+	if !ok {
+		return
+	}
+
+	// Display trip information
+	fmt.Fprintf(c.out, "Trip %s Information:\n", tripID)
+	fmt.Fprintf(c.out, " - Start Time: %s\n", trip.StartTime.In(c.DisplayZone).Format("2006-01-02 15:04 MST"))
+	fmt.Fprintf(c.out, " - Started: %v\n", trip.Started)
+	fmt.Fprintf(c.out, " - Enrolled Passengers: %v\n", trip.EnrolledPassengers)
+
+	// Check if the user is the car owner
+	carOwnerID := c.prompt("Enter your user ID as the car owner: ")
+
+	policy, err := c.api.GetPolicy(context.Background())
+	if err != nil {
+		fmt.Fprintln(c.out, "Error retrieving trip policy:", err)
+		return
+	}
+
+	// Check that the car owner is starting a trip with passengers within its start window
+	if err := trip.CheckStart(carOwnerID, policy, c.Clock.Now()); err != nil {
+		fmt.Fprintln(c.out, model.ErrorMessage(err))
+		return
+	}
+
+	// Mark the trip as started on the server
+	if _, err := c.api.StartTrip(context.Background(), tripID, carOwnerID); err != nil {
+		fmt.Fprintln(c.out, "Error starting trip:", err)
+		return
+	}
+	fmt.Fprintln(c.out, "Trip started successfully.")
+}
+
+// getTrip retrieves a trip, printing what went wrong if it cannot
+func (c *Console) getTrip(tripID string) (model.Trip, bool) {
+	trip, err := c.api.GetTrip(context.Background(), tripID)
+	if errors.Is(err, client.ErrNotFound) {
+		fmt.Fprintln(c.out, "Error - Trip does not exist")
+		return model.Trip{}, false
+	}
+	if err != nil {
+		fmt.Fprintln(c.out, "Error retrieving trip information:", err)
+		return model.Trip{}, false
+	}
+	return trip, true
+}
+
+func (c *Console) userExists(userID string) bool {
+	_, err := c.api.GetUser(context.Background(), userID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		fmt.Fprintln(c.out, "Error checking if user exists:", err)
+	}
+	return err == nil
+}
+
+func (c *Console) tripExists(tripID string) bool {
+	_, err := c.api.GetTrip(context.Background(), tripID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		fmt.Fprintln(c.out, "Error checking if trip exists:", err)
+	}
+	return err == nil
+}
+
+func (c *Console) seriesExists(seriesID string) bool {
+	_, err := c.api.GetSeries(context.Background(), seriesID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		fmt.Fprintln(c.out, "Error checking if recurring trip exists:", err)
+	}
+	return err == nil
+}
+
+// printResult prints the server's answer to a change, or why the request failed
+func (c *Console) printResult(message string, err error) {
+	var apiErr *client.Error
+	switch {
+	case errors.As(err, &apiErr):
+		fmt.Fprintln(c.out, apiErr.Message)
+	case err != nil:
+		fmt.Fprintln(c.out, "Error executing request:", err)
+	default:
+		fmt.Fprintln(c.out, message)
+	}
+}
+
+// printListing prints a listing with print, or why the request failed
+func (c *Console) printListing(err error, print func()) {
+	if err != nil {
+		fmt.Fprintln(c.out, "Error making request:", err)
+		return
+	}
+	print()
+}
+
+// ReadinessProblem asks the gateway whether it and the services behind it are ready.
+// If they are not it returns what is wrong and what to do about it, otherwise an empty string.
+func (c *Console) ReadinessProblem() string {
+	status, err := c.api.Ready(context.Background())
+	if err == nil {
+		return ""
+	}
+	if !errors.Is(err, client.ErrUnavailable) {
+		return fmt.Sprintf("The car-pooling server is not reachable at %s.\n"+
+			"Please start the gateway, the User service and the Trip service (see the README) and try again.", c.api.GatewayURL())
+	}
+
+	var names []string
+	for name := range status.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	message := "The car-pooling server is running but not ready yet:"
+	for _, name := range names {
+		if result := status.Checks[name]; result != "ok" {
+			message += fmt.Sprintf("\n  %s: %s", strings.ReplaceAll(name, "_", " "), result)
+		}
+	}
+	return message + "\nPlease try again in a moment."
+}
+
+func (c *Console) cancelTrip() {
+	tripID := c.prompt("Enter the ID of the trip to cancel: ")
+
+	// Retrieve trip information from the server
+	trip, ok := c.getTrip(tripID)
+	if !ok {
+		return
+	}
+
+	policy, err := c.api.GetPolicy(context.Background())
+	if err != nil {
+		fmt.Fprintln(c.out, "Error retrieving trip policy:", err)
+		return
+	}
+
+	// Check that the trip has not started and is still before the cancellation cut-off
+	if err := trip.CheckCancel(policy, c.Clock.Now()); err != nil {
+		fmt.Fprintln(c.out, model.ErrorMessage(err))
+		return
+	}
+
+	// Perform the trip cancellation
+	c.printResult(c.api.DeleteTrip(context.Background(), tripID))
+}
+
+// listTripStatus prints out the status of the trip, including whether it has started
+func (c *Console) listTripStatus() {
+	tripID := c.prompt("Enter the ID of the trip to check status: ")
+
+	// Retrieve trip information from the server
+	trip, ok := c.getTrip(tripID)
+	if !ok {
+		return
+	}
+
+	// Display updated trip status
+	fmt.Fprintf(c.out, "Trip %s Status:\n", tripID)
+	fmt.Fprintf(c.out, " - Start Time: %s\n", trip.StartTime.In(c.DisplayZone).Format("2006-01-02 15:04 MST"))
+	fmt.Fprintf(c.out, " - Started: %v\n", trip.Started) // Updated trip status from the server
+	fmt.Fprintf(c.out, " - Cancelled: %v\n", trip.Cancelled)
+	fmt.Fprintf(c.out, " - Enrolled Passengers: %v\n", trip.EnrolledPassengers)
+}
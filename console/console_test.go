@@ -0,0 +1,130 @@
+package console
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/client"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi/openapitest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the console's output")
+
+// testNow is when the scenarios are run, a Monday morning
+var testNow = time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+
+const accountRetention = 365 * 24 * time.Hour
+
+// newTestConsole returns a console on the script, talking to an in-process API through a client
+// whose requests and responses are checked against the OpenAPI document. Times are shown in UTC.
+func newTestConsole(t *testing.T, script string, out *bytes.Buffer) (*Console, *clock.Fake) {
+	fake := clock.NewFake(testNow)
+	p := config.Default().Policy
+	policy := model.Policy{
+		PublishLeadTime:   p.PublishLeadTime,
+		StartWindowBefore: p.StartWindowBefore,
+		StartWindowAfter:  p.StartWindowAfter,
+		CancelCutoff:      p.CancelCutoff,
+		EnrollmentCutoff:  p.EnrollmentCutoff,
+	}
+
+	v := openapitest.New(t)
+	api := client.New("http://gateway.test", "console-key", &http.Client{Transport: v.Transport(newFakeAPI(fake, policy))})
+
+	c := New(api, strings.NewReader(script), out)
+	c.echo = true
+	c.AccountRetention = accountRetention
+	c.DisplayZone = time.UTC
+	c.Clock = fake
+	return c, fake
+}
+
+// TestScenarios runs the console on each script in testdata/*.input and compares what it
+// printed with the .golden file next to it. Run "go test ./console -update" to rewrite them.
+func TestScenarios(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.input"))
+	if err != nil || len(scripts) == 0 {
+		t.Fatalf("no scenarios in testdata: %v", err)
+	}
+
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".input")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			c, _ := newTestConsole(t, string(input), &out)
+			c.Run()
+
+			golden := strings.TrimSuffix(script, ".input") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkOutput(t, out.String(), string(want))
+		})
+	}
+}
+
+// checkOutput fails the test at the first line of the output that differs from the golden file
+func checkOutput(t *testing.T, got, want string) {
+	t.Helper()
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var gotLine, wantLine string
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if gotLine != wantLine {
+			t.Fatalf("line %d of the output is\n  %q\nwant\n  %q", i+1, gotLine, wantLine)
+		}
+	}
+}
+
+func TestParseStartTime(t *testing.T) {
+	singapore, err := time.LoadLocation("Asia/Singapore")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"09:30", time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)},
+		{"today 09:30", time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)},
+		{"tomorrow 08:15", time.Date(2024, 1, 16, 8, 15, 0, 0, time.UTC)},
+		{"2024-02-01 18:00", time.Date(2024, 2, 1, 18, 0, 0, 0, time.UTC)},
+		{"tomorrow 08:15 Asia/Singapore", time.Date(2024, 1, 16, 8, 15, 0, 0, singapore)},
+	}
+	for _, test := range tests {
+		if got, err := parseStartTime(test.input, testNow, time.UTC); err != nil || !got.Equal(test.want) {
+			t.Errorf("parseStartTime(%q) returned %v, %v, want %v", test.input, got, err, test.want)
+		}
+	}
+
+	for _, input := range []string{"", "9.30", "next week 09:30", "2024-13-01 09:30"} {
+		if _, err := parseStartTime(input, testNow, time.UTC); err == nil {
+			t.Errorf("parseStartTime(%q) accepted an invalid time", input)
+		}
+	}
+}
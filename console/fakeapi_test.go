@@ -0,0 +1,423 @@
+package console
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/bulk"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/gorilla/mux"
+)
+
+// fakeAPI is an in-process car-pooling API for the console's scenarios. It keeps the users and
+// trips in maps and answers like the User and Trip services behind the gateway, checking the
+// same rules through the model package at the time of the test's clock.
+type fakeAPI struct {
+	mu     sync.Mutex
+	clock  clock.Clock
+	policy model.Policy
+	users  map[string]model.User
+	trips  map[string]model.Trip
+	series map[string]model.TripSeries
+}
+
+func newFakeAPI(clk clock.Clock, policy model.Policy) http.Handler {
+	api := &fakeAPI{clock: clk, policy: policy, users: map[string]model.User{}, trips: map[string]model.Trip{},
+		series: map[string]model.TripSeries{}}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/users", api.listUsers).Methods("GET")
+	r.HandleFunc("/api/v1/users/{id}", api.getUser).Methods("GET", "DELETE")
+	r.HandleFunc("/api/v1/users/{id}", api.putUser).Methods("POST", "PUT")
+	r.HandleFunc("/api/v1/users/{id}/become-owner", api.becomeOwner).Methods("POST")
+	r.HandleFunc("/api/v1/users/{id}/become-passenger", api.becomePassenger).Methods("POST")
+	r.HandleFunc("/api/v1/users/{id}/notifications", api.listNotifications).Methods("GET")
+	r.HandleFunc("/api/v1/trips", api.listTrips).Methods("GET")
+	r.HandleFunc("/api/v1/trips/{id}", api.getTrip).Methods("GET", "DELETE")
+	r.HandleFunc("/api/v1/trips/{id}", api.putTrip).Methods("POST", "PUT")
+	r.HandleFunc("/api/v1/trips/{id}/enroll", api.enroll).Methods("PUT")
+	r.HandleFunc("/api/v1/trips/{id}/start", api.start).Methods("PUT")
+	r.HandleFunc("/api/v1/series", api.listSeries).Methods("GET")
+	r.HandleFunc("/api/v1/series/{id}", api.getSeries).Methods("GET", "DELETE")
+	r.HandleFunc("/api/v1/series/{id}", api.putSeries).Methods("POST", "PUT")
+	r.HandleFunc("/api/v1/series/{id}/skip", api.skip).Methods("POST")
+	r.HandleFunc("/api/v1/policy", func(w http.ResponseWriter, r *http.Request) { writeJSON(w, api.policy) }).Methods("GET")
+
+	// The admin endpoints export and import the maps with the services' bulk package
+	users, trips := api.bulkUsers(), api.bulkTrips()
+	r.HandleFunc("/api/v1/admin/users/export", users.Export).Methods("GET")
+	r.HandleFunc("/api/v1/admin/users/import", users.Import).Methods("POST")
+	r.HandleFunc("/api/v1/admin/trips/export", trips.Export).Methods("GET")
+	r.HandleFunc("/api/v1/admin/trips/import", trips.Import).Methods("POST")
+
+	// Lock around every request so the handlers below don't have to
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		r.ServeHTTP(w, request)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, message)
+}
+
+func writeRuleError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, model.ErrNotTripOwner) {
+		status = http.StatusUnauthorized
+	}
+	writeError(w, status, model.ErrorMessage(err))
+}
+
+func (api *fakeAPI) listUsers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, api.users)
+}
+
+func (api *fakeAPI) getUser(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	user, ok := api.users[userID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid user ID")
+		return
+	}
+	if r.Method == "GET" {
+		writeJSON(w, user)
+		return
+	}
+	if err := user.CheckDelete(accountRetention, api.clock.Now()); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	delete(api.users, userID)
+	fmt.Fprintf(w, "User %s deleted", userID)
+}
+
+func (api *fakeAPI) putUser(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	var user model.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := user.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, model.ErrorMessage(err))
+		return
+	}
+
+	user.ID, user.CreatedAt = userID, api.clock.Now()
+	if existing, ok := api.users[userID]; ok {
+		user.CreatedAt = existing.CreatedAt
+	}
+	api.users[userID] = user
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "User %s %s successfully", r.Method, userID)
+}
+
+func (api *fakeAPI) becomeOwner(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	user, ok := api.users[userID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid user ID")
+		return
+	}
+	var details map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&details); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	license, plate, err := model.NormaliseCarOwnerDetails(details["driver_license"], details["car_plate_number"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, model.ErrorMessage(err))
+		return
+	}
+
+	user.IsCarOwner, user.DriverLicense, user.CarPlateNumber = true, license, plate
+	api.users[userID] = user
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "User %s is now a car owner", userID)
+}
+
+func (api *fakeAPI) becomePassenger(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	user, ok := api.users[userID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid user ID")
+		return
+	}
+	if !user.IsCarOwner {
+		writeError(w, http.StatusBadRequest, "Error - User is not a car owner")
+		return
+	}
+
+	user.IsCarOwner, user.DriverLicense, user.CarPlateNumber = false, "", ""
+	api.users[userID] = user
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "User %s is now a passenger", userID)
+}
+
+func (api *fakeAPI) listNotifications(w http.ResponseWriter, r *http.Request) {
+	if _, ok := api.users[mux.Vars(r)["id"]]; !ok {
+		writeError(w, http.StatusNotFound, "Invalid user ID")
+		return
+	}
+	writeJSON(w, []model.Notification{})
+}
+
+func (api *fakeAPI) listTrips(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, api.trips)
+}
+
+func (api *fakeAPI) getTrip(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+	trip, ok := api.trips[tripID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid trip ID")
+		return
+	}
+	if r.Method == "GET" {
+		writeJSON(w, trip)
+		return
+	}
+	if err := trip.CheckCancel(api.policy, api.clock.Now()); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	delete(api.trips, tripID)
+	fmt.Fprintf(w, "Trip %s deleted", tripID)
+}
+
+func (api *fakeAPI) putTrip(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+	var trip model.Trip
+	if err := json.NewDecoder(r.Body).Decode(&trip); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := trip.CheckPublish(api.policy, api.clock.Now()); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	carOwner, ok := api.users[trip.CarOwnerID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Error - Car owner does not exist")
+		return
+	}
+	if err := carOwner.CheckCarOwner(); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+
+	trip.ID = tripID
+	trip.EnrolledPassengers = api.trips[tripID].EnrolledPassengers
+	trip.UpdateSeats()
+	api.trips[tripID] = trip
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Trip %s %s successfully", r.Method, tripID)
+}
+
+func (api *fakeAPI) enroll(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+	var enrollment map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&enrollment); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	userID := enrollment["user_id"]
+
+	trip, ok := api.trips[tripID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid trip ID")
+		return
+	}
+	if err := trip.CheckEnroll(userID, api.policy, api.clock.Now()); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+
+	trip.EnrolledPassengers = append(trip.EnrolledPassengers, userID)
+	trip.UpdateSeats()
+	api.trips[tripID] = trip
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "User %s enrolled in trip %s successfully", userID, tripID)
+}
+
+func (api *fakeAPI) start(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+	trip, ok := api.trips[tripID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid trip ID")
+		return
+	}
+	if err := trip.CheckStart(r.Header.Get("car-owner-id"), api.policy, api.clock.Now()); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+
+	trip.Started = true
+	api.trips[tripID] = trip
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Trip %s started successfully", tripID)
+}
+
+func (api *fakeAPI) listSeries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, api.series)
+}
+
+func (api *fakeAPI) getSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	series, ok := api.series[seriesID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid series ID")
+		return
+	}
+	if r.Method == "GET" {
+		writeJSON(w, series)
+		return
+	}
+	delete(api.series, seriesID)
+	fmt.Fprintf(w, "Series %s deleted", seriesID)
+}
+
+// putSeries stores a recurring trip, without publishing its trips
+func (api *fakeAPI) putSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	var series model.TripSeries
+	if err := json.NewDecoder(r.Body).Decode(&series); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	existing, exists := api.series[seriesID]
+	if r.Method == "POST" && exists {
+		writeError(w, http.StatusConflict, "Error - Series already exists")
+		return
+	}
+	if r.Method == "PUT" && !exists {
+		writeError(w, http.StatusNotFound, "Invalid series ID")
+		return
+	}
+	if carOwner, ok := api.users[series.CarOwnerID]; !ok {
+		writeError(w, http.StatusNotFound, "Error - Car owner does not exist")
+		return
+	} else if err := carOwner.CheckCarOwner(); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+
+	series.ID, series.SkippedDates = seriesID, existing.SkippedDates
+	api.series[seriesID] = series
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Series %s %s successfully", r.Method, seriesID)
+}
+
+func (api *fakeAPI) skip(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	series, ok := api.series[seriesID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid series ID")
+		return
+	}
+	var skip map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&skip); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	series.SkippedDates = append(series.SkippedDates, skip["date"])
+	api.series[seriesID] = series
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Occurrence %s of series %s skipped", skip["date"], seriesID)
+}
+
+// inOrder returns the values of a map in the order of their keys
+func inOrder[V any](m map[string]V) []V {
+	values := make([]V, 0, len(m))
+	for _, key := range sortedKeys(m) {
+		values = append(values, m[key])
+	}
+	return values
+}
+
+func (api *fakeAPI) bulkUsers() bulk.Records[model.User] {
+	return bulk.Records[model.User]{
+		Name:    "users",
+		Entity:  "user",
+		Columns: model.UserColumns,
+		All:     func() []model.User { return inOrder(api.users) },
+		Get: func(id string) (model.User, bool) {
+			user, ok := api.users[id]
+			return user, ok
+		},
+		ID:      func(user model.User) string { return user.ID },
+		CSV:     model.UsersCSV,
+		FromCSV: model.CSVRow.User,
+		Check: func(user, _ *model.User) error {
+			if user.CreatedAt.IsZero() {
+				user.CreatedAt = api.clock.Now()
+			}
+			return user.CheckImport()
+		},
+		PutAll: func(users []model.User, check func(stored func(id string) (model.User, bool)) error) error {
+			if err := check(func(id string) (model.User, bool) {
+				user, ok := api.users[id]
+				return user, ok
+			}); err != nil {
+				return err
+			}
+			for _, user := range users {
+				api.users[user.ID] = user
+			}
+			return nil
+		},
+	}
+}
+
+func (api *fakeAPI) bulkTrips() bulk.Records[model.Trip] {
+	return bulk.Records[model.Trip]{
+		Name:    "trips",
+		Entity:  "trip",
+		Columns: model.TripColumns,
+		All:     func() []model.Trip { return inOrder(api.trips) },
+		Get: func(id string) (model.Trip, bool) {
+			trip, ok := api.trips[id]
+			return trip, ok
+		},
+		ID:      func(trip model.Trip) string { return trip.ID },
+		CSV:     model.TripsCSV,
+		FromCSV: model.CSVRow.Trip,
+		Check: func(trip, _ *model.Trip) error {
+			if err := trip.CheckImport(); err != nil {
+				return err
+			}
+			carOwner, ok := api.users[trip.CarOwnerID]
+			if !ok {
+				return fmt.Errorf("car owner %s does not exist", trip.CarOwnerID)
+			}
+			trip.UpdateSeats()
+			return carOwner.CheckCarOwner()
+		},
+		PutAll: func(trips []model.Trip, check func(stored func(id string) (model.Trip, bool)) error) error {
+			if err := check(func(id string) (model.Trip, bool) {
+				trip, ok := api.trips[id]
+				return trip, ok
+			}); err != nil {
+				return err
+			}
+			for _, trip := range trips {
+				api.trips[trip.ID] = trip
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,318 @@
+package console
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// The listings are printed as aligned tables for people to read, or with --output as JSON, YAML
+// or CSV for scripts. JSON and YAML hold the records the API returns, in the order of the table,
+// and the CSV columns are named after their JSON fields.
+
+// outputFlag is the format of a command's output, set with --output
+type outputFlag string
+
+var outputFormats = []string{"table", "json", "yaml", "csv"}
+
+func (o *outputFlag) String() string { return string(*o) }
+
+func (o *outputFlag) Set(format string) error {
+	for _, known := range outputFormats {
+		if format == known {
+			*o = outputFlag(format)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q, want one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// outputFlags defines --output, and --json as short for --output json
+func outputFlags(f *flag.FlagSet, what string) *outputFlag {
+	output := outputFlag("table")
+	f.Var(&output, "output", "print the "+what+" as a table, json, yaml or csv")
+	f.BoolFunc("json", "print the "+what+" as JSON, short for --output json", func(value string) error {
+		if value == "true" {
+			output = "json"
+		}
+		return nil
+	})
+	return &output
+}
+
+// printOutput prints v as JSON or YAML, the records as CSV, or the table
+func (c *Console) printOutput(output *outputFlag, v interface{}, records [][]string, table func()) error {
+	switch *output {
+	case "json":
+		return c.printJSON(v)
+	case "yaml":
+		return c.printYAML(v)
+	case "csv":
+		if records == nil {
+			return usageError("this command cannot print CSV")
+		}
+		w := csv.NewWriter(c.out)
+		w.WriteAll(records)
+		return w.Error()
+	}
+	table()
+	return nil
+}
+
+// printYAML prints v as YAML, with the fields named and ordered as in its JSON
+func (c *Console) printYAML(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// JSON is YAML, so decoding it into a node keeps the order of the fields. Its flow style and
+	// quotes are dropped so it prints as block YAML.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	var blockStyle func(node *yaml.Node)
+	blockStyle = func(node *yaml.Node) {
+		node.Style = 0
+		for _, child := range node.Content {
+			blockStyle(child)
+		}
+	}
+	blockStyle(&doc)
+
+	data, err = yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	_, err = c.out.Write(data)
+	return err
+}
+
+// oneOf checks that a flag's value is one of the choices
+func oneOf(name, value string, choices ...string) error {
+	for _, choice := range choices {
+		if value == choice {
+			return nil
+		}
+	}
+	return usageError("invalid --%s %q, want one of %s", name, value, strings.Join(choices, ", "))
+}
+
+// joinList joins the values of a list in a CSV cell
+func joinList(values []string) string {
+	return strings.Join(values, ";")
+}
+
+// userFilter chooses and orders the users of a listing
+type userFilter struct {
+	sort    string
+	reverse bool
+	role    string
+	search  string
+}
+
+// userOrders compare users by each of the --sort fields
+var userOrders = map[string]func(a, b model.User) bool{
+	"id":      func(a, b model.User) bool { return a.ID < b.ID },
+	"name":    func(a, b model.User) bool { return strings.ToLower(fullName(a)) < strings.ToLower(fullName(b)) },
+	"created": func(a, b model.User) bool { return a.CreatedAt.Before(b.CreatedAt) },
+}
+
+func (uf *userFilter) define(f *flag.FlagSet) {
+	f.StringVar(&uf.sort, "sort", "id", "order of the users: id, name or created")
+	f.BoolVar(&uf.reverse, "reverse", false, "list the users in reverse order")
+	f.StringVar(&uf.role, "role", "", "only list the users with the role: owner or passenger")
+	f.StringVar(&uf.search, "search", "", "only list the users whose ID, name or email contains the text")
+}
+
+// apply returns the users the filter chooses, in its order
+func (uf userFilter) apply(users map[string]model.User) ([]model.User, error) {
+	if err := oneOf("sort", uf.sort, "id", "name", "created"); err != nil {
+		return nil, err
+	}
+	if uf.role != "" {
+		if err := oneOf("role", uf.role, "owner", "passenger"); err != nil {
+			return nil, err
+		}
+	}
+
+	search := strings.ToLower(uf.search)
+	var listed []model.User
+	for _, id := range sortedKeys(users) {
+		user := users[id]
+		user.ID = id
+		switch {
+		case uf.role == "owner" && !user.IsCarOwner, uf.role == "passenger" && user.IsCarOwner:
+			continue
+		case search != "" && !strings.Contains(strings.ToLower(user.ID+" "+fullName(user)+" "+user.Email), search):
+			continue
+		}
+		listed = append(listed, user)
+	}
+
+	less := userOrders[uf.sort]
+	sort.SliceStable(listed, func(i, j int) bool {
+		if uf.reverse {
+			return less(listed[j], listed[i])
+		}
+		return less(listed[i], listed[j])
+	})
+	return listed, nil
+}
+
+func fullName(user model.User) string {
+	return user.FirstName + " " + user.LastName
+}
+
+// printUsers prints the users in a table
+func (c *Console) printUsers(users []model.User) {
+	rows := make([][]string, len(users))
+	for i, user := range users {
+		owner := "no"
+		if user.IsCarOwner {
+			owner = "yes"
+		}
+		rows[i] = []string{user.ID, fullName(user), owner, user.MobileNumber, user.Email}
+	}
+	c.printTable([]string{"ID", "NAME", "CAR OWNER", "MOBILE", "EMAIL"}, rows)
+}
+
+// tripFilter chooses and orders the trips of a listing
+type tripFilter struct {
+	sort      string
+	reverse   bool
+	status    string
+	owner     string
+	passenger string
+	date      string
+	search    string
+}
+
+var tripStatuses = []string{"scheduled", "full", "started", "cancelled"}
+
+// tripOrders compare trips by each of the --sort fields
+var tripOrders = map[string]func(a, b model.Trip) bool{
+	"id":     func(a, b model.Trip) bool { return a.ID < b.ID },
+	"start":  func(a, b model.Trip) bool { return a.StartTime.Before(b.StartTime) },
+	"owner":  func(a, b model.Trip) bool { return a.CarOwnerID < b.CarOwnerID },
+	"seats":  func(a, b model.Trip) bool { return a.SeatsLeft() < b.SeatsLeft() },
+	"status": func(a, b model.Trip) bool { return statusRank(a) < statusRank(b) },
+}
+
+// statusRank orders the statuses as a trip goes through them
+func statusRank(trip model.Trip) int {
+	status := displayStatus(trip)
+	for i, known := range tripStatuses {
+		if status == known {
+			return i
+		}
+	}
+	return len(tripStatuses)
+}
+
+func (tf *tripFilter) define(f *flag.FlagSet) {
+	f.StringVar(&tf.sort, "sort", "id", "order of the trips: id, start, owner, seats or status")
+	f.BoolVar(&tf.reverse, "reverse", false, "list the trips in reverse order")
+	f.StringVar(&tf.status, "status", "", "only list the trips with one of the statuses, e.g. scheduled,full (also started, cancelled)")
+	f.StringVar(&tf.owner, "owner", "", "only list the trips of the car owner")
+	f.StringVar(&tf.passenger, "passenger", "", "only list the trips the user is enrolled in")
+	f.StringVar(&tf.date, "date", "", "only list the trips starting on the date: today, tomorrow or e.g. 2024-01-15")
+	f.StringVar(&tf.search, "search", "", "only list the trips whose pickup or destination contains the text")
+}
+
+// apply returns the trips the filter chooses, in its order. Dates are in the display zone.
+func (tf tripFilter) apply(trips map[string]model.Trip, now time.Time, zone *time.Location) ([]model.Trip, error) {
+	if err := oneOf("sort", tf.sort, "id", "start", "owner", "seats", "status"); err != nil {
+		return nil, err
+	}
+	statuses := map[string]bool{}
+	if tf.status != "" {
+		for _, status := range strings.Split(tf.status, ",") {
+			if err := oneOf("status", status, tripStatuses...); err != nil {
+				return nil, err
+			}
+			statuses[status] = true
+		}
+	}
+	var date string
+	switch today := now.In(zone); tf.date {
+	case "":
+	case "today":
+		date = today.Format("2006-01-02")
+	case "tomorrow":
+		date = today.AddDate(0, 0, 1).Format("2006-01-02")
+	default:
+		if _, err := time.Parse("2006-01-02", tf.date); err != nil {
+			return nil, usageError("invalid --date %q, want today, tomorrow or a date such as 2024-01-15", tf.date)
+		}
+		date = tf.date
+	}
+
+	search := strings.ToLower(tf.search)
+	var listed []model.Trip
+	for _, id := range sortedKeys(trips) {
+		trip := trips[id]
+		trip.ID = id
+		switch {
+		case len(statuses) > 0 && !statuses[displayStatus(trip)]:
+			continue
+		case tf.owner != "" && trip.CarOwnerID != tf.owner:
+			continue
+		case tf.passenger != "" && !trip.IsEnrolled(tf.passenger):
+			continue
+		case date != "" && trip.StartTime.In(zone).Format("2006-01-02") != date:
+			continue
+		case search != "" && !strings.Contains(strings.ToLower(trip.PickupLocation+" "+trip.AltPickupLocation+" "+trip.Destination), search):
+			continue
+		}
+		listed = append(listed, trip)
+	}
+
+	less := tripOrders[tf.sort]
+	sort.SliceStable(listed, func(i, j int) bool {
+		if tf.reverse {
+			return less(listed[j], listed[i])
+		}
+		return less(listed[i], listed[j])
+	})
+	return listed, nil
+}
+
+// printTrips prints the trips in a table, with their start times in the display zone
+func (c *Console) printTrips(trips []model.Trip) {
+	rows := make([][]string, len(trips))
+	for i, trip := range trips {
+		rows[i] = []string{trip.ID, trip.CarOwnerID, trip.PickupLocation + " → " + trip.Destination, c.formatTime(trip.StartTime),
+			fmt.Sprintf("%d/%d", trip.SeatsLeft(), trip.TotalSeats), displayStatus(trip)}
+	}
+	c.printTable([]string{"ID", "OWNER", "ROUTE", "START", "SEATS", "STATUS"}, rows)
+}
+
+// seriesCSV returns the recurring trips as CSV records, under a header
+func seriesCSV(series ...model.TripSeries) [][]string {
+	records := [][]string{{"id", "car_owner_id", "pickup_location", "alt_pickup_location", "destination", "total_seats",
+		"time_of_day", "time_zone", "days", "until", "skipped_dates"}}
+	for _, s := range series {
+		records = append(records, []string{s.ID, s.CarOwnerID, s.PickupLocation, s.AltPickupLocation, s.Destination,
+			strconv.Itoa(s.TotalSeats), s.TimeOfDay, s.TimeZone, joinList(s.Days), s.Until, joinList(s.SkippedDates)})
+	}
+	return records
+}
+
+// notificationsCSV returns the notifications as CSV records, under a header
+func notificationsCSV(notifications []model.Notification) [][]string {
+	records := [][]string{{"trip_id", "message", "created_at"}}
+	for _, notification := range notifications {
+		records = append(records, []string{notification.TripID, notification.Message, notification.CreatedAt.Format(time.RFC3339)})
+	}
+	return records
+}
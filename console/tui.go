@@ -0,0 +1,981 @@
+package console
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/client"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// The terminal UI shows the users and the trips in tables, with the details of the selected one
+// beside them. Forms to create and change them open in place of the details and check what is
+// typed as it is typed, with the same rules the services follow. The tables are reloaded every
+// RefreshInterval and after every change.
+
+const (
+	usersTab = iota
+	tripsTab
+)
+
+// detailWidth is the width of the details pane, borders included
+const detailWidth = 42
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	tabStyle      = lipgloss.NewStyle().Padding(0, 1)
+	activeTab     = tabStyle.Reverse(true)
+	boxStyle      = lipgloss.NewStyle().Border(lipgloss.RoundedBorder())
+	headerStyle   = lipgloss.NewStyle().Bold(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	faintStyle    = lipgloss.NewStyle().Faint(true)
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	okStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+
+	// statusStyles colour the trip statuses
+	statusStyles = map[string]lipgloss.Style{
+		"scheduled": lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+		"full":      lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		"started":   lipgloss.NewStyle().Foreground(lipgloss.Color("4")),
+		"cancelled": lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+	}
+)
+
+// tuiLoaded carries the users, the trips and the policy, or why they could not be loaded
+type tuiLoaded struct {
+	users  map[string]model.User
+	trips  map[string]model.Trip
+	policy model.Policy
+	err    error
+}
+
+// tuiDone carries the server's answer to a change
+type tuiDone struct {
+	message string
+	err     error
+}
+
+// tuiTick asks for the data to be reloaded
+type tuiTick struct{}
+
+// tui is the Bubble Tea model of the terminal UI
+type tui struct {
+	c      *Console
+	users  map[string]model.User
+	trips  map[string]model.Trip
+	policy model.Policy
+	loaded time.Time
+
+	tab    int
+	cursor [2]int // selected row of each table
+	offset [2]int // first row shown of each table
+
+	form    *form
+	confirm *confirmation
+	status  string
+	failed  bool
+
+	width, height int
+}
+
+// confirmation is a question the user answers with y before a change is sent
+type confirmation struct {
+	question string
+	yes      tea.Cmd
+}
+
+// RunTUI runs the terminal UI on the console's input and output until the user quits
+func (c *Console) RunTUI() error {
+	_, err := tea.NewProgram(newTUI(c), tea.WithAltScreen(), tea.WithInput(c.input), tea.WithOutput(c.out)).Run()
+	return err
+}
+
+func newTUI(c *Console) *tui {
+	return &tui{c: c, width: 100, height: 24}
+}
+
+func (m *tui) Init() tea.Cmd {
+	return tea.Batch(m.load(), m.tick())
+}
+
+// load reads the users, the trips and the policy in the background
+func (m *tui) load() tea.Cmd {
+	api := m.c.api
+	return func() tea.Msg {
+		ctx := context.Background()
+		var loaded tuiLoaded
+		if loaded.users, loaded.err = api.ListUsers(ctx); loaded.err != nil {
+			return loaded
+		}
+		if loaded.trips, loaded.err = api.ListTrips(ctx); loaded.err != nil {
+			return loaded
+		}
+		loaded.policy, loaded.err = api.GetPolicy(ctx)
+		return loaded
+	}
+}
+
+// tick asks for the next reload, unless live refresh is off
+func (m *tui) tick() tea.Cmd {
+	if m.c.RefreshInterval <= 0 {
+		return nil
+	}
+	return tea.Tick(m.c.RefreshInterval, func(time.Time) tea.Msg { return tuiTick{} })
+}
+
+// send makes a change in the background and reports the server's answer
+func send(change func(ctx context.Context) (string, error)) tea.Cmd {
+	return func() tea.Msg {
+		message, err := change(context.Background())
+		return tuiDone{message, err}
+	}
+}
+
+func (m *tui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scroll()
+	case tuiTick:
+		return m, tea.Batch(m.load(), m.tick())
+	case tuiLoaded:
+		if msg.err != nil {
+			m.setStatus("Error loading the users and trips: "+errorText(msg.err), true)
+			return m, nil
+		}
+		m.users, m.trips, m.policy = msg.users, msg.trips, msg.policy
+		m.loaded = m.c.Clock.Now()
+		m.scroll()
+	case tuiDone:
+		if msg.err != nil && m.form != nil {
+			m.form.err, m.form.pending = errorText(msg.err), false
+			return m, nil
+		}
+		m.form = nil
+		if msg.err != nil {
+			m.setStatus(errorText(msg.err), true)
+			return m, nil
+		}
+		m.setStatus(msg.message, false)
+		return m, m.load()
+	case tea.KeyMsg:
+		switch {
+		case msg.String() == "ctrl+c":
+			return m, tea.Quit
+		case m.form != nil:
+			return m, m.formKey(msg)
+		case m.confirm != nil:
+			yes := m.confirm.yes
+			m.confirm = nil
+			if msg.String() == "y" {
+				return m, yes
+			}
+			return m, nil
+		default:
+			return m, m.key(msg)
+		}
+	}
+	return m, nil
+}
+
+// errorText returns the server's explanation of a failed request
+func errorText(err error) string {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Error()
+	}
+	return "Error executing request: " + err.Error()
+}
+
+func (m *tui) setStatus(status string, failed bool) {
+	m.status, m.failed = status, failed
+}
+
+// key carries out the key pressed on the tables
+func (m *tui) key(msg tea.KeyMsg) tea.Cmd {
+	m.setStatus("", false)
+	switch msg.String() {
+	case "q":
+		return tea.Quit
+	case "tab", "shift+tab":
+		m.tab = 1 - m.tab
+	case "1":
+		m.tab = usersTab
+	case "2":
+		m.tab = tripsTab
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "pgup":
+		m.move(-m.pageSize())
+	case "pgdown":
+		m.move(m.pageSize())
+	case "home", "g":
+		m.move(-len(m.rows()))
+	case "end", "G":
+		m.move(len(m.rows()))
+	case "r":
+		return m.load()
+	case "n":
+		if m.tab == usersTab {
+			return m.open(m.userForm(nil))
+		}
+		return m.open(m.tripForm(nil))
+	default:
+		return m.selectedKey(msg.String())
+	}
+	return nil
+}
+
+// selectedKey carries out the keys that act on the selected user or trip
+func (m *tui) selectedKey(key string) tea.Cmd {
+	id := m.selected()
+	if id == "" {
+		return nil
+	}
+	api := m.c.api
+
+	if m.tab == usersTab {
+		user := m.users[id]
+		switch key {
+		case "e", "enter":
+			return m.open(m.userForm(&user))
+		case "d":
+			m.confirm = &confirmation{fmt.Sprintf("Delete user %s? (y/n)", id), send(func(ctx context.Context) (string, error) {
+				return api.DeleteUser(ctx, id)
+			})}
+		case "o":
+			return m.open(m.ownerForm(id))
+		case "p":
+			return send(func(ctx context.Context) (string, error) {
+				return api.BecomePassenger(ctx, id)
+			})
+		}
+		return nil
+	}
+
+	trip := m.trips[id]
+	switch key {
+	case "e", "enter":
+		return m.open(m.tripForm(&trip))
+	case "d":
+		m.confirm = &confirmation{fmt.Sprintf("Cancel trip %s? (y/n)", id), send(func(ctx context.Context) (string, error) {
+			return api.DeleteTrip(ctx, id)
+		})}
+	case "a":
+		return m.open(m.enrollForm(trip))
+	case "s":
+		return m.open(m.startForm(trip))
+	}
+	return nil
+}
+
+// rows returns the IDs of the current table in order
+func (m *tui) rows() []string {
+	if m.tab == usersTab {
+		return sortedKeys(m.users)
+	}
+	return sortedKeys(m.trips)
+}
+
+// selected returns the ID of the selected row, or an empty string if the table is empty
+func (m *tui) selected() string {
+	rows := m.rows()
+	if len(rows) == 0 {
+		return ""
+	}
+	return rows[m.cursor[m.tab]]
+}
+
+// pageSize returns how many rows the tables show
+func (m *tui) pageSize() int {
+	// The tabs, the borders, the header, the status and the keys take six lines
+	if rows := m.height - 6; rows > 3 {
+		return rows
+	}
+	return 3
+}
+
+func (m *tui) move(by int) {
+	m.cursor[m.tab] += by
+	m.scroll()
+}
+
+// scroll keeps the selected rows within the tables and shown
+func (m *tui) scroll() {
+	for tab, count := range [2]int{len(m.users), len(m.trips)} {
+		m.cursor[tab] = max(0, min(m.cursor[tab], count-1))
+		m.offset[tab] = max(0, min(m.offset[tab], m.cursor[tab]), m.cursor[tab]-m.pageSize()+1)
+	}
+}
+
+func (m *tui) View() string {
+	tableWidth := max(20, m.width-detailWidth)
+
+	var table, side string
+	if m.tab == usersTab {
+		table = m.usersTable(tableWidth - 4)
+		side = m.userDetails()
+	} else {
+		table = m.tripsTable(tableWidth - 4)
+		side = m.tripDetails()
+	}
+	if m.form != nil {
+		side = m.form.view(detailWidth - 4)
+	}
+
+	height := m.pageSize() + 1
+	body := lipgloss.JoinHorizontal(lipgloss.Top,
+		boxStyle.Width(tableWidth-2).Height(height).Padding(0, 1).Render(table),
+		boxStyle.Width(detailWidth-2).Height(height).Padding(0, 1).Render(side))
+
+	return strings.Join([]string{m.header(), body, m.statusLine(), faintStyle.Render(m.help())}, "\n")
+}
+
+// header shows the tabs and when the data was loaded
+func (m *tui) header() string {
+	tabs := []string{tabStyle.Render(fmt.Sprintf("1 Users (%d)", len(m.users))), tabStyle.Render(fmt.Sprintf("2 Trips (%d)", len(m.trips)))}
+	tabs[m.tab] = activeTab.Render(strings.TrimSpace(tabs[m.tab]))
+	left := titleStyle.Render("Car-pooling console") + "  " + strings.Join(tabs, " ")
+	right := "loading..."
+	if !m.loaded.IsZero() {
+		right = "updated " + m.loaded.In(m.c.DisplayZone).Format("15:04:05")
+	}
+	gap := max(1, m.width-lipgloss.Width(left)-lipgloss.Width(right))
+	return left + strings.Repeat(" ", gap) + faintStyle.Render(right)
+}
+
+func (m *tui) statusLine() string {
+	switch {
+	case m.confirm != nil:
+		return titleStyle.Render(m.confirm.question)
+	case m.failed:
+		return errorStyle.Render(m.status)
+	default:
+		return okStyle.Render(m.status)
+	}
+}
+
+// help lists the keys that work in the current view
+func (m *tui) help() string {
+	switch {
+	case m.form != nil:
+		return "tab/↑/↓ move · enter next/save · ctrl+s save · esc close"
+	case m.tab == usersTab:
+		return "↑/↓ select · tab trips · n new · e edit · o car owner · p passenger · d delete · r refresh · q quit"
+	default:
+		return "↑/↓ select · tab users · n new · e edit · a enroll · s start · d cancel · r refresh · q quit"
+	}
+}
+
+func (m *tui) usersTable(width int) string {
+	ids := m.rows()
+	rows := make([][]string, len(ids))
+	for i, id := range ids {
+		user := m.users[id]
+		role := "passenger"
+		if user.IsCarOwner {
+			role = "car owner"
+		}
+		rows[i] = []string{id, fullName(user), role, user.MobileNumber}
+	}
+	if len(rows) == 0 {
+		return faintStyle.Render("No users yet, press n to create one")
+	}
+	return m.renderTable([]string{"ID", "NAME", "ROLE", "MOBILE"}, rows, 1, width, nil)
+}
+
+func (m *tui) tripsTable(width int) string {
+	ids := m.rows()
+	rows := make([][]string, len(ids))
+	for i, id := range ids {
+		trip := m.trips[id]
+		rows[i] = []string{id, trip.CarOwnerID, trip.PickupLocation + " → " + trip.Destination,
+			trip.StartTime.In(m.c.DisplayZone).Format("Mon 02 Jan 15:04"),
+			fmt.Sprintf("%d/%d", trip.SeatsLeft(), trip.TotalSeats), displayStatus(trip)}
+	}
+	if len(rows) == 0 {
+		return faintStyle.Render("No trips yet, press n to publish one")
+	}
+	return m.renderTable([]string{"ID", "OWNER", "ROUTE", "START", "SEATS", "STATUS"}, rows, 2, width, func(cell string, column int) string {
+		if column == 5 {
+			return statusStyles[strings.TrimSpace(cell)].Render(cell)
+		}
+		return cell
+	})
+}
+
+// displayStatus is the trip's status, with a scheduled trip without seats left shown as full
+func displayStatus(trip model.Trip) string {
+	status := tripStatus(trip)
+	if status == "scheduled" && trip.SeatsLeft() == 0 {
+		return "full"
+	}
+	return status
+}
+
+// renderTable lays the rows out in columns within width, shortening the flex column if they do
+// not fit, and shows a page of them with the selected row highlighted. style colours a cell.
+func (m *tui) renderTable(header []string, rows [][]string, flex, width int, style func(cell string, column int) string) string {
+	widths := make([]int, len(header))
+	for i, title := range header {
+		widths[i] = lipgloss.Width(title)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], lipgloss.Width(cell))
+		}
+	}
+	total := 2 * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+	if total > width {
+		widths[flex] = max(3, widths[flex]-(total-width))
+	}
+
+	line := func(cells []string, style func(cell string, column int) string) string {
+		parts := make([]string, len(cells))
+		for i, cell := range cells {
+			cell = truncate(cell, widths[i])
+			cell += strings.Repeat(" ", widths[i]-lipgloss.Width(cell))
+			if style != nil {
+				cell = style(cell, i)
+			}
+			parts[i] = cell
+		}
+		return strings.Join(parts, "  ")
+	}
+
+	lines := []string{headerStyle.Render(line(header, nil))}
+	offset := m.offset[m.tab]
+	for i := offset; i < len(rows) && i < offset+m.pageSize(); i++ {
+		if i == m.cursor[m.tab] {
+			lines = append(lines, selectedStyle.Render(line(rows[i], nil)))
+		} else {
+			lines = append(lines, line(rows[i], style))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncate shortens text to width, ending it with an ellipsis
+func truncate(text string, width int) string {
+	if lipgloss.Width(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && lipgloss.Width(string(runes)) > width-1 {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// details lays out the labelled values of the details pane
+func details(title string, fields [][2]string) string {
+	lines := []string{titleStyle.Render(title), ""}
+	for _, field := range fields {
+		lines = append(lines, fmt.Sprintf("%-12s %s", field[0]+":", field[1]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *tui) userDetails() string {
+	id := m.selected()
+	if id == "" {
+		return ""
+	}
+	user := m.users[id]
+	fields := [][2]string{
+		{"Name", fullName(user)},
+		{"Mobile", user.MobileNumber},
+		{"Email", user.Email},
+		{"Role", "passenger"},
+	}
+	if user.IsCarOwner {
+		fields[3][1] = "car owner"
+		fields = append(fields, [2]string{"License", user.DriverLicense}, [2]string{"Plate", user.CarPlateNumber})
+	}
+	if !user.CreatedAt.IsZero() {
+		fields = append(fields, [2]string{"Joined", user.CreatedAt.In(m.c.DisplayZone).Format("2006-01-02")})
+	}
+
+	var driving, riding []string
+	for _, tripID := range sortedKeys(m.trips) {
+		trip := m.trips[tripID]
+		if trip.CarOwnerID == id {
+			driving = append(driving, tripID)
+		}
+		if trip.IsEnrolled(id) {
+			riding = append(riding, tripID)
+		}
+	}
+	if len(driving) > 0 {
+		fields = append(fields, [2]string{"Driving", strings.Join(driving, ", ")})
+	}
+	if len(riding) > 0 {
+		fields = append(fields, [2]string{"Riding in", strings.Join(riding, ", ")})
+	}
+	return details("User "+id, fields)
+}
+
+func (m *tui) tripDetails() string {
+	id := m.selected()
+	if id == "" {
+		return ""
+	}
+	trip := m.trips[id]
+	status := displayStatus(trip)
+	owner := trip.CarOwnerID
+	if user, ok := m.users[owner]; ok {
+		owner += " (" + fullName(user) + ")"
+	}
+	fields := [][2]string{
+		{"Status", statusStyles[status].Render(status)},
+		{"Car owner", owner},
+		{"Pickup", trip.PickupLocation},
+	}
+	if trip.AltPickupLocation != "" {
+		fields = append(fields, [2]string{"Alt pickup", trip.AltPickupLocation})
+	}
+	fields = append(fields,
+		[2]string{"Destination", trip.Destination},
+		[2]string{"Start", m.c.formatTime(trip.StartTime)},
+		[2]string{"Seats left", fmt.Sprintf("%d of %d", trip.SeatsLeft(), trip.TotalSeats)})
+	if trip.SeriesID != "" {
+		fields = append(fields, [2]string{"Series", trip.SeriesID})
+	}
+	if len(trip.EnrolledPassengers) > 0 {
+		fields = append(fields, [2]string{"Passengers", strings.Join(trip.EnrolledPassengers, ", ")})
+	}
+	return details("Trip "+id, fields)
+}
+
+// form is a form shown in place of the details. Each field is checked as it is typed and the
+// form is only sent once they all pass.
+type form struct {
+	title   string
+	fields  []*formField
+	focus   int
+	check   func(f *form) error // checks the fields together before the form is sent
+	submit  func(f *form) tea.Cmd
+	err     string // why the form was not sent, or the server's answer if it rejected it
+	pending bool   // the form has been sent and the answer has not arrived
+}
+
+type formField struct {
+	key, label string
+	input      textinput.Model
+	validate   func(value string, f *form) error
+	touched    bool // the field has been typed in or left, so its error is shown
+	err        error
+}
+
+// field returns a form field starting with value
+func field(key, label, value string, validate func(string, *form) error) *formField {
+	input := textinput.New()
+	input.Prompt = ""
+	input.CharLimit = 100
+	input.Cursor.SetMode(cursor.CursorStatic)
+	input.SetValue(value)
+	return &formField{key: key, label: label, input: input, validate: validate}
+}
+
+// value returns the trimmed value of the field with the key
+func (f *form) value(key string) string {
+	for _, field := range f.fields {
+		if field.key == key {
+			return strings.TrimSpace(field.input.Value())
+		}
+	}
+	return ""
+}
+
+// validate checks every field, it reports whether they all pass
+func (f *form) validate() bool {
+	ok := true
+	for _, field := range f.fields {
+		field.err = nil
+		if field.validate != nil {
+			field.err = field.validate(strings.TrimSpace(field.input.Value()), f)
+		}
+		ok = ok && field.err == nil
+	}
+	return ok
+}
+
+func (f *form) setFocus(focus int) {
+	f.fields[f.focus].input.Blur()
+	f.fields[f.focus].touched = true
+	f.focus = (focus + len(f.fields)) % len(f.fields)
+	f.fields[f.focus].input.Focus()
+	f.validate()
+}
+
+// open shows the form, with the first field focused
+func (m *tui) open(f *form) tea.Cmd {
+	m.form = f
+	f.validate()
+	return f.fields[0].input.Focus()
+}
+
+// formKey passes the key pressed to the open form
+func (m *tui) formKey(msg tea.KeyMsg) tea.Cmd {
+	f := m.form
+	switch msg.String() {
+	case "esc":
+		m.form = nil
+		return nil
+	case "tab", "down":
+		f.setFocus(f.focus + 1)
+		return nil
+	case "shift+tab", "up":
+		f.setFocus(f.focus - 1)
+		return nil
+	case "enter":
+		if f.focus < len(f.fields)-1 {
+			f.setFocus(f.focus + 1)
+			return nil
+		}
+		return m.submit()
+	case "ctrl+s":
+		return m.submit()
+	}
+	if f.pending {
+		return nil
+	}
+
+	field := f.fields[f.focus]
+	var cmd tea.Cmd
+	field.input, cmd = field.input.Update(msg)
+	field.touched = true
+	f.err = ""
+	f.validate()
+	return cmd
+}
+
+// submit sends the form if its fields pass, otherwise it shows their errors
+func (m *tui) submit() tea.Cmd {
+	f := m.form
+	if f.pending {
+		return nil
+	}
+	for _, field := range f.fields {
+		field.touched = true
+	}
+	if !f.validate() {
+		f.err = "Correct the fields marked in red"
+		return nil
+	}
+	if f.check != nil {
+		if err := f.check(f); err != nil {
+			f.err = model.ErrorMessage(err)
+			return nil
+		}
+	}
+	f.err, f.pending = "", true
+	return f.submit(f)
+}
+
+func (f *form) view(width int) string {
+	labelWidth := 0
+	for _, field := range f.fields {
+		labelWidth = max(labelWidth, lipgloss.Width(field.label))
+	}
+
+	lines := []string{titleStyle.Render(f.title), ""}
+	for i, field := range f.fields {
+		field.input.Width = max(4, width-labelWidth-3)
+		marker := " "
+		if i == f.focus {
+			marker = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s %-*s %s", marker, labelWidth, field.label, field.input.View()))
+		if field.touched && field.err != nil {
+			lines = append(lines, errorStyle.Render(strings.Repeat(" ", labelWidth+3)+field.err.Error()))
+		}
+	}
+	switch {
+	case f.pending:
+		lines = append(lines, "", faintStyle.Render("Sending..."))
+	case f.err != "":
+		lines = append(lines, "", errorStyle.Width(width).Render(f.err))
+	}
+	return strings.Join(lines, "\n")
+}
+
+var mobilePattern = regexp.MustCompile(`^\+?[0-9 ]{6,15}$`)
+
+func required(value string, _ *form) error {
+	if value == "" {
+		return errors.New("required")
+	}
+	return nil
+}
+
+func validMobile(value string, f *form) error {
+	if err := required(value, f); err != nil {
+		return err
+	}
+	if !mobilePattern.MatchString(value) {
+		return errors.New("not a phone number")
+	}
+	return nil
+}
+
+func validEmail(value string, f *form) error {
+	if err := required(value, f); err != nil {
+		return err
+	}
+	if address, err := mail.ParseAddress(value); err != nil || address.Address != value || !strings.Contains(value[strings.Index(value, "@"):], ".") {
+		return errors.New("not an email address")
+	}
+	return nil
+}
+
+func validYesNo(value string, _ *form) error {
+	if _, ok := yesNo(value); !ok {
+		return errors.New("y or n")
+	}
+	return nil
+}
+
+// yesNo reads a y/n answer, it reports whether the answer was one
+func yesNo(value string) (yes, ok bool) {
+	switch strings.ToLower(value) {
+	case "y", "yes":
+		return true, true
+	case "n", "no", "":
+		return false, true
+	}
+	return false, false
+}
+
+// carOwnerField checks a car owner detail, which only car owners need
+func carOwnerField(value string, f *form) error {
+	if owner, _ := yesNo(f.value("car_owner")); owner && value == "" {
+		return errors.New("required for car owners")
+	}
+	return nil
+}
+
+func validSeats(value string, _ *form) error {
+	if seats, err := strconv.Atoi(value); err != nil || seats <= 0 {
+		return errors.New("at least 1")
+	}
+	return nil
+}
+
+// newID checks the ID of a user or trip being created
+func newID[V any](existing map[string]V) func(string, *form) error {
+	return func(value string, f *form) error {
+		if err := required(value, f); err != nil {
+			return err
+		}
+		if _, ok := existing[value]; ok {
+			return errors.New("already exists")
+		}
+		return nil
+	}
+}
+
+func (m *tui) existingUser(value string, f *form) error {
+	if err := required(value, f); err != nil {
+		return err
+	}
+	if _, ok := m.users[value]; !ok {
+		return errors.New("no such user")
+	}
+	return nil
+}
+
+func (m *tui) validOwner(value string, f *form) error {
+	if err := m.existingUser(value, f); err != nil {
+		return err
+	}
+	switch err := m.users[value].CheckCarOwner(); {
+	case errors.Is(err, model.ErrNotCarOwner):
+		return errors.New("not a car owner")
+	case err != nil:
+		return errors.New("no license or plate")
+	}
+	return nil
+}
+
+func (m *tui) validStart(value string, _ *form) error {
+	now := m.c.Clock.Now()
+	start, err := parseStartTime(value, now, m.c.DisplayZone)
+	if err != nil {
+		return errors.New("e.g. 08:30, tomorrow 08:15 or 2024-02-01 18:00")
+	}
+	if !m.policy.CanPublish(start, now) {
+		return fmt.Errorf("at least %s from now", model.FormatDuration(m.policy.PublishLeadTime))
+	}
+	return nil
+}
+
+// userForm is the form of a new user, or of the user's changes
+func (m *tui) userForm(user *model.User) *form {
+	f := &form{title: "New user"}
+	id := field("id", "ID", "", newID(m.users))
+	if user != nil {
+		f.title = "Edit user " + user.ID
+	} else {
+		user = &model.User{}
+		f.fields = append(f.fields, id)
+	}
+	owner := ""
+	switch {
+	case user.IsCarOwner:
+		owner = "y"
+	case user.ID != "":
+		owner = "n"
+	}
+	f.fields = append(f.fields,
+		field("first_name", "First name", user.FirstName, required),
+		field("last_name", "Last name", user.LastName, required),
+		field("mobile", "Mobile", user.MobileNumber, validMobile),
+		field("email", "Email", user.Email, validEmail),
+		field("car_owner", "Car owner", owner, validYesNo),
+		field("license", "License", user.DriverLicense, carOwnerField),
+		field("plate", "Plate", user.CarPlateNumber, carOwnerField))
+
+	profile := func() model.User {
+		changed := *user
+		changed.FirstName, changed.LastName = f.value("first_name"), f.value("last_name")
+		changed.MobileNumber, changed.Email = f.value("mobile"), f.value("email")
+		changed.IsCarOwner, _ = yesNo(f.value("car_owner"))
+		changed.DriverLicense, changed.CarPlateNumber = f.value("license"), f.value("plate")
+		if !changed.IsCarOwner {
+			changed.DriverLicense, changed.CarPlateNumber = "", ""
+		}
+		return changed
+	}
+	f.check = func(f *form) error {
+		changed := profile()
+		return changed.Validate()
+	}
+
+	api := m.c.api
+	f.submit = func(f *form) tea.Cmd {
+		changed := profile()
+		changed.Validate()
+		if user.ID == "" {
+			userID := f.value("id")
+			return send(func(ctx context.Context) (string, error) {
+				return api.CreateUser(ctx, userID, changed)
+			})
+		}
+		return send(func(ctx context.Context) (string, error) {
+			return api.UpdateUser(ctx, user.ID, changed)
+		})
+	}
+	return f
+}
+
+// ownerForm asks for the car owner details of a passenger becoming a car owner
+func (m *tui) ownerForm(userID string) *form {
+	f := &form{title: "Make " + userID + " a car owner", fields: []*formField{
+		field("license", "License", "", required),
+		field("plate", "Plate", "", required),
+	}}
+	f.check = func(f *form) error {
+		_, _, err := model.NormaliseCarOwnerDetails(f.value("license"), f.value("plate"))
+		return err
+	}
+	api := m.c.api
+	f.submit = func(f *form) tea.Cmd {
+		license, plate, _ := model.NormaliseCarOwnerDetails(f.value("license"), f.value("plate"))
+		return send(func(ctx context.Context) (string, error) {
+			return api.BecomeCarOwner(ctx, userID, client.BecomeCarOwnerRequest{DriverLicense: license, CarPlateNumber: plate})
+		})
+	}
+	return f
+}
+
+// tripForm is the form of a new trip, or of the trip's changes
+func (m *tui) tripForm(trip *model.Trip) *form {
+	f := &form{title: "New trip"}
+	start := ""
+	if trip != nil {
+		f.title = "Edit trip " + trip.ID
+		start = trip.StartTime.In(m.c.DisplayZone).Format("2006-01-02 15:04")
+	} else {
+		trip = &model.Trip{}
+		f.fields = append(f.fields, field("id", "ID", "", newID(m.trips)))
+	}
+	seats := ""
+	if trip.TotalSeats > 0 {
+		seats = strconv.Itoa(trip.TotalSeats)
+	}
+	f.fields = append(f.fields,
+		field("owner", "Car owner", trip.CarOwnerID, m.validOwner),
+		field("pickup", "Pickup", trip.PickupLocation, required),
+		field("alt_pickup", "Alt pickup", trip.AltPickupLocation, nil),
+		field("destination", "Destination", trip.Destination, required),
+		field("start", "Start", start, m.validStart),
+		field("seats", "Seats", seats, validSeats))
+
+	api := m.c.api
+	f.submit = func(f *form) tea.Cmd {
+		changed := *trip
+		changed.CarOwnerID = f.value("owner")
+		changed.PickupLocation, changed.AltPickupLocation = f.value("pickup"), f.value("alt_pickup")
+		changed.Destination = f.value("destination")
+		changed.StartTime, _ = parseStartTime(f.value("start"), m.c.Clock.Now(), m.c.DisplayZone)
+		changed.TotalSeats, _ = strconv.Atoi(f.value("seats"))
+		changed.UpdateSeats()
+		if trip.ID == "" {
+			tripID := f.value("id")
+			return send(func(ctx context.Context) (string, error) {
+				return api.CreateTrip(ctx, tripID, changed)
+			})
+		}
+		return send(func(ctx context.Context) (string, error) {
+			return api.UpdateTrip(ctx, trip.ID, changed)
+		})
+	}
+	return f
+}
+
+// enrollForm asks for the passenger to enroll in the trip
+func (m *tui) enrollForm(trip model.Trip) *form {
+	f := &form{title: "Enroll in trip " + trip.ID, fields: []*formField{
+		field("user", "Passenger", "", m.existingUser),
+	}}
+	api := m.c.api
+	f.submit = func(f *form) tea.Cmd {
+		userID := f.value("user")
+		return send(func(ctx context.Context) (string, error) {
+			return api.EnrollPassenger(ctx, trip.ID, client.EnrollPassengerRequest{UserID: userID})
+		})
+	}
+	return f
+}
+
+// startForm asks the car owner to confirm starting the trip
+func (m *tui) startForm(trip model.Trip) *form {
+	f := &form{title: "Start trip " + trip.ID, fields: []*formField{
+		field("owner", "Car owner", trip.CarOwnerID, required),
+	}}
+	api := m.c.api
+	f.submit = func(f *form) tea.Cmd {
+		ownerID := f.value("owner")
+		return send(func(ctx context.Context) (string, error) {
+			return api.StartTrip(ctx, trip.ID, ownerID)
+		})
+	}
+	return f
+}
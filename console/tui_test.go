@@ -0,0 +1,201 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/client"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// newTestTUI returns the terminal UI on a console with a car owner O1, a passenger U2 and a trip
+// T1 with one seat, loaded and without live refresh
+func newTestTUI(t *testing.T) *tui {
+	var out bytes.Buffer
+	c, _ := newTestConsole(t, "", &out)
+	c.RefreshInterval = 0
+
+	ctx := context.Background()
+	for id, user := range map[string]model.User{
+		"O1": {FirstName: "John", LastName: "Lim", MobileNumber: "98765432", Email: "john@example.com", IsCarOwner: true, DriverLicense: "S1234567A", CarPlateNumber: "SBA1234A"},
+		"U2": {FirstName: "Jane", LastName: "Tan", MobileNumber: "91234567", Email: "jane@example.com"},
+	} {
+		if _, err := c.api.CreateUser(ctx, id, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trip := model.Trip{CarOwnerID: "O1", PickupLocation: "Ang Mo Kio", Destination: "Ngee Ann Polytechnic",
+		StartTime: testNow.Add(time.Hour), TotalSeats: 1, AvailableSeats: 1}
+	if _, err := c.api.CreateTrip(ctx, "T1", trip); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTUI(c)
+	feed(t, m, m.Init()(), tea.WindowSizeMsg{Width: 140, Height: 30})
+	return m
+}
+
+// feed passes the messages to the terminal UI and runs the commands it returns, until it stops
+// returning any
+func feed(t *testing.T, m *tui, msgs ...tea.Msg) {
+	t.Helper()
+	for len(msgs) > 0 {
+		msg := msgs[0]
+		msgs = msgs[1:]
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for _, cmd := range batch {
+				if cmd != nil {
+					msgs = append(msgs, cmd())
+				}
+			}
+			continue
+		}
+		if _, cmd := m.Update(msg); cmd != nil {
+			msgs = append(msgs, cmd())
+		}
+	}
+}
+
+// keys are the key presses typing text, with a few named keys
+func keys(text ...string) []tea.Msg {
+	named := map[string]tea.KeyType{"tab": tea.KeyTab, "enter": tea.KeyEnter, "esc": tea.KeyEsc,
+		"down": tea.KeyDown, "up": tea.KeyUp, "backspace": tea.KeyBackspace}
+	var msgs []tea.Msg
+	for _, key := range text {
+		if keyType, ok := named[key]; ok {
+			msgs = append(msgs, tea.KeyMsg{Type: keyType})
+		} else {
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		}
+	}
+	return msgs
+}
+
+// checkView fails the test if the screen does not show each of the texts
+func checkView(t *testing.T, m *tui, texts ...string) {
+	t.Helper()
+	view := m.View()
+	for _, text := range texts {
+		if !strings.Contains(view, text) {
+			t.Errorf("the screen does not show %q:\n%s", text, view)
+		}
+	}
+}
+
+func TestTUIShowsTheUsersAndTrips(t *testing.T) {
+	m := newTestTUI(t)
+	checkView(t, m, "1 Users (2)", "O1  John Lim  car owner  98765432", "U2  Jane Tan  passenger  91234567",
+		"User O1", "License:     S1234567A", "Driving:     T1", "updated 08:00:00")
+
+	feed(t, m, keys("j")...)
+	checkView(t, m, "User U2", "Email:       jane@example.com")
+
+	feed(t, m, keys("tab")...)
+	checkView(t, m, "2 Trips (1)", "T1  O1     Ang Mo Kio → Ngee Ann Polytechnic  Mon 15 Jan 09:00  1/1    scheduled",
+		"Trip T1", "Car owner:   O1 (John Lim)", "Start:       2024-01-15 09:00 UTC")
+}
+
+func TestTUICreatesAUser(t *testing.T) {
+	m := newTestTUI(t)
+	feed(t, m, keys("n", "U3", "tab", "Ali", "tab", "Abu", "tab", "9123", "tab", "ali@example")...)
+	checkView(t, m, "New user", "not a phone number", "not an email address")
+
+	// The form is not sent while a field is invalid
+	feed(t, m, tea.KeyMsg{Type: tea.KeyCtrlS})
+	checkView(t, m, "Correct the fields marked in red")
+
+	feed(t, m, keys("up", "backspace", "backspace", "backspace", "backspace", "91230000", "down", ".com", "tab", "y", "enter", "enter", "enter")...)
+	checkView(t, m, "required for car owners")
+
+	feed(t, m, keys("up", "t7654321b", "enter", "sgx 88", "enter")...)
+	checkView(t, m, "User POST U3 successfully", "1 Users (3)", "U3  Ali Abu   car owner  91230000")
+	if user := m.users["U3"]; user.DriverLicense != "T7654321B" || user.CarPlateNumber != "SGX88" {
+		t.Errorf("U3 was created with the license %q and plate %q, want them normalised", user.DriverLicense, user.CarPlateNumber)
+	}
+}
+
+func TestTUIChecksTripsBeforeSendingThem(t *testing.T) {
+	m := newTestTUI(t)
+	feed(t, m, keys("tab", "n", "T1", "tab", "U2", "tab", "tab", "tab", "tab", "08:15", "tab", "0", "tab")...)
+	checkView(t, m, "already exists", "not a car owner", "required", "at least 30m from now", "at least 1")
+
+	feed(t, m, keys("esc", "n", "T2", "tab", "O1", "tab", "Bishan", "tab", "tab", "Orchard", "tab", "tomorrow 08:15", "tab", "3", "enter")...)
+	checkView(t, m, "Trip POST T2 successfully", "T2  O1     Bishan → Orchard", "Tue 16 Jan 08:15  3/3")
+}
+
+func TestTUIEnrollsAndStarts(t *testing.T) {
+	m := newTestTUI(t)
+	feed(t, m, keys("tab", "a", "U9", "enter")...)
+	checkView(t, m, "no such user")
+
+	feed(t, m, keys("backspace", "2", "enter")...)
+	checkView(t, m, "User U2 enrolled in trip T1 successfully", "0/1    full", "Passengers:  U2")
+
+	// A rejected form stays open with the server's answer
+	feed(t, m, keys("a", "O1", "enter")...)
+	checkView(t, m, "Enroll in trip T1", "Error - No seats left on this trip")
+
+	feed(t, m, keys("esc", "s", "enter")...)
+	checkView(t, m, "Error - Trips can only be started from")
+
+	m.c.Clock.(*clock.Fake).Advance(30 * time.Minute)
+	feed(t, m, keys("enter")...)
+	checkView(t, m, "Trip T1 started successfully", "0/1    started")
+}
+
+func TestTUIAsksBeforeCancelling(t *testing.T) {
+	m := newTestTUI(t)
+	feed(t, m, keys("tab", "d")...)
+	checkView(t, m, "Cancel trip T1? (y/n)")
+
+	feed(t, m, keys("n")...)
+	checkView(t, m, "2 Trips (1)")
+
+	feed(t, m, keys("d", "y")...)
+	checkView(t, m, "Trip T1 deleted", "2 Trips (0)", "No trips yet")
+}
+
+func TestTUIRefreshes(t *testing.T) {
+	m := newTestTUI(t)
+	if _, err := m.c.api.EnrollPassenger(context.Background(), "T1", client.EnrollPassengerRequest{UserID: "U2"}); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, m, tuiTick{}, keys("j")[0])
+	checkView(t, m, "Riding in:   T1")
+}
+
+func TestTUIShowsWhyItCannotLoad(t *testing.T) {
+	var out bytes.Buffer
+	c := New(client.New("http://127.0.0.1:1", "console-key", nil), strings.NewReader(""), &out)
+	c.RefreshInterval = 0
+	m := newTUI(c)
+	feed(t, m, m.Init()())
+	checkView(t, m, "Error loading the users and trips: Error executing request:", "No users yet", "loading...")
+}
+
+func TestDisplayStatus(t *testing.T) {
+	tests := []struct {
+		trip model.Trip
+		want string
+	}{
+		{model.Trip{TotalSeats: 2, EnrolledPassengers: []string{"U2"}}, "scheduled"},
+		{model.Trip{TotalSeats: 1, EnrolledPassengers: []string{"U2"}}, "full"},
+		{model.Trip{TotalSeats: 1, EnrolledPassengers: []string{"U2"}, Started: true}, "started"},
+		{model.Trip{TotalSeats: 1, Cancelled: true}, "cancelled"},
+	}
+	for _, test := range tests {
+		got := displayStatus(test.trip)
+		if got != test.want {
+			t.Errorf("displayStatus(%+v) = %q, want %q", test.trip, got, test.want)
+		}
+		if _, ok := statusStyles[got]; !ok {
+			t.Errorf("the status %q has no colour", got)
+		}
+	}
+}
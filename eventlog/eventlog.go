@@ -0,0 +1,212 @@
+// Package eventlog is the append-only log of state changes kept by the car-pooling services.
+// The log is the source of truth: the services rebuild their in-memory views by replaying it
+// on start up, and it can be replayed up to any point in time to see the state as it was then.
+// Events are stored one JSON object per line.
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+)
+
+// Event is a single state change of an entity
+type Event struct {
+	Seq      int64           `json:"seq"`
+	Type     string          `json:"type"`
+	EntityID string          `json:"entity_id"`
+	Time     time.Time       `json:"time"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode unmarshals the event's data into v
+func (e Event) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("decoding %s event %d: %v", e.Type, e.Seq, err)
+	}
+	return nil
+}
+
+// Log is an append-only sequence of events, optionally backed by a file
+type Log struct {
+	// Clock tells the time events are appended at, clock.System if it is nil
+	Clock clock.Clock
+
+	mu     sync.Mutex
+	file   *os.File
+	closed bool
+	events []Event
+}
+
+// Open reads the events already stored in the file at path and opens it for appending,
+// the file is created if it does not exist. An empty path gives a log kept in memory only.
+func Open(path string) (*Log, error) {
+	l := &Log{}
+	if path == "" {
+		return l, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+		}
+		l.events = append(l.events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	l.file = file
+	return l, nil
+}
+
+// Change is an event waiting to be appended, see Append
+type Change struct {
+	Type     string
+	EntityID string
+	Data     interface{}
+}
+
+// Append records a new event for the entity, data is stored as JSON.
+// The event is written to the file before it is returned.
+func (l *Log) Append(eventType, entityID string, data interface{}) (Event, error) {
+	events, err := l.AppendAll([]Change{{Type: eventType, EntityID: entityID, Data: data}})
+	if err != nil {
+		return Event{}, err
+	}
+	return events[0], nil
+}
+
+// AppendAll records the changes as consecutive events. They are written to the file in a single
+// write before they are returned, and none of them are kept if it fails.
+func (l *Log) AppendAll(changes []Change) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, errors.New("the event log is closed")
+	}
+
+	clk := l.Clock
+	if clk == nil {
+		clk = clock.System
+	}
+	now := clk.Now().UTC()
+	events := make([]Event, len(changes))
+	var lines []byte
+	for i, change := range changes {
+		event := Event{
+			Seq:      int64(len(l.events)+i) + 1,
+			Type:     change.Type,
+			EntityID: change.EntityID,
+			Time:     now,
+		}
+		if change.Data != nil {
+			jsonData, err := json.Marshal(change.Data)
+			if err != nil {
+				return nil, err
+			}
+			event.Data = jsonData
+		}
+		events[i] = event
+
+		if l.file != nil {
+			line, err := json.Marshal(event)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(append(lines, line...), '\n')
+		}
+	}
+
+	if l.file != nil {
+		if _, err := l.file.Write(lines); err != nil {
+			return nil, err
+		}
+		if err := l.file.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	l.events = append(l.events, events...)
+	return events, nil
+}
+
+// Events returns every event in the order they were appended
+func (l *Log) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]Event{}, l.events...)
+}
+
+// Until returns the events that were appended at or before t
+func (l *Log) Until(t time.Time) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var events []Event
+	for _, event := range l.events {
+		if event.Time.After(t) {
+			break
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// Check reports whether the log can still be written to, it fails if the file backing it has
+// been removed or replaced since it was opened
+func (l *Log) Check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	opened, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(l.file.Name())
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(opened, current) {
+		return fmt.Errorf("%s was replaced after it was opened", l.file.Name())
+	}
+	return nil
+}
+
+// Close flushes and closes the file backing the log, no events can be appended after it
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	if l.file == nil {
+		return nil
+	}
+	err := errors.Join(l.file.Sync(), l.file.Close())
+	l.file = nil
+	return err
+}
@@ -0,0 +1,165 @@
+package eventlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+)
+
+var testNow = time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+
+// openFile opens the log at path, the log is closed when the test ends
+func openFile(t *testing.T, path string) *Log {
+	t.Helper()
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// describe lists the sequence number, type and entity of each event, e.g. "1 TripPublished T1"
+func describe(events []Event) string {
+	lines := make([]string, len(events))
+	for i, event := range events {
+		lines[i] = fmt.Sprintf("%d %s %s", event.Seq, event.Type, event.EntityID)
+	}
+	return strings.Join(lines, ", ")
+}
+
+func TestReopeningTheLogKeepsItsEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	l := openFile(t, path)
+	if _, err := l.Append("TripPublished", "T1", map[string]int{"total_seats": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Append("TripStarted", "T1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Append("TripDeleted", "T1", nil); err == nil {
+		t.Error("an event was appended to the closed log")
+	}
+
+	// The reopened log has the events that were written, and carries on numbering after them
+	l = openFile(t, path)
+	events := l.Events()
+	if got, want := describe(events), "1 TripPublished T1, 2 TripStarted T1"; got != want {
+		t.Fatalf("the reopened log has %q, want %q", got, want)
+	}
+	var data map[string]int
+	if err := events[0].Decode(&data); err != nil || data["total_seats"] != 3 {
+		t.Errorf("the first event decoded to %v, %v", data, err)
+	}
+	event, err := l.Append("TripDeleted", "T1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Seq != 3 {
+		t.Errorf("the event appended to the reopened log is number %d, want 3", event.Seq)
+	}
+}
+
+func TestOpeningACorruptLogFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, []byte(`{"seq":1,"type":"TripPublished","entity_id":"T1"}`+"\n\nnot json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("opening the corrupt log returned %v, want an error on line 3", err)
+	}
+}
+
+func TestAppendAllIsAllOrNothing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l := openFile(t, path)
+	if _, err := l.Append("TripPublished", "T1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second change cannot be stored as JSON, so the first is not kept either
+	_, err := l.AppendAll([]Change{
+		{Type: "PassengerEnrolled", EntityID: "T1", Data: map[string]string{"user_id": "P1"}},
+		{Type: "PassengerEnrolled", EntityID: "T1", Data: make(chan int)},
+	})
+	if err == nil {
+		t.Fatal("AppendAll stored a change that cannot be encoded")
+	}
+	if got, want := describe(l.Events()), "1 TripPublished T1"; got != want {
+		t.Errorf("after the failed AppendAll the log has %q, want %q", got, want)
+	}
+
+	events, err := l.AppendAll([]Change{
+		{Type: "PassengerEnrolled", EntityID: "T1", Data: map[string]string{"user_id": "P1"}},
+		{Type: "PassengerEnrolled", EntityID: "T1", Data: map[string]string{"user_id": "P2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(events), "2 PassengerEnrolled T1, 3 PassengerEnrolled T1"; got != want {
+		t.Errorf("AppendAll returned %q, want %q", got, want)
+	}
+	l.Close()
+
+	// Nothing of the failed AppendAll reached the file
+	if got, want := describe(openFile(t, path).Events()), "1 TripPublished T1, 2 PassengerEnrolled T1, 3 PassengerEnrolled T1"; got != want {
+		t.Errorf("the file has %q, want %q", got, want)
+	}
+}
+
+func TestEventsAreNumberedInOrder(t *testing.T) {
+	l := openFile(t, "")
+	if _, err := l.Append("UserRegistered", "U1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AppendAll(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AppendAll([]Change{{Type: "UserRegistered", EntityID: "U2"}, {Type: "UserDeleted", EntityID: "U1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Append("UserBecamePassenger", "U2", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := describe(l.Events()), "1 UserRegistered U1, 2 UserRegistered U2, 3 UserDeleted U1, 4 UserBecamePassenger U2"; got != want {
+		t.Errorf("the log has %q, want %q", got, want)
+	}
+}
+
+func TestUntilReturnsTheEventsUpToATime(t *testing.T) {
+	fake := clock.NewFake(testNow)
+	l := openFile(t, "")
+	l.Clock = fake
+
+	for _, entityID := range []string{"U1", "U2", "U3"} {
+		if _, err := l.Append("UserRegistered", entityID, nil); err != nil {
+			t.Fatal(err)
+		}
+		fake.Advance(time.Hour)
+	}
+
+	tests := []struct {
+		until time.Time
+		want  string
+	}{
+		{testNow.Add(-time.Nanosecond), ""},
+		{testNow, "1 UserRegistered U1"},
+		{testNow.Add(90 * time.Minute), "1 UserRegistered U1, 2 UserRegistered U2"},
+		{testNow.Add(2 * time.Hour), "1 UserRegistered U1, 2 UserRegistered U2, 3 UserRegistered U3"},
+	}
+	for _, test := range tests {
+		if got := describe(l.Until(test.until)); got != test.want {
+			t.Errorf("Until(%v) returned %q, want %q", test.until, got, test.want)
+		}
+	}
+}
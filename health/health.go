@@ -0,0 +1,115 @@
+// Package health provides the liveness and readiness endpoints of the car-pooling programs.
+// /healthz only reports that the process is serving requests. /readyz also runs the program's
+// dependency checks, such as its event log and the services it calls, and returns 503 if any fail.
+// Both report the build version and how long the process has been running.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Version is the build version, set with -ldflags "-X github.com/Zachisastudent/ETI_Assignment-1/health.Version=v1.2.3".
+// If it is not set the VCS revision recorded by the Go toolchain is reported instead.
+var Version string
+
+// checkTimeout limits how long the readiness checks may take together
+const checkTimeout = 2 * time.Second
+
+// Check is a named dependency check, it returns nil when the dependency is usable
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Checker serves the health endpoints of a program
+type Checker struct {
+	started time.Time
+	checks  []Check
+}
+
+// NewChecker returns a checker that reports ready when all the checks pass
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{started: time.Now(), checks: checks}
+}
+
+// Status is the body of the health responses
+type Status struct {
+	Status  string            `json:"status"`
+	Version string            `json:"version"`
+	Uptime  string            `json:"uptime"`
+	Checks  map[string]string `json:"checks,omitempty"`
+}
+
+// Live handles GET /healthz, it succeeds as long as the process can serve requests
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, c.status("ok"))
+}
+
+// Ready handles GET /readyz by running every check at the same time
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	status := c.status("ready")
+	status.Checks = map[string]string{}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := "ok"
+			if err := check.Check(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			status.Checks[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, result := range status.Checks {
+		if result != "ok" {
+			status.Status = "not ready"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	writeStatus(w, code, status)
+}
+
+func (c *Checker) status(status string) Status {
+	return Status{
+		Status:  status,
+		Version: version(),
+		Uptime:  time.Since(c.started).Round(time.Second).String(),
+	}
+}
+
+func writeStatus(w http.ResponseWriter, code int, status Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+func version() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "dev"
+}
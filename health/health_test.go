@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// get calls the handler and decodes the status it reports
+func get(t *testing.T, handler http.HandlerFunc) (int, Status) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/", nil))
+
+	if got := recorder.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control is %q, want no-store", got)
+	}
+	var status Status
+	if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, status
+}
+
+func passing(context.Context) error { return nil }
+
+func TestLiveReportsTheVersion(t *testing.T) {
+	defer func(version string) { Version = version }(Version)
+	Version = "v1.2.3"
+
+	// Liveness does not depend on the checks
+	checker := NewChecker(Check{Name: "event_log", Check: func(context.Context) error { return errors.New("removed") }})
+	code, status := get(t, checker.Live)
+	if code != http.StatusOK || status.Status != "ok" || status.Version != "v1.2.3" || status.Uptime == "" || status.Checks != nil {
+		t.Errorf("/healthz returned %d %+v", code, status)
+	}
+}
+
+func TestReadyRunsEveryCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []Check
+		code   int
+		status string
+		want   map[string]string
+	}{
+		{"no checks", nil, http.StatusOK, "ready", map[string]string{}},
+		{"all pass", []Check{{"event_log", passing}, {"trip_service", passing}}, http.StatusOK, "ready",
+			map[string]string{"event_log": "ok", "trip_service": "ok"}},
+		{"one fails", []Check{{"event_log", passing}, {"trip_service", func(context.Context) error { return errors.New("connection refused") }}},
+			http.StatusServiceUnavailable, "not ready", map[string]string{"event_log": "ok", "trip_service": "connection refused"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, status := get(t, NewChecker(test.checks...).Ready)
+			if code != test.code || status.Status != test.status || len(status.Checks) != len(test.want) {
+				t.Fatalf("/readyz returned %d %+v, want %d %q", code, status, test.code, test.status)
+			}
+			for name, want := range test.want {
+				if status.Checks[name] != want {
+					t.Errorf("check %s reported %q, want %q", name, status.Checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadyChecksStopWhenTheRequestIsCancelled(t *testing.T) {
+	// A check that hangs gives up with the request rather than holding up the probe
+	checker := NewChecker(Check{Name: "user_service", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	recorder := httptest.NewRecorder()
+	checker.Ready(recorder, httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx))
+	var status Status
+	json.NewDecoder(recorder.Body).Decode(&status)
+	if recorder.Code != http.StatusServiceUnavailable || status.Checks["user_service"] != context.Canceled.Error() {
+		t.Errorf("/readyz returned %d %+v", recorder.Code, status)
+	}
+}
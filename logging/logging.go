@@ -0,0 +1,157 @@
+// Package logging sets up the structured logging shared by the car-pooling programs.
+// The level is debug, info, warn or error and the format text or json, both are set in the
+// log section of the configuration. Logs are written to standard error.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// New returns a logger with the given level and format and makes it the default slog logger
+func New(program, levelName, format string) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %v", err)
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+
+	logger := slog.New(handler).With("program", program)
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// NewRequestID returns a random 16 character hex ID
+func NewRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(id)
+}
+
+type entryKey struct{}
+
+// entry holds the details a handler adds to the log line of its request
+type entry struct {
+	userID string
+}
+
+// SetUserID records the user a request acts on or for in the request's log line
+func SetUserID(r *http.Request, userID string) {
+	if e, ok := r.Context().Value(entryKey{}).(*entry); ok {
+		e.userID = userID
+	}
+}
+
+// errorBodyLimit is how much of an error response is copied into the log line
+const errorBodyLimit = 200
+
+// Middleware logs one line per request with the method, route template, status, latency, request ID,
+// client ID and user ID. Requests without an X-Request-ID are given one, and the ID is echoed back to
+// the client. Client errors are logged at warn level and server errors at error level, both with the
+// start of the response body, which holds the reason.
+func Middleware(logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get("X-Request-ID")
+			if requestID == "" {
+				requestID = NewRequestID()
+				r.Header.Set("X-Request-ID", requestID)
+			}
+			w.Header().Set("X-Request-ID", requestID)
+
+			e := &entry{}
+			r = r.WithContext(context.WithValue(r.Context(), entryKey{}, e))
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(recorder, r)
+
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", recorder.status),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("request_id", requestID),
+			}
+			if clientID := r.Header.Get("X-Client-ID"); clientID != "" {
+				attrs = append(attrs, slog.String("client_id", clientID))
+			}
+			if e.userID != "" {
+				attrs = append(attrs, slog.String("user_id", e.userID))
+			}
+
+			// Health probes arrive every few seconds, they are only worth logging when they fail
+			level := slog.LevelInfo
+			if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+				level = slog.LevelDebug
+			}
+			if recorder.status >= http.StatusBadRequest {
+				level = slog.LevelWarn
+				if recorder.status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
+				attrs = append(attrs, slog.String("error", strings.TrimSpace(recorder.body.String())))
+			}
+
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+// responseRecorder remembers the status code and the start of the body written by a handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   strings.Builder
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if remaining := errorBodyLimit - r.body.Len(); remaining > 0 {
+		if len(data) < remaining {
+			remaining = len(data)
+		}
+		r.body.Write(data[:remaining])
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, the gateway's proxy flushes through it
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -1,291 +1,386 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/Zachisastudent/ETI_Assignment-1/certs"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+	"github.com/Zachisastudent/ETI_Assignment-1/health"
+	"github.com/Zachisastudent/ETI_Assignment-1/logging"
+	"github.com/Zachisastudent/ETI_Assignment-1/metrics"
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi"
+	"github.com/Zachisastudent/ETI_Assignment-1/resilient"
+	"github.com/Zachisastudent/ETI_Assignment-1/server"
 	"github.com/gorilla/mux"
 )
 
-// User represents a user in the car-pooling platform
-type User struct {
-	ID             string    `json:"id"`
-	FirstName      string    `json:"first_name"`
-	LastName       string    `json:"last_name"`
-	MobileNumber   string    `json:"mobile_number"`
-	Email          string    `json:"email"`
-	DriverLicense  string    `json:"driver_license,omitempty"`
-	CarPlateNumber string    `json:"car_plate_number,omitempty"`
-	IsCarOwner     bool      `json:"is_car_owner"`
-	CreatedAt      time.Time `json:"created_at"`
-}
+// The car-pooling platform runs as two services, the User service (userservice) and the
+// Trip service (tripservice). This gateway keeps port 8222 as the single entry point for
+// the console: it authenticates clients by API key, rate limits each client, logs every
+// request tagged with an X-Request-ID and forwards each route group to the service that owns it.
 
-// Trip represents a car-pooling trip published by a car owner
-type Trip struct {
-	ID                 string    `json:"id"`
-	CarOwnerID         string    `json:"car_owner_id"`
-	PickupLocation     string    `json:"pickup_location"`
-	AltPickupLocation  string    `json:"alt_pickup_location,omitempty"`
-	StartTime          time.Time `json:"start_time"`
-	Destination        string    `json:"destination"`
-	AvailableSeats     int       `json:"available_seats"`
-	EnrolledPassengers []string  `json:"enrolled_passengers,omitempty"`
-	TotalSeats         int       `json:"total_seats"`
-	Started            bool      `json:"started"` // New field
+// gatewayRoute forwards every path under prefix to the service that owns it
+type gatewayRoute struct {
+	prefix  string
+	service string
 }
 
-var (
-	users = map[string]User{}
-	trips = map[string]Trip{}
-)
-
-func main() {
-	r := mux.NewRouter()
-
-	r.HandleFunc("/api/v1/users/{id}", getUser).Methods("GET", "DELETE")
-	r.HandleFunc("/api/v1/users", getAllUsers).Methods("GET")
-	r.HandleFunc("/api/v1/users/{id}", createOrUpdateUser).Methods("POST", "PUT")
-
-	r.HandleFunc("/api/v1/trips/{id}", getTrip).Methods("GET", "DELETE")
-	r.HandleFunc("/api/v1/trips", getAllTrips).Methods("GET")
-	r.HandleFunc("/api/v1/trips/{id}", createOrUpdateTrip).Methods("POST", "PUT")
-	// Add a new route for enrolling passengers
-	r.HandleFunc("/api/v1/trips/{id}/enroll", enrollPassenger).Methods("PUT")
-	r.HandleFunc("/api/v1/trips/{id}/start", startTrip).Methods("PUT")
-
-	fmt.Println("Starting car-pooling server on port 8222")
-	http.ListenAndServe(":8222", r)
+var routes = []gatewayRoute{
+	{"/api/v1/users", "user_service"},
+	{"/api/v1/trips", "trip_service"},
+	{"/api/v1/series", "trip_service"},
+	{"/api/v1/policy", "trip_service"},
 }
 
-// getUser handles GET and DELETE requests for a specific user
-func getUser(w http.ResponseWriter, r *http.Request) {
-	userID := mux.Vars(r)["id"]
-
-	if user, ok := users[userID]; ok {
-		if r.Method == "GET" {
-			json.NewEncoder(w).Encode(user)
-		} else if r.Method == "DELETE" {
-			delete(users, userID)
-			fmt.Fprintf(w, "User %s deleted", userID)
-		}
-	} else {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprint(w, "Invalid user ID")
-	}
+// adminRoutes are forwarded like routes, but only for admin clients
+var adminRoutes = []gatewayRoute{
+	{"/api/v1/admin/users", "user_service"},
+	{"/api/v1/admin/trips", "trip_service"},
 }
 
-// getAllUsers handles GET requests to retrieve all users
-func getAllUsers(w http.ResponseWriter, r *http.Request) {
-	json.NewEncoder(w).Encode(users)
+// auditServices keep the audit logs that are merged by GET /api/v1/audit
+var auditServices = []gatewayRoute{
+	{"/api/v1/audit", "user_service"},
+	{"/api/v1/audit", "trip_service"},
 }
 
-// createOrUpdateUser handles POST and PUT requests to create or update a user
-func createOrUpdateUser(w http.ResponseWriter, r *http.Request) {
-	userID := mux.Vars(r)["id"]
-	var user User
+// serviceURLs are the base URLs of the services keyed by their configuration section
+var serviceURLs map[string]string
 
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&user); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Invalid request payload")
+// clk tells the rate limiter the time, the tests replace it with a clock.Fake
+var clk = clock.System
+
+func main() {
+	cfg, err := config.Load("gateway", flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
 		return
 	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	// Set the creation time if the user is being created
-	if r.Method == "POST" {
-		user.CreatedAt = time.Now()
+	logger, err := logging.New("gateway", cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	// Check if the user is also a car owner
-	if user.IsCarOwner {
-		if user.DriverLicense == "" || user.CarPlateNumber == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, "Driver's license and car plate number are required for car owners")
-			return
-		}
+	// The console connects over HTTPS with an API key, with mutual TLS the gateway also presents
+	// its certificate to the services
+	serverTLS, err := certs.ServerTLS(cfg.TLS, false)
+	if err != nil {
+		logger.Error("setting up TLS", "error", err)
+		os.Exit(1)
+	}
+	clientTLS, err := certs.ClientTLS(cfg.TLS, true)
+	if err != nil {
+		logger.Error("setting up TLS", "error", err)
+		os.Exit(1)
 	}
 
-	users[userID] = user
-	w.WriteHeader(http.StatusAccepted)
-	fmt.Fprintf(w, "User %s %s successfully", r.Method, userID)
-}
+	r, err := newRouter(cfg, logger, certs.Transport(clientTLS))
+	if err != nil {
+		logger.Error("setting up the routes", "error", err)
+		os.Exit(1)
+	}
 
-// getTrip handles GET and DELETE requests for a specific trip
-func getTrip(w http.ResponseWriter, r *http.Request) {
-	tripID := mux.Vars(r)["id"]
+	serverConfig := cfg.HTTP.Server(cfg.Gateway.ListenAddr)
+	serverConfig.TLS = serverTLS
 
-	if trip, ok := trips[tripID]; ok {
-		if r.Method == "GET" {
-			json.NewEncoder(w).Encode(trip)
-		} else if r.Method == "DELETE" {
-			delete(trips, tripID)
-			fmt.Fprintf(w, "Trip %s deleted", tripID)
-		}
-	} else {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprint(w, "Invalid trip ID")
+	logger.Info("starting car-pooling gateway", "addr", serverConfig.Addr, "tls", serverTLS != nil, "mutual_tls", cfg.TLS.Mutual)
+	if err := server.Run(logger, serverConfig, r); err != nil {
+		logger.Error("car-pooling gateway stopped", "error", err)
+		os.Exit(1)
 	}
+	logger.Info("car-pooling gateway stopped")
 }
 
-// getAllTrips handles GET requests to retrieve all trips
-func getAllTrips(w http.ResponseWriter, r *http.Request) {
-	json.NewEncoder(w).Encode(trips)
-}
+// newRouter returns the gateway's routes, calls to the services are made with serviceTransport
+func newRouter(cfg *config.Config, logger *slog.Logger, serviceTransport http.RoundTripper) (*mux.Router, error) {
+	serviceURLs = map[string]string{
+		"user_service": cfg.UserService.URL,
+		"trip_service": cfg.TripService.URL,
+	}
 
-// createOrUpdateTrip handles POST and PUT requests to create or update a trip
-func createOrUpdateTrip(w http.ResponseWriter, r *http.Request) {
-	tripID := mux.Vars(r)["id"]
-	var trip Trip
+	auditConfig := resilient.DefaultConfig()
+	auditConfig.Transport = serviceTransport
+	auditClient = resilient.NewClient("audit", auditConfig)
 
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&trip); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Invalid request payload")
-		return
+	limiter := newRateLimiter(cfg.Gateway.RateLimit, cfg.Gateway.RateBurst)
+
+	adminClients := map[string]bool{}
+	for _, clientID := range cfg.Gateway.AdminClients {
+		adminClients[clientID] = true
 	}
 
-	// Check if the car owner exists
-	carOwner, carOwnerExists := users[trip.CarOwnerID]
-	if !carOwnerExists {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprint(w, "Error - Car owner does not exist")
-		return
+	r := mux.NewRouter()
+
+	// Probes do not have an API key, so the health endpoints sit outside the API's middleware.
+	// The gateway is ready when both services are.
+	checker := health.NewChecker(
+		health.Check{Name: "user_service", Check: serviceReady("user-service", cfg.UserService.URL, serviceTransport)},
+		health.Check{Name: "trip_service", Check: serviceReady("trip-service", cfg.TripService.URL, serviceTransport)},
+	)
+	r.HandleFunc("/healthz", checker.Live).Methods("GET")
+	r.HandleFunc("/readyz", checker.Ready).Methods("GET")
+
+	// The API description is public too
+	r.HandleFunc("/api/v1/openapi.json", openapi.Handler).Methods("GET")
+
+	api := r.NewRoute().Subrouter()
+
+	api.Handle("/api/v1/audit", adminOnly(adminClients, "view the audit log", http.HandlerFunc(getAuditLog))).Methods("GET")
+
+	// Scraped with an API key like any other client, e.g. Prometheus' authorization setting
+	api.Handle("/metrics", metrics.Handler())
+
+	proxies := map[string]*httputil.ReverseProxy{}
+	proxyFor := func(route gatewayRoute) (*httputil.ReverseProxy, error) {
+		serviceURL := serviceURLs[route.service]
+		proxy, ok := proxies[serviceURL]
+		if !ok {
+			target, err := url.Parse(serviceURL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s.url: %v", route.service, err)
+			}
+			proxy = httputil.NewSingleHostReverseProxy(target)
+			proxy.Transport = serviceTransport
+			proxies[serviceURL] = proxy
+		}
+		return proxy, nil
+	}
+	for _, route := range routes {
+		proxy, err := proxyFor(route)
+		if err != nil {
+			return nil, err
+		}
+		api.PathPrefix(route.prefix).Handler(proxy)
+	}
+	for _, route := range adminRoutes {
+		proxy, err := proxyFor(route)
+		if err != nil {
+			return nil, err
+		}
+		api.PathPrefix(route.prefix).Handler(adminOnly(adminClients, "export and import data", proxy))
 	}
 
-	// Check if the car owner is a car owner
-	if !carOwner.IsCarOwner {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Error - Only car owners can create trips")
-		return
+	// Rejected requests are logged too, so the logging middleware runs first
+	api.Use(logging.Middleware(logger), metrics.Middleware, apiKeyMiddleware(cfg.Gateway.APIKeys), limiter.middleware)
+
+	return r, nil
+}
+
+// apiKeyMiddleware authenticates clients by the API key sent as "Authorization: Bearer <key>"
+// or in the X-API-Key header. The key is not passed on, the services receive the client's
+// ID in the X-Client-ID header instead.
+func apiKeyMiddleware(apiKeys map[string]string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if key == "" {
+				key = r.Header.Get("X-API-Key")
+			}
+
+			clientID := ""
+			for id, clientKey := range apiKeys {
+				if subtle.ConstantTimeCompare([]byte(key), []byte(clientKey)) == 1 {
+					clientID = id
+				}
+			}
+			if clientID == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, "Error - Missing or invalid API key")
+				return
+			}
+
+			r.Header.Del("Authorization")
+			r.Header.Del("X-API-Key")
+			r.Header.Set("X-Client-ID", clientID)
+			// The proxy adds the client's address to X-Forwarded-For, which the services audit. An
+			// address the client sent itself is dropped so it cannot pass as its own.
+			r.Header.Del("X-Forwarded-For")
+
+			next.ServeHTTP(w, r)
+		})
 	}
+}
 
-	// Check if the car owner has the required fields if they are a car owner
-	if carOwner.IsCarOwner {
-		if carOwner.DriverLicense == "" || carOwner.CarPlateNumber == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, "Error - Car owner profile incomplete")
+// adminOnly rejects requests from clients that are not listed in gateway.admin_clients, saying
+// they cannot do what the action describes. It runs after apiKeyMiddleware so the client is
+// identified by X-Client-ID.
+func adminOnly(adminClients map[string]bool, action string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !adminClients[r.Header.Get("X-Client-ID")] {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "Error - Only admin clients can %s", action)
 			return
 		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auditClient fetches the audit logs of the services, it is set up in main
+var auditClient *resilient.Client
+
+// getAuditLog handles GET requests to query the audit logs of the services by the entity and id
+// query parameters. The records are merged in time order, format=jsonl exports them as JSON lines.
+func getAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := url.Values{}
+	query.Set("entity", r.URL.Query().Get("entity"))
+	query.Set("id", r.URL.Query().Get("id"))
+
+	records := []audit.Record{}
+	for _, service := range auditServices {
+		serviceRecords, err := fetchAuditLog(serviceURLs[service.service]+service.prefix+"?"+query.Encode(), r.Header.Get("X-Request-ID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprintf(w, "Error - Could not fetch the audit log: %v", err)
+			return
+		}
+		records = append(records, serviceRecords...)
 	}
-// Check if the start time is at least 30 minutes in the future
 
-	if time.Until(trip.StartTime) < 30*time.Minute {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Error - Trips must be scheduled at least 30 minutes in the future")
-		return
+	audit.Sort(records)
+	audit.WriteResponse(w, r, records)
+}
+
+// fetchAuditLog retrieves the matching audit records from a single service
+func fetchAuditLog(auditURL, requestID string) ([]audit.Record, error) {
+	request, err := http.NewRequest("GET", auditURL, nil)
+	if err != nil {
+		return nil, err
 	}
+	request.Header.Set("X-Request-ID", requestID)
 
-	// Update the EnrolledPassengers in the Trips map
-	if existingTrip, ok := trips[tripID]; ok {
-		trip.EnrolledPassengers = existingTrip.EnrolledPassengers
+	response, err := auditClient.Do(request)
+	if err != nil {
+		return nil, err
 	}
+	defer response.Body.Close()
 
-	// Set available seats to the difference between total seats and enrolled passengers
-	trip.AvailableSeats = trip.TotalSeats - len(trip.EnrolledPassengers)
-	if trip.AvailableSeats < 0 {
-		trip.AvailableSeats = 0
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", auditURL, response.Status)
 	}
 
-	trips[tripID] = trip
-	w.WriteHeader(http.StatusAccepted)
-	fmt.Fprintf(w, "Trip %s %s successfully", r.Method, tripID)
+	var records []audit.Record
+	if err := json.NewDecoder(response.Body).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
 }
 
-// enrollPassenger handles the enrollment of passengers in a trip
-func enrollPassenger(w http.ResponseWriter, r *http.Request) {
-    tripID := mux.Vars(r)["id"]
-
-    var enrollmentData map[string]string
-    decoder := json.NewDecoder(r.Body)
-    if err := decoder.Decode(&enrollmentData); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        fmt.Fprint(w, "Invalid request payload")
-        return
-    }
-
-    userID, exists := enrollmentData["user_id"]
-    if !exists {
-        w.WriteHeader(http.StatusBadRequest)
-        fmt.Fprint(w, "Error - User ID is required in the request payload")
-        return
-    }
-
-    if trip, ok := trips[tripID]; ok {
-        // Check if the user already enrolled
-        for _, passengerID := range trip.EnrolledPassengers {
-            if passengerID == userID {
-                w.WriteHeader(http.StatusBadRequest)
-                fmt.Fprint(w, "Error - User already enrolled in this trip")
-                return
-            }
-        }
-
-        // Update the EnrolledPassengers field
-        trip.EnrolledPassengers = append(trip.EnrolledPassengers, userID)
-
-        // Update the trip in the trips map
-        trips[tripID] = trip
-
-        w.WriteHeader(http.StatusAccepted)
-        fmt.Fprintf(w, "User %s enrolled in trip %s successfully", userID, tripID)
-    } else {
-        w.WriteHeader(http.StatusNotFound)
-        fmt.Fprint(w, "Invalid trip ID")
-    }
+// serviceReady returns a check that asks the service at serviceURL whether it is ready.
+// The check is neither retried nor stopped by a circuit breaker, a probe should report what it finds.
+func serviceReady(name, serviceURL string, transport http.RoundTripper) func(ctx context.Context) error {
+	config := resilient.DefaultConfig()
+	config.MaxRetries = 0
+	config.FailureThreshold = 0
+	config.Transport = transport
+	healthClient := resilient.NewClient(name+"-health", config)
+
+	return func(ctx context.Context) error {
+		request, err := http.NewRequestWithContext(ctx, "GET", serviceURL+"/readyz", nil)
+		if err != nil {
+			return err
+		}
+		response, err := healthClient.Do(request)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			var status health.Status
+			json.NewDecoder(response.Body).Decode(&status)
+
+			var failed []string
+			for checkName, result := range status.Checks {
+				if result != "ok" {
+					failed = append(failed, checkName+": "+result)
+				}
+			}
+			sort.Strings(failed)
+			return fmt.Errorf("%s is not ready (%s)", serviceURL, strings.Join(failed, "; "))
+		}
+		return nil
+	}
 }
 
+// rateLimiter gives every client a token bucket that refills at rate tokens per second up to burst tokens
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
 
-// startTrip handles the starting of a trip
-func startTrip(w http.ResponseWriter, r *http.Request) {
-	tripID := mux.Vars(r)["id"]
-
-	// Retrieve the car owner ID from the request header
-	carOwnerID := r.Header.Get("car-owner-id")
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
 
-	// Retrieve the trip based on the tripID
-	trip, ok := trips[tripID]
-	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprint(w, "Invalid trip ID")
-		return
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
 	}
+}
 
-	// Check if the user starting the trip is the car owner
-	if trip.CarOwnerID != carOwnerID {
-		w.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprint(w, "Error - Only the car owner can start the trip")
-		return
-	}
+// Allow takes a token from the client's bucket. If the bucket is empty it returns false and
+// how long until the next token is available.
+func (l *rateLimiter) Allow(clientID string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	// Check if the trip is already started
-	if trip.Started {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Error - Trip is already started")
-		return
+	bucket, ok := l.buckets[clientID]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[clientID] = bucket
 	}
 
-	// Check if the trip has at least one enrolled passenger
-	if len(trip.EnrolledPassengers) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Error - Trip cannot start without any enrolled passengers")
-		return
-	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
 
-	// Check if the start time is within the allowed window
-	if time.Until(trip.StartTime) < 30*time.Minute {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Error - Trip cannot be started more than 30 minutes after scheduled time")
-		return
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
 	}
-
-	// Mark the trip as started
-	trip.Started = true
-	trips[tripID] = trip
-
-	w.WriteHeader(http.StatusAccepted)
-	fmt.Fprintf(w, "Trip %s started successfully", tripID)
+	bucket.tokens--
+	return true, 0
 }
 
+// middleware rejects requests from clients that have used up their rate limit, it runs after
+// apiKeyMiddleware so the client is identified by X-Client-ID
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := l.Allow(r.Header.Get("X-Client-ID"), clk.Now())
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, "Error - Rate limit exceeded, please retry later")
+			return
+		}
 
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi/openapitest"
+	"github.com/gorilla/mux"
+)
+
+// stubResponse is what a stub service answers a request with
+type stubResponse struct {
+	status      int
+	contentType string
+	body        string
+}
+
+// stubService stands in for the User or the Trip service. It answers "METHOD /path" requests
+// with the given responses and remembers the last request the gateway forwarded.
+type stubService struct {
+	*httptest.Server
+	responses map[string]stubResponse
+	last      *http.Request
+}
+
+func newStubService(t *testing.T, responses map[string]stubResponse) *stubService {
+	s := &stubService{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.last = r
+		response, ok := s.responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "Invalid ID")
+			return
+		}
+		if response.contentType != "" {
+			w.Header().Set("Content-Type", response.contentType)
+		}
+		w.WriteHeader(response.status)
+		io.WriteString(w, response.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+const (
+	userJSON   = `{"id":"U1","first_name":"Jane","last_name":"Tan","mobile_number":"91234567","email":"jane@example.com","is_car_owner":false,"created_at":"2024-01-02T03:04:05Z"}`
+	tripJSON   = `{"id":"T1","car_owner_id":"O1","pickup_location":"Ang Mo Kio","start_time":"2024-01-02T03:04:05Z","destination":"Ngee Ann Polytechnic","available_seats":2,"total_seats":3,"started":false,"cancelled":false}`
+	seriesJSON = `{"id":"S1","car_owner_id":"O1","pickup_location":"Ang Mo Kio","destination":"Ngee Ann Polytechnic","total_seats":3,"time_of_day":"08:15","days":["WEEKDAYS"]}`
+	policyJSON = `{"publish_lead_time":"30m0s","start_window_before":"30m0s","start_window_after":"30m0s","cancel_cutoff":"30m0s","enrollment_cutoff":"30m0s"}`
+	auditJSON  = `[{"time":"2024-01-02T03:04:05Z","actor":"console","source_ip":"127.0.0.1","method":"POST","path":"/api/v1/users/U1","status":202,"entity":"user","entity_id":"U1","before":null,"after":{"id":"U1"}}]`
+	reportJSON = `{"dry_run":false,"on_conflict":"fail","created":1,"updated":0,"skipped":0,"failed":0,"rows":[{"row":1,"id":"U1","result":"created"}]}`
+	readyJSON  = `{"status":"ready","version":"dev","uptime":"1s","checks":{"event_log":"ok"}}`
+)
+
+func jsonResponse(body string) stubResponse {
+	return stubResponse{http.StatusOK, "application/json", body}
+}
+
+func textResponse(status int, body string) stubResponse {
+	return stubResponse{status, "text/plain; charset=utf-8", body}
+}
+
+// newTestGateway returns the gateway in front of stub User and Trip services. Client "console"
+// is an admin, client "mobile" is not. configure can change the configuration before the gateway
+// is set up.
+func newTestGateway(t *testing.T, configure ...func(cfg *config.Config)) (*mux.Router, *stubService, *stubService) {
+	t.Helper()
+
+	userService := newStubService(t, map[string]stubResponse{
+		"GET /readyz":                            jsonResponse(readyJSON),
+		"GET /api/v1/audit":                      jsonResponse(auditJSON),
+		"GET /api/v1/users":                      jsonResponse(`{"U1":` + userJSON + `}`),
+		"GET /api/v1/users/U1":                   jsonResponse(userJSON),
+		"POST /api/v1/users/U1":                  textResponse(http.StatusAccepted, "User POST U1 successfully"),
+		"PUT /api/v1/users/U1":                   textResponse(http.StatusAccepted, "User PUT U1 successfully"),
+		"DELETE /api/v1/users/U1":                textResponse(http.StatusOK, "User U1 deleted"),
+		"POST /api/v1/users/U1/become-owner":     textResponse(http.StatusAccepted, "User U1 is now a car owner"),
+		"POST /api/v1/users/U1/become-passenger": textResponse(http.StatusAccepted, "User U1 is now a passenger"),
+		"GET /api/v1/users/U1/notifications":     jsonResponse(`[{"trip_id":"T1","message":"Trip T1 was cancelled","created_at":"2024-01-02T03:04:05Z"}]`),
+		"GET /api/v1/admin/users/export":         jsonResponse(`[` + userJSON + `]`),
+		"POST /api/v1/admin/users/import":        {http.StatusAccepted, "application/json", reportJSON},
+	})
+	tripService := newStubService(t, map[string]stubResponse{
+		"GET /readyz":                     jsonResponse(readyJSON),
+		"GET /api/v1/audit":               jsonResponse(`[]`),
+		"GET /api/v1/trips":               jsonResponse(`{"T1":` + tripJSON + `}`),
+		"GET /api/v1/trips/T1":            jsonResponse(tripJSON),
+		"POST /api/v1/trips/T1":           textResponse(http.StatusAccepted, "Trip POST T1 successfully"),
+		"PUT /api/v1/trips/T1":            textResponse(http.StatusAccepted, "Trip PUT T1 successfully"),
+		"DELETE /api/v1/trips/T1":         textResponse(http.StatusOK, "Trip T1 deleted"),
+		"PUT /api/v1/trips/T1/enroll":     textResponse(http.StatusAccepted, "User U1 enrolled in trip T1 successfully"),
+		"PUT /api/v1/trips/T1/withdraw":   textResponse(http.StatusAccepted, "User U1 withdrew from trip T1 successfully"),
+		"PUT /api/v1/trips/T1/start":      textResponse(http.StatusAccepted, "Trip T1 started successfully"),
+		"GET /api/v1/series":              jsonResponse(`{"S1":` + seriesJSON + `}`),
+		"GET /api/v1/series/S1":           jsonResponse(seriesJSON),
+		"POST /api/v1/series/S1":          textResponse(http.StatusAccepted, "Series POST S1 successfully"),
+		"PUT /api/v1/series/S1":           textResponse(http.StatusAccepted, "Series PUT S1 successfully"),
+		"DELETE /api/v1/series/S1":        textResponse(http.StatusOK, "Series S1 deleted"),
+		"POST /api/v1/series/S1/skip":     textResponse(http.StatusAccepted, "Occurrence 2024-01-03 of series S1 skipped"),
+		"GET /api/v1/policy":              jsonResponse(policyJSON),
+		"GET /api/v1/admin/trips/export":  jsonResponse(`[` + tripJSON + `]`),
+		"POST /api/v1/admin/trips/import": {http.StatusAccepted, "application/json", reportJSON},
+	})
+
+	cfg := config.Default()
+	cfg.Gateway.APIKeys = map[string]string{"console": "console-key", "mobile": "mobile-key"}
+	cfg.Gateway.AdminClients = []string{"console"}
+	cfg.Gateway.RateBurst = 1000
+	cfg.UserService.URL = userService.URL
+	cfg.TripService.URL = tripService.URL
+	for _, change := range configure {
+		change(cfg)
+	}
+
+	r, err := newRouter(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, userService, tripService
+}
+
+// request returns a request from the given client, or without an API key if clientID is empty
+func request(clientID, method, path, body string) *http.Request {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if clientID != "" {
+		r.Header.Set("Authorization", "Bearer "+clientID+"-key")
+	}
+	return r
+}
+
+func TestContract(t *testing.T) {
+	r, _, _ := newTestGateway(t)
+	v := openapitest.New(t)
+	v.CheckRoutes(r)
+
+	const user = `{"first_name":"Jane","last_name":"Tan","mobile_number":"91234567","email":"jane@example.com","is_car_owner":false}`
+	const trip = `{"car_owner_id":"O1","pickup_location":"Ang Mo Kio","start_time":"2024-01-02T03:04:05Z","destination":"Ngee Ann Polytechnic","total_seats":3}`
+	const series = `{"car_owner_id":"O1","pickup_location":"Ang Mo Kio","destination":"Ngee Ann Polytechnic","total_seats":3,"time_of_day":"08:15","days":["WEEKDAYS"]}`
+
+	start := request("console", "PUT", "/api/v1/trips/T1/start", "")
+	start.Header.Set("car-owner-id", "O1")
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{"list users", request("console", "GET", "/api/v1/users", ""), http.StatusOK},
+		{"get a user", request("console", "GET", "/api/v1/users/U1", ""), http.StatusOK},
+		{"get a missing user", request("console", "GET", "/api/v1/users/U9", ""), http.StatusNotFound},
+		{"create a user", request("console", "POST", "/api/v1/users/U1", user), http.StatusAccepted},
+		{"update a user", request("console", "PUT", "/api/v1/users/U1", user), http.StatusAccepted},
+		{"delete a user", request("console", "DELETE", "/api/v1/users/U1", ""), http.StatusOK},
+		{"become a car owner", request("console", "POST", "/api/v1/users/U1/become-owner", `{"driver_license":"S1234567A","car_plate_number":"SBA1234A"}`), http.StatusAccepted},
+		{"become a passenger", request("console", "POST", "/api/v1/users/U1/become-passenger", ""), http.StatusAccepted},
+		{"list notifications", request("console", "GET", "/api/v1/users/U1/notifications", ""), http.StatusOK},
+		{"list trips", request("console", "GET", "/api/v1/trips", ""), http.StatusOK},
+		{"get a trip", request("console", "GET", "/api/v1/trips/T1", ""), http.StatusOK},
+		{"create a trip", request("console", "POST", "/api/v1/trips/T1", trip), http.StatusAccepted},
+		{"update a trip", request("console", "PUT", "/api/v1/trips/T1", trip), http.StatusAccepted},
+		{"delete a trip", request("console", "DELETE", "/api/v1/trips/T1", ""), http.StatusOK},
+		{"enroll a passenger", request("console", "PUT", "/api/v1/trips/T1/enroll", `{"user_id":"U1"}`), http.StatusAccepted},
+		{"withdraw a passenger", request("console", "PUT", "/api/v1/trips/T1/withdraw", `{"user_id":"U1"}`), http.StatusAccepted},
+		{"start a trip", start, http.StatusAccepted},
+		{"list series", request("console", "GET", "/api/v1/series", ""), http.StatusOK},
+		{"get a series", request("console", "GET", "/api/v1/series/S1", ""), http.StatusOK},
+		{"create a series", request("console", "POST", "/api/v1/series/S1", series), http.StatusAccepted},
+		{"update a series", request("console", "PUT", "/api/v1/series/S1", series), http.StatusAccepted},
+		{"delete a series", request("console", "DELETE", "/api/v1/series/S1", ""), http.StatusOK},
+		{"skip a date", request("console", "POST", "/api/v1/series/S1/skip", `{"date":"2024-01-03"}`), http.StatusAccepted},
+		{"get the policy", request("console", "GET", "/api/v1/policy", ""), http.StatusOK},
+		{"query the audit log", request("console", "GET", "/api/v1/audit?entity=user&id=U1", ""), http.StatusOK},
+		{"export the audit log", request("console", "GET", "/api/v1/audit?format=jsonl", ""), http.StatusOK},
+		{"query the audit log as a client", request("mobile", "GET", "/api/v1/audit", ""), http.StatusForbidden},
+		{"export the users", request("console", "GET", "/api/v1/admin/users/export", ""), http.StatusOK},
+		{"import users", request("console", "POST", "/api/v1/admin/users/import", "["+userJSON+"]"), http.StatusAccepted},
+		{"export the trips", request("console", "GET", "/api/v1/admin/trips/export", ""), http.StatusOK},
+		{"import trips", request("console", "POST", "/api/v1/admin/trips/import", "["+tripJSON+"]"), http.StatusAccepted},
+		{"import users as a client", request("mobile", "POST", "/api/v1/admin/users/import", "["+userJSON+"]"), http.StatusForbidden},
+		{"export the trips as a client", request("mobile", "GET", "/api/v1/admin/trips/export", ""), http.StatusForbidden},
+		{"request without an API key", request("", "GET", "/api/v1/users", ""), http.StatusUnauthorized},
+		{"request with an unknown API key", request("nobody", "GET", "/api/v1/users", ""), http.StatusUnauthorized},
+		{"metrics", request("console", "GET", "/metrics", ""), http.StatusOK},
+		{"liveness", request("", "GET", "/healthz", ""), http.StatusOK},
+		{"readiness", request("", "GET", "/readyz", ""), http.StatusOK},
+		{"OpenAPI document", request("", "GET", "/api/v1/openapi.json", ""), http.StatusOK},
+	}
+	for _, test := range tests {
+		method, path := test.request.Method, test.request.URL.Path
+		response := v.Do(r, test.request)
+		if response.StatusCode != test.status {
+			body, _ := io.ReadAll(response.Body)
+			t.Errorf("%s: %s %s returned %d, want %d: %s", test.name, method, path, response.StatusCode, test.status, body)
+		}
+	}
+
+	v.CheckCoverage("users", "notifications", "trips", "series", "policy", "audit", "docs", "health")
+}
+
+func TestRequestsAreForwardedWithTheClientID(t *testing.T) {
+	r, userService, tripService := newTestGateway(t)
+	v := openapitest.New(t)
+
+	request := request("mobile", "GET", "/api/v1/trips/T1", "")
+	request.Header.Set("X-API-Key", "mobile-key")
+	request.Header.Set("X-Forwarded-For", "10.0.0.1")
+	v.Do(r, request)
+
+	forwarded := tripService.last
+	if forwarded == nil || forwarded.URL.Path != "/api/v1/trips/T1" {
+		t.Fatalf("the trip service received %v, want GET /api/v1/trips/T1", forwarded)
+	}
+	if got := forwarded.Header.Get("X-Client-ID"); got != "mobile" {
+		t.Errorf("X-Client-ID is %q, want mobile", got)
+	}
+	if forwarded.Header.Get("Authorization") != "" || forwarded.Header.Get("X-API-Key") != "" {
+		t.Error("the API key was passed on to the service")
+	}
+	// Only the address the gateway saw is passed on, the client could have sent any other
+	if got := forwarded.Header.Get("X-Forwarded-For"); got != "192.0.2.1" {
+		t.Errorf("X-Forwarded-For is %q, want the client's address 192.0.2.1", got)
+	}
+	if userService.last != nil {
+		t.Errorf("the user service received %s %s", userService.last.Method, userService.last.URL.Path)
+	}
+}
+
+// TestCallsBetweenTheServicesAreNotForwarded checks that clients cannot reach the routes the
+// services only call on each other
+func TestCallsBetweenTheServicesAreNotForwarded(t *testing.T) {
+	r, userService, tripService := newTestGateway(t)
+
+	for _, path := range []string{"/api/v1/passengers/U1/notifications", "/api/v1/owners/O1/cancel-trips"} {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, request("console", "POST", path, `{"trip_id":"T1","message":"Trip T1 was cancelled"}`))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("POST %s returned %d, want %d", path, recorder.Code, http.StatusNotFound)
+		}
+	}
+	if userService.last != nil || tripService.last != nil {
+		t.Error("a call between the services was forwarded")
+	}
+}
+
+func TestReadinessReportsAServiceThatIsDown(t *testing.T) {
+	r, _, tripService := newTestGateway(t)
+	v := openapitest.New(t)
+	tripService.Close()
+
+	response := v.Do(r, request("", "GET", "/readyz", ""))
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("readyz returned %d, want %d", response.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestClientsAreRateLimited(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	clk = fake
+	t.Cleanup(func() { clk = clock.System })
+	r, _, _ := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Gateway.RateLimit = 1
+		cfg.Gateway.RateBurst = 2
+	})
+	v := openapitest.New(t)
+
+	tests := []struct {
+		name       string
+		advance    time.Duration
+		clientID   string
+		status     int
+		retryAfter string
+	}{
+		{"first request of the burst", 0, "mobile", http.StatusOK, ""},
+		{"last request of the burst", 0, "mobile", http.StatusOK, ""},
+		{"request after the burst", 0, "mobile", http.StatusTooManyRequests, "1"},
+		{"request from another client", 0, "console", http.StatusOK, ""},
+		{"request before the next token", 999 * time.Millisecond, "mobile", http.StatusTooManyRequests, "1"},
+		{"request once the next token is available", time.Millisecond, "mobile", http.StatusOK, ""},
+		{"request after using the new token", 0, "mobile", http.StatusTooManyRequests, "1"},
+	}
+	for _, test := range tests {
+		fake.Advance(test.advance)
+		response := v.Do(r, request(test.clientID, "GET", "/api/v1/trips", ""))
+		if response.StatusCode != test.status || response.Header.Get("Retry-After") != test.retryAfter {
+			t.Errorf("%s: returned %d with Retry-After %q, want %d with %q",
+				test.name, response.StatusCode, response.Header.Get("Retry-After"), test.status, test.retryAfter)
+		}
+	}
+}
+
+func TestAuditLogReportsAServiceThatIsDown(t *testing.T) {
+	r, _, tripService := newTestGateway(t)
+	v := openapitest.New(t)
+	tripService.Close()
+
+	response := v.Do(r, request("console", "GET", "/api/v1/audit", ""))
+	body, _ := io.ReadAll(response.Body)
+	if response.StatusCode != http.StatusBadGateway || !strings.HasPrefix(string(body), "Error - Could not fetch the audit log") {
+		t.Errorf("the audit log returned %d %q, want %d", response.StatusCode, body, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,76 @@
+// Package metrics holds the Prometheus metrics shared by the car-pooling programs and the
+// /metrics handler that exposes them. Each service adds its own domain metrics next to these.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "carpool_http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests by method, route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	storeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "carpool_store_operation_duration_seconds",
+		Help:    "Time taken by store operations, including appending to the event log for changes.",
+		Buckets: []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5},
+	}, []string{"operation"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records the duration of every request by method, route template and status code
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		// Routes are labelled by template so /trips/{id} is a single series however many trips there are
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		requestDuration.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// StoreTimer starts timing a store operation, the returned function records it when called
+func StoreTimer(operation string) func() {
+	start := time.Now()
+	return func() {
+		storeDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
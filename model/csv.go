@@ -0,0 +1,166 @@
+package model
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Users and trips are exported and imported as CSV with a header row naming the columns after
+// their JSON fields, so a file can be edited in a spreadsheet and read back. Times are RFC 3339
+// and lists, such as the enrolled passengers, are joined with ";".
+var (
+	UserColumns = []string{"id", "first_name", "last_name", "mobile_number", "email", "is_car_owner", "driver_license",
+		"car_plate_number", "created_at"}
+	TripColumns = []string{"id", "car_owner_id", "pickup_location", "alt_pickup_location", "start_time", "destination",
+		"available_seats", "enrolled_passengers", "total_seats", "started", "cancelled", "series_id"}
+)
+
+// UsersCSV returns the users as CSV records, under a header
+func UsersCSV(users ...User) [][]string {
+	records := [][]string{UserColumns}
+	for _, user := range users {
+		records = append(records, []string{user.ID, user.FirstName, user.LastName, user.MobileNumber, user.Email,
+			strconv.FormatBool(user.IsCarOwner), user.DriverLicense, user.CarPlateNumber, user.CreatedAt.Format(time.RFC3339)})
+	}
+	return records
+}
+
+// TripsCSV returns the trips as CSV records, under a header
+func TripsCSV(trips ...Trip) [][]string {
+	records := [][]string{TripColumns}
+	for _, trip := range trips {
+		records = append(records, []string{trip.ID, trip.CarOwnerID, trip.PickupLocation, trip.AltPickupLocation,
+			trip.StartTime.Format(time.RFC3339), trip.Destination, strconv.Itoa(trip.AvailableSeats),
+			strings.Join(trip.EnrolledPassengers, ";"), strconv.Itoa(trip.TotalSeats), strconv.FormatBool(trip.Started),
+			strconv.FormatBool(trip.Cancelled), trip.SeriesID})
+	}
+	return records
+}
+
+// CSVRow is a row of a CSV file keyed by the columns of its header
+type CSVRow map[string]string
+
+// ReadCSV reads the rows under the header of a CSV file. The header must name the id column and
+// may leave out any other of the columns, but not name one that is not among them.
+func ReadCSV(r io.Reader, columns []string) ([]CSVRow, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the CSV has no header")
+	}
+
+	known := map[string]bool{}
+	for _, column := range columns {
+		known[column] = true
+	}
+	header := records[0]
+	named := map[string]bool{}
+	for _, column := range header {
+		if !known[column] {
+			return nil, fmt.Errorf("unknown CSV column %q, want any of %s", column, strings.Join(columns, ", "))
+		}
+		if named[column] {
+			return nil, fmt.Errorf("the CSV column %q appears more than once", column)
+		}
+		named[column] = true
+	}
+	if !named["id"] {
+		return nil, fmt.Errorf("the CSV has no id column")
+	}
+
+	rows := make([]CSVRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := CSVRow{}
+		for i, value := range record {
+			row[header[i]] = strings.TrimSpace(value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (row CSVRow) bool(column string, err *error) bool {
+	if row[column] == "" || *err != nil {
+		return false
+	}
+	value, parseErr := strconv.ParseBool(row[column])
+	if parseErr != nil {
+		*err = fmt.Errorf("invalid %s %q, want true or false", column, row[column])
+	}
+	return value
+}
+
+func (row CSVRow) int(column string, err *error) int {
+	if row[column] == "" || *err != nil {
+		return 0
+	}
+	value, parseErr := strconv.Atoi(row[column])
+	if parseErr != nil {
+		*err = fmt.Errorf("invalid %s %q, want a whole number", column, row[column])
+	}
+	return value
+}
+
+func (row CSVRow) time(column string, err *error) time.Time {
+	if row[column] == "" || *err != nil {
+		return time.Time{}
+	}
+	value, parseErr := time.Parse(time.RFC3339, row[column])
+	if parseErr != nil {
+		*err = fmt.Errorf("invalid %s %q, want an RFC 3339 time such as 2006-01-02T15:04:05Z", column, row[column])
+	}
+	return value
+}
+
+func (row CSVRow) list(column string) []string {
+	var values []string
+	for _, value := range strings.Split(row[column], ";") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// User returns the user in a row read with UserColumns
+func (row CSVRow) User() (User, error) {
+	var err error
+	user := User{
+		ID:             row["id"],
+		FirstName:      row["first_name"],
+		LastName:       row["last_name"],
+		MobileNumber:   row["mobile_number"],
+		Email:          row["email"],
+		IsCarOwner:     row.bool("is_car_owner", &err),
+		DriverLicense:  row["driver_license"],
+		CarPlateNumber: row["car_plate_number"],
+		CreatedAt:      row.time("created_at", &err),
+	}
+	return user, err
+}
+
+// Trip returns the trip in a row read with TripColumns
+func (row CSVRow) Trip() (Trip, error) {
+	var err error
+	trip := Trip{
+		ID:                 row["id"],
+		CarOwnerID:         row["car_owner_id"],
+		PickupLocation:     row["pickup_location"],
+		AltPickupLocation:  row["alt_pickup_location"],
+		StartTime:          row.time("start_time", &err),
+		Destination:        row["destination"],
+		AvailableSeats:     row.int("available_seats", &err),
+		EnrolledPassengers: row.list("enrolled_passengers"),
+		TotalSeats:         row.int("total_seats", &err),
+		Started:            row.bool("started", &err),
+		Cancelled:          row.bool("cancelled", &err),
+		SeriesID:           row["series_id"],
+	}
+	return trip, err
+}
@@ -0,0 +1,149 @@
+// Package model holds the car-pooling types sent over the API and the rules they follow. The services
+// store and return them, and the client package and the console send and decode the same types, so
+// their JSON stays in step. The services enforce the rules in rules.go and the console checks them
+// before sending a request, so both explain a broken rule the same way.
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// User represents a user in the car-pooling platform
+type User struct {
+	ID             string    `json:"id"`
+	FirstName      string    `json:"first_name"`
+	LastName       string    `json:"last_name"`
+	MobileNumber   string    `json:"mobile_number"`
+	Email          string    `json:"email"`
+	DriverLicense  string    `json:"driver_license,omitempty"`
+	CarPlateNumber string    `json:"car_plate_number,omitempty"`
+	IsCarOwner     bool      `json:"is_car_owner"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Notification is a message left for a user about a trip they are enrolled in
+type Notification struct {
+	TripID    string    `json:"trip_id"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Trip represents a car-pooling trip published by a car owner
+type Trip struct {
+	ID                 string    `json:"id"`
+	CarOwnerID         string    `json:"car_owner_id"`
+	PickupLocation     string    `json:"pickup_location"`
+	AltPickupLocation  string    `json:"alt_pickup_location,omitempty"`
+	StartTime          time.Time `json:"start_time"`
+	Destination        string    `json:"destination"`
+	AvailableSeats     int       `json:"available_seats"`
+	EnrolledPassengers []string  `json:"enrolled_passengers,omitempty"`
+	TotalSeats         int       `json:"total_seats"`
+	Started            bool      `json:"started"`
+	Cancelled          bool      `json:"cancelled"`
+	SeriesID           string    `json:"series_id,omitempty"`
+}
+
+// TripSeries is a recurring trip template that publishes concrete trips ahead of time
+type TripSeries struct {
+	ID                string   `json:"id"`
+	CarOwnerID        string   `json:"car_owner_id"`
+	PickupLocation    string   `json:"pickup_location"`
+	AltPickupLocation string   `json:"alt_pickup_location,omitempty"`
+	Destination       string   `json:"destination"`
+	TotalSeats        int      `json:"total_seats"`
+	TimeOfDay         string   `json:"time_of_day"`         // e.g. "08:15"
+	TimeZone          string   `json:"time_zone,omitempty"` // IANA zone the time of day is in, defaults to the display zone
+	Days              []string `json:"days"`                // RRULE BYDAY codes (MO, TU, ...) or "WEEKDAYS"/"DAILY"
+	Until             string   `json:"until,omitempty"`     // last date of the series, e.g. "2006-01-02"
+	SkippedDates      []string `json:"skipped_dates,omitempty"`
+}
+
+// ImportReport is the outcome of importing users or trips in bulk, with the result of each row.
+// Nothing is stored unless every row could be, so when Failed is not zero the other rows only say
+// what would have happened, as they do for a dry run.
+type ImportReport struct {
+	DryRun     bool        `json:"dry_run"`
+	OnConflict string      `json:"on_conflict"`
+	Created    int         `json:"created"`
+	Updated    int         `json:"updated"`
+	Skipped    int         `json:"skipped"`
+	Failed     int         `json:"failed"`
+	Rows       []ImportRow `json:"rows"`
+}
+
+// ImportRow is the result of one row of an import, counted from 1 after any CSV header
+type ImportRow struct {
+	Row    int    `json:"row"`
+	ID     string `json:"id"`
+	Result string `json:"result"` // created, updated, skipped or failed
+	Error  string `json:"error,omitempty"`
+}
+
+// Policy holds the timing rules for publishing, starting, cancelling and enrolling in trips.
+// The Trip service checks every trip against it, clients can read it from GET /api/v1/policy.
+type Policy struct {
+	PublishLeadTime   time.Duration // trips must be published at least this long before departure
+	StartWindowBefore time.Duration // trips can be started from this long before departure...
+	StartWindowAfter  time.Duration // ...until this long after departure
+	CancelCutoff      time.Duration // trips can be cancelled until this long before departure
+	EnrollmentCutoff  time.Duration // passengers can enroll until this long before departure
+}
+
+// CanPublish reports whether a trip departing at start may be published at now
+func (p Policy) CanPublish(start, now time.Time) bool {
+	return start.Sub(now) >= p.PublishLeadTime
+}
+
+// CanStart reports whether a trip departing at start may be started at now
+func (p Policy) CanStart(start, now time.Time) bool {
+	return !now.Before(start.Add(-p.StartWindowBefore)) && !now.After(start.Add(p.StartWindowAfter))
+}
+
+// CanCancel reports whether a trip departing at start may be cancelled at now
+func (p Policy) CanCancel(start, now time.Time) bool {
+	return start.Sub(now) >= p.CancelCutoff
+}
+
+// CanEnroll reports whether a passenger may enroll at now in a trip departing at start
+func (p Policy) CanEnroll(start, now time.Time) bool {
+	return start.Sub(now) >= p.EnrollmentCutoff
+}
+
+// policyFields maps the JSON keys of the policy to its durations
+func (p *Policy) policyFields() map[string]*time.Duration {
+	return map[string]*time.Duration{
+		"publish_lead_time":   &p.PublishLeadTime,
+		"start_window_before": &p.StartWindowBefore,
+		"start_window_after":  &p.StartWindowAfter,
+		"cancel_cutoff":       &p.CancelCutoff,
+		"enrollment_cutoff":   &p.EnrollmentCutoff,
+	}
+}
+
+// MarshalJSON writes the policy durations as duration strings such as "30m0s"
+func (p Policy) MarshalJSON() ([]byte, error) {
+	durations := map[string]string{}
+	for key, field := range p.policyFields() {
+		durations[key] = field.String()
+	}
+	return json.Marshal(durations)
+}
+
+// UnmarshalJSON reads the policy durations written by MarshalJSON
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var durations map[string]string
+	if err := json.Unmarshal(data, &durations); err != nil {
+		return err
+	}
+	for key, field := range p.policyFields() {
+		duration, err := time.ParseDuration(durations[key])
+		if err != nil {
+			return fmt.Errorf("invalid %s in policy: %w", key, err)
+		}
+		*field = duration
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	PublishLeadTime:   30 * time.Minute,
+	StartWindowBefore: 15 * time.Minute,
+	StartWindowAfter:  time.Hour,
+	CancelCutoff:      30 * time.Minute,
+	EnrollmentCutoff:  30 * time.Minute,
+}
+
+func TestUserValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want error
+	}{
+		{"passenger", User{FirstName: "Jane"}, nil},
+		{"car owner", User{IsCarOwner: true, DriverLicense: "s1234567a", CarPlateNumber: "SBA 1234 A"}, nil},
+		{"car owner without a license", User{IsCarOwner: true, CarPlateNumber: "SBA1234A"}, ErrCarOwnerDetailsRequired},
+	}
+	for _, test := range tests {
+		if err := test.user.Validate(); !errors.Is(err, test.want) {
+			t.Errorf("%s: Validate returned %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	user := User{IsCarOwner: true, DriverLicense: " s1234567a", CarPlateNumber: "sba 1234 a"}
+	if err := user.Validate(); err != nil || user.DriverLicense != "S1234567A" || user.CarPlateNumber != "SBA1234A" {
+		t.Errorf("Validate left %q and %q, %v", user.DriverLicense, user.CarPlateNumber, err)
+	}
+	if _, _, err := NormaliseCarOwnerDetails("S1234567A", "1234"); err == nil {
+		t.Error("NormaliseCarOwnerDetails accepted the car plate 1234")
+	}
+}
+
+func TestUserCheckImport(t *testing.T) {
+	user := User{ID: "O1", FirstName: "John", LastName: "Lim", MobileNumber: "98765432", Email: "john@example.com",
+		IsCarOwner: true, DriverLicense: "s1234567a", CarPlateNumber: "SBA1234A"}
+	if err := user.CheckImport(); err != nil || user.DriverLicense != "S1234567A" {
+		t.Errorf("CheckImport returned %v and left the license %q", err, user.DriverLicense)
+	}
+	user.Email = ""
+	if err := user.CheckImport(); !errors.Is(err, ErrUserDetailsRequired) {
+		t.Errorf("CheckImport without an email returned %v, want %v", err, ErrUserDetailsRequired)
+	}
+}
+
+func TestUserCheckCarOwner(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want error
+	}{
+		{"car owner", User{IsCarOwner: true, DriverLicense: "S1234567A", CarPlateNumber: "SBA1234A"}, nil},
+		{"passenger", User{}, ErrNotCarOwner},
+		{"car owner without a plate", User{IsCarOwner: true, DriverLicense: "S1234567A"}, ErrOwnerProfileIncomplete},
+	}
+	for _, test := range tests {
+		if err := test.user.CheckCarOwner(); !errors.Is(err, test.want) {
+			t.Errorf("%s: CheckCarOwner returned %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestTripSeats(t *testing.T) {
+	trip := Trip{TotalSeats: 2, EnrolledPassengers: []string{"P1"}}
+	trip.UpdateSeats()
+	if trip.AvailableSeats != 1 || !trip.IsEnrolled("P1") || trip.IsEnrolled("P2") {
+		t.Errorf("unexpected seats %+v", trip)
+	}
+
+	// A trip whose seats were reduced below its passengers has none left rather than a negative number
+	trip.TotalSeats = 0
+	trip.UpdateSeats()
+	if trip.AvailableSeats != 0 {
+		t.Errorf("AvailableSeats is %d, want 0", trip.AvailableSeats)
+	}
+}
+
+func TestTripRules(t *testing.T) {
+	now := time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)
+	trip := Trip{CarOwnerID: "O1", PickupLocation: "Ang Mo Kio", Destination: "Ngee Ann Polytechnic", StartTime: now.Add(time.Hour), TotalSeats: 1}
+	with := func(change func(*Trip)) Trip {
+		changed := trip
+		change(&changed)
+		return changed
+	}
+	enrolled := with(func(t *Trip) { t.EnrolledPassengers = []string{"P1"} })
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"publish", trip.CheckPublish(testPolicy, now), nil},
+		{"publish without seats", with(func(t *Trip) { t.TotalSeats = 0 }).CheckPublish(testPolicy, now), ErrNoSeats},
+		{"publish without a destination", with(func(t *Trip) { t.Destination = "" }).CheckPublish(testPolicy, now), ErrTripDetailsRequired},
+		{"publish 29 minutes ahead", trip.CheckPublish(testPolicy, trip.StartTime.Add(-29*time.Minute)), ErrPublishTooLate},
+		{"publish 30 minutes ahead", trip.CheckPublish(testPolicy, trip.StartTime.Add(-30*time.Minute)), nil},
+		{"update", trip.CheckUpdate(trip, testPolicy, now), nil},
+		{"update a started trip", with(func(t *Trip) { t.Started = true }).CheckUpdate(trip, testPolicy, now), ErrTripStarted},
+		{"update a cancelled trip", with(func(t *Trip) { t.Cancelled = true }).CheckUpdate(trip, testPolicy, now), ErrTripCancelled},
+		{"update to as many seats as passengers", enrolled.CheckUpdate(enrolled, testPolicy, now), nil},
+		{"update to fewer seats than passengers", enrolled.CheckUpdate(with(func(t *Trip) { t.EnrolledPassengers = []string{"P1", "P2"} }), testPolicy, now), ErrTooManyPassengers},
+		{"update to 29 minutes ahead", trip.CheckUpdate(trip, testPolicy, trip.StartTime.Add(-29*time.Minute)), ErrPublishTooLate},
+		{"enroll", trip.CheckEnroll("P1", testPolicy, now), nil},
+		{"enroll twice", enrolled.CheckEnroll("P1", testPolicy, now), ErrAlreadyEnrolled},
+		{"enroll in a full trip", enrolled.CheckEnroll("P2", testPolicy, now), ErrTripFull},
+		{"enroll in a cancelled trip", with(func(t *Trip) { t.Cancelled = true }).CheckEnroll("P1", testPolicy, now), ErrTripCancelled},
+		{"enroll after the cut-off", trip.CheckEnroll("P1", testPolicy, trip.StartTime.Add(-29*time.Minute)), ErrEnrollmentClosed},
+		{"withdraw", enrolled.CheckWithdraw("P1", testPolicy, now), nil},
+		{"withdraw without enrolling", enrolled.CheckWithdraw("P2", testPolicy, now), ErrNotEnrolled},
+		{"withdraw from a started trip", with(func(t *Trip) { t.EnrolledPassengers = []string{"P1"}; t.Started = true }).CheckWithdraw("P1", testPolicy, now), ErrTripStarted},
+		{"withdraw after the cut-off", enrolled.CheckWithdraw("P1", testPolicy, trip.StartTime.Add(-29*time.Minute)), ErrWithdrawalClosed},
+		{"start", enrolled.CheckStart("O1", testPolicy, trip.StartTime), nil},
+		{"start as a passenger", enrolled.CheckStart("P1", testPolicy, trip.StartTime), ErrNotTripOwner},
+		{"start without passengers", trip.CheckStart("O1", testPolicy, trip.StartTime), ErrNoPassengers},
+		{"start a started trip", with(func(t *Trip) { t.Started = true }).CheckStart("O1", testPolicy, trip.StartTime), ErrTripStarted},
+		{"start at the start of the window", enrolled.CheckStart("O1", testPolicy, trip.StartTime.Add(-15*time.Minute)), nil},
+		{"start before the window", enrolled.CheckStart("O1", testPolicy, trip.StartTime.Add(-16*time.Minute)), ErrOutsideStartWindow},
+		{"start after the window", enrolled.CheckStart("O1", testPolicy, trip.StartTime.Add(61*time.Minute)), ErrOutsideStartWindow},
+		{"cancel", trip.CheckCancel(testPolicy, now), nil},
+		{"cancel a started trip", with(func(t *Trip) { t.Started = true }).CheckCancel(testPolicy, now), ErrTripStarted},
+		{"cancel after the cut-off", trip.CheckCancel(testPolicy, trip.StartTime.Add(-29*time.Minute)), ErrCancelTooLate},
+		{"import a past trip", with(func(t *Trip) { t.StartTime = now.Add(-time.Hour); t.Started = true }).CheckImport(), nil},
+		{"import without seats", with(func(t *Trip) { t.TotalSeats = 0 }).CheckImport(), ErrNoSeats},
+		{"import more passengers than seats", with(func(t *Trip) { t.EnrolledPassengers = []string{"P1", "P2"} }).CheckImport(), ErrTooManyPassengers},
+		{"import a passenger twice", with(func(t *Trip) { t.TotalSeats = 2; t.EnrolledPassengers = []string{"P1", "P1"} }).CheckImport(), ErrAlreadyEnrolled},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, test.err, test.want)
+		}
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	trip := Trip{StartTime: time.Now(), EnrolledPassengers: []string{"P1"}}
+	err := trip.CheckStart("", testPolicy, time.Now().Add(2*time.Hour))
+	if got, want := ErrorMessage(err), "Error - Trips can only be started from 15m before until 1h after the scheduled time"; got != want {
+		t.Errorf("ErrorMessage returned %q, want %q", got, want)
+	}
+}
+
+func TestPolicyJSON(t *testing.T) {
+	data, err := json.Marshal(testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil || policy != testPolicy {
+		t.Errorf("the policy came back as %+v, %v from %s", policy, err, data)
+	}
+	if err := json.Unmarshal([]byte(`{"publish_lead_time":"soon"}`), &policy); err == nil {
+		t.Error("an invalid duration was accepted")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := User{ID: "O1", FirstName: "John", LastName: "Lim", MobileNumber: "98765432", Email: "john@example.com",
+		IsCarOwner: true, DriverLicense: "S1234567A", CarPlateNumber: "SBA1234A", CreatedAt: created}
+	trip := Trip{ID: "T1", CarOwnerID: "O1", PickupLocation: "Ang Mo Kio", StartTime: created, Destination: "Orchard, Singapore",
+		AvailableSeats: 1, EnrolledPassengers: []string{"U2", "U3"}, TotalSeats: 3, Started: true, SeriesID: "S1"}
+
+	// Written and read back, the records are unchanged
+	var out bytes.Buffer
+	csv.NewWriter(&out).WriteAll(UsersCSV(user))
+	rows, err := ReadCSV(&out, UserColumns)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("ReadCSV returned %v, %v", rows, err)
+	}
+	if got, err := rows[0].User(); err != nil || got != user {
+		t.Errorf("the user came back as %+v, %v", got, err)
+	}
+	csv.NewWriter(&out).WriteAll(TripsCSV(trip))
+	if rows, err = ReadCSV(&out, TripColumns); err != nil || len(rows) != 1 {
+		t.Fatalf("ReadCSV returned %v, %v", rows, err)
+	}
+	if got, err := rows[0].Trip(); err != nil || !reflect.DeepEqual(got, trip) {
+		t.Errorf("the trip came back as %+v, %v", got, err)
+	}
+
+	// Columns can be left out, but not misnamed, and the values must parse
+	if rows, err = ReadCSV(strings.NewReader("first_name,id\nJane,U2\n"), UserColumns); err != nil || rows[0]["id"] != "U2" {
+		t.Errorf("ReadCSV returned %v, %v", rows, err)
+	}
+	for _, text := range []string{"", "first_name\nJane\n", "id,name\nU2,Jane\n", "id,id\nU2,U2\n", "id,email\nU2\n"} {
+		if _, err := ReadCSV(strings.NewReader(text), UserColumns); err == nil {
+			t.Errorf("ReadCSV accepted %q", text)
+		}
+	}
+	if _, err := (CSVRow{"id": "U2", "is_car_owner": "maybe"}).User(); err == nil || err.Error() != `invalid is_car_owner "maybe", want true or false` {
+		t.Errorf("User returned %v for is_car_owner maybe", err)
+	}
+	if _, err := (CSVRow{"id": "T2", "total_seats": "three"}).Trip(); err == nil {
+		t.Error("Trip accepted total_seats three")
+	}
+}
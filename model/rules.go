@@ -0,0 +1,288 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Errors for requests that break the car-pooling rules, the errors returned by the checks below
+// match them with errors.Is. ErrorMessage turns them into the text the programs show.
+var (
+	ErrCarOwnerDetailsRequired = errors.New("driver's license and car plate number are required for car owners")
+	ErrNotCarOwner             = errors.New("only car owners can create trips")
+	ErrOwnerProfileIncomplete  = errors.New("car owner profile incomplete")
+	ErrAccountTooNew           = errors.New("account is too new to delete")
+	ErrTripDetailsRequired     = errors.New("car owner, pickup location, destination and start time are required")
+	ErrNoSeats                 = errors.New("total seats must be greater than zero")
+	ErrPublishTooLate          = errors.New("trip is scheduled too soon")
+	ErrTripCancelled           = errors.New("trip has been cancelled")
+	ErrEnrollmentClosed        = errors.New("enrollment has closed")
+	ErrAlreadyEnrolled         = errors.New("user already enrolled in this trip")
+	ErrTripFull                = errors.New("no seats left on this trip")
+	ErrNotEnrolled             = errors.New("user is not enrolled in this trip")
+	ErrWithdrawalClosed        = errors.New("withdrawals have closed")
+	ErrNotTripOwner            = errors.New("only the car owner can start the trip")
+	ErrTripStarted             = errors.New("trip is already started")
+	ErrNoPassengers            = errors.New("trip cannot start without any enrolled passengers")
+	ErrOutsideStartWindow      = errors.New("trip is outside its start window")
+	ErrCancelTooLate           = errors.New("trip is too close to its scheduled time to cancel")
+	ErrUserDetailsRequired     = errors.New("first name, last name, mobile number and email are required")
+	ErrTooManyPassengers       = errors.New("more passengers are enrolled than the trip has seats")
+)
+
+var (
+	driverLicensePattern = regexp.MustCompile(`^[A-Z0-9]{6,15}$`)
+	carPlatePattern      = regexp.MustCompile(`^[A-Z]{1,3}[0-9]{1,4}[A-Z]?$`)
+)
+
+// ruleError is a broken rule whose message gives the details, such as the policy's durations
+type ruleError struct {
+	err     error
+	message string
+}
+
+func (e *ruleError) Error() string { return e.message }
+func (e *ruleError) Unwrap() error { return e.err }
+
+func breaks(err error, format string, args ...interface{}) error {
+	return &ruleError{err: err, message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorMessage returns the text shown for a broken rule, e.g. "Error - Trip is already started"
+func ErrorMessage(err error) string {
+	message := err.Error()
+	return "Error - " + strings.ToUpper(message[:1]) + message[1:]
+}
+
+// FormatDuration shortens a duration for messages, e.g. "30m" instead of "30m0s"
+func FormatDuration(d time.Duration) string {
+	text := d.String()
+	text = strings.TrimSuffix(text, "m0s")
+	if text != d.String() {
+		text += "m"
+	}
+	return strings.Replace(text, "h0m", "h", 1)
+}
+
+// formatRetention describes the retention period in years or days where it can, e.g. "1 year"
+func formatRetention(retention time.Duration) string {
+	const day = 24 * time.Hour
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	switch {
+	case retention > 0 && retention%(365*day) == 0:
+		return plural(int64(retention/(365*day)), "year")
+	case retention > 0 && retention%day == 0:
+		return plural(int64(retention/day), "day")
+	default:
+		return retention.String()
+	}
+}
+
+// NormaliseCarOwnerDetails upper-cases a driver's license and car plate number, drops their spaces
+// and checks their format
+func NormaliseCarOwnerDetails(license, plate string) (string, string, error) {
+	license = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(license), " ", ""))
+	plate = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(plate), " ", ""))
+
+	if license == "" || plate == "" {
+		return "", "", ErrCarOwnerDetailsRequired
+	}
+	if !driverLicensePattern.MatchString(license) {
+		return "", "", fmt.Errorf("invalid driver's license number %q", license)
+	}
+	if !carPlatePattern.MatchString(plate) {
+		return "", "", fmt.Errorf("invalid car plate number %q", plate)
+	}
+
+	return license, plate, nil
+}
+
+// Validate checks a user profile, normalising the car owner details of a car owner
+func (u *User) Validate() error {
+	if !u.IsCarOwner {
+		return nil
+	}
+	license, plate, err := NormaliseCarOwnerDetails(u.DriverLicense, u.CarPlateNumber)
+	if err != nil {
+		return err
+	}
+	u.DriverLicense, u.CarPlateNumber = license, plate
+	return nil
+}
+
+// CheckImport checks a user read from an export, which must have a full profile, normalising the
+// car owner details of a car owner
+func (u *User) CheckImport() error {
+	if u.FirstName == "" || u.LastName == "" || u.MobileNumber == "" || u.Email == "" {
+		return ErrUserDetailsRequired
+	}
+	return u.Validate()
+}
+
+// CheckCarOwner reports why the user cannot publish trips, or nil if they can
+func (u User) CheckCarOwner() error {
+	if !u.IsCarOwner {
+		return ErrNotCarOwner
+	}
+	if u.DriverLicense == "" || u.CarPlateNumber == "" {
+		return ErrOwnerProfileIncomplete
+	}
+	return nil
+}
+
+// CheckDelete reports why the account cannot be deleted at now, or nil if it has been kept for
+// the retention period
+func (u User) CheckDelete(retention time.Duration, now time.Time) error {
+	if now.Sub(u.CreatedAt) < retention {
+		return breaks(ErrAccountTooNew, "account cannot be deleted before %s", formatRetention(retention))
+	}
+	return nil
+}
+
+// SeatsLeft returns the seats not taken by the enrolled passengers
+func (t Trip) SeatsLeft() int {
+	if seats := t.TotalSeats - len(t.EnrolledPassengers); seats > 0 {
+		return seats
+	}
+	return 0
+}
+
+// UpdateSeats sets the available seats from the total seats and the enrolled passengers
+func (t *Trip) UpdateSeats() {
+	t.AvailableSeats = t.SeatsLeft()
+}
+
+// IsEnrolled reports whether the user is one of the trip's passengers
+func (t Trip) IsEnrolled(userID string) bool {
+	for _, passengerID := range t.EnrolledPassengers {
+		if passengerID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that a trip has the details needed to publish it
+func (t Trip) Validate() error {
+	if t.CarOwnerID == "" || t.PickupLocation == "" || t.Destination == "" || t.StartTime.IsZero() {
+		return ErrTripDetailsRequired
+	}
+	if t.TotalSeats <= 0 {
+		return ErrNoSeats
+	}
+	return nil
+}
+
+// CheckImport reports why a trip read from an export cannot be imported. Imported trips keep their
+// start time, passengers and status, so the publishing and enrollment deadlines do not apply.
+func (t Trip) CheckImport() error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if len(t.EnrolledPassengers) > t.TotalSeats {
+		return ErrTooManyPassengers
+	}
+	enrolled := map[string]bool{}
+	for _, passengerID := range t.EnrolledPassengers {
+		if enrolled[passengerID] {
+			return breaks(ErrAlreadyEnrolled, "user %s is enrolled more than once", passengerID)
+		}
+		enrolled[passengerID] = true
+	}
+	return nil
+}
+
+// CheckPublish reports why the trip cannot be published at now, or nil if it can
+func (t Trip) CheckPublish(policy Policy, now time.Time) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if !policy.CanPublish(t.StartTime, now) {
+		return breaks(ErrPublishTooLate, "trips must be scheduled at least %s in the future", FormatDuration(policy.PublishLeadTime))
+	}
+	return nil
+}
+
+// CheckUpdate reports why the trip cannot be replaced by update at now, or nil if it can. Started
+// and cancelled trips cannot be changed any more, and update keeps the trip's passengers so it
+// needs a seat for each of them.
+func (t Trip) CheckUpdate(update Trip, policy Policy, now time.Time) error {
+	switch {
+	case t.Started:
+		return breaks(ErrTripStarted, "trip is already started and cannot be changed")
+	case t.Cancelled:
+		return breaks(ErrTripCancelled, "trip has been cancelled and cannot be changed")
+	case update.TotalSeats < len(update.EnrolledPassengers):
+		return breaks(ErrTooManyPassengers, "total seats cannot be fewer than the %d passengers enrolled", len(update.EnrolledPassengers))
+	}
+	return update.CheckPublish(policy, now)
+}
+
+// CheckEnroll reports why the user cannot enroll in the trip at now, or nil if they can
+func (t Trip) CheckEnroll(userID string, policy Policy, now time.Time) error {
+	switch {
+	case t.Cancelled:
+		return ErrTripCancelled
+	case !policy.CanEnroll(t.StartTime, now):
+		return breaks(ErrEnrollmentClosed, "enrollment closes %s before the scheduled time", FormatDuration(policy.EnrollmentCutoff))
+	case t.IsEnrolled(userID):
+		return ErrAlreadyEnrolled
+	case t.SeatsLeft() == 0:
+		return ErrTripFull
+	}
+	return nil
+}
+
+// CheckWithdraw reports why the user cannot withdraw from the trip at now, or nil if they can.
+// Passengers can withdraw until the enrollment cut-off, the same time enrollment closes.
+func (t Trip) CheckWithdraw(userID string, policy Policy, now time.Time) error {
+	switch {
+	case t.Started:
+		return ErrTripStarted
+	case t.Cancelled:
+		return ErrTripCancelled
+	case !t.IsEnrolled(userID):
+		return ErrNotEnrolled
+	case !policy.CanEnroll(t.StartTime, now):
+		return breaks(ErrWithdrawalClosed, "withdrawals close %s before the scheduled time", FormatDuration(policy.EnrollmentCutoff))
+	}
+	return nil
+}
+
+// CheckStart reports why the car owner cannot start the trip at now, or nil if they can
+func (t Trip) CheckStart(carOwnerID string, policy Policy, now time.Time) error {
+	switch {
+	case t.CarOwnerID != carOwnerID:
+		return ErrNotTripOwner
+	case t.Started:
+		return ErrTripStarted
+	case t.Cancelled:
+		return ErrTripCancelled
+	case len(t.EnrolledPassengers) == 0:
+		return ErrNoPassengers
+	case !policy.CanStart(t.StartTime, now):
+		return breaks(ErrOutsideStartWindow, "trips can only be started from %s before until %s after the scheduled time",
+			FormatDuration(policy.StartWindowBefore), FormatDuration(policy.StartWindowAfter))
+	}
+	return nil
+}
+
+// CheckCancel reports why the trip cannot be cancelled at now, or nil if it can
+func (t Trip) CheckCancel(policy Policy, now time.Time) error {
+	if t.Started {
+		return breaks(ErrTripStarted, "trip is already started and cannot be canceled")
+	}
+	if !policy.CanCancel(t.StartTime, now) {
+		return breaks(ErrCancelTooLate, "trips cannot be canceled less than %s before the scheduled time", FormatDuration(policy.CancelCutoff))
+	}
+	return nil
+}
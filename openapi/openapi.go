@@ -0,0 +1,20 @@
+// Package openapi holds the OpenAPI 3 document describing the car-pooling API. The gateway serves it
+// at /api/v1/openapi.json. The document is kept by hand next to the handlers, and the contract tests
+// of the gateway and the services check every request and response they make against it.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Document is the OpenAPI document as JSON
+//
+//go:embed openapi.json
+var Document []byte
+
+// Handler handles GET requests for the OpenAPI document
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Document)
+}
@@ -0,0 +1,212 @@
+// Package openapitest checks HTTP exchanges against the OpenAPI document in tests. A Validator
+// sends each request to a handler with httptest, fails the test if the request or the response
+// does not match the document, and records which documented operations have been exercised.
+package openapitest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+)
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", decodeJSONLines)
+}
+
+// decodeJSONLines decodes a body of JSON lines as an array of the values, one per line
+func decodeJSONLines(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (interface{}, error) {
+	values := []interface{}{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var value interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, scanner.Err()
+}
+
+// Validator checks requests and responses against the OpenAPI document
+type Validator struct {
+	t         testing.TB
+	doc       *openapi3.T
+	router    routers.Router
+	exercised map[string]bool // operation IDs
+}
+
+// New loads the OpenAPI document and fails the test if it is not valid
+func New(t testing.TB) *Validator {
+	t.Helper()
+
+	doc, err := openapi3.NewLoader().LoadFromData(openapi.Document)
+	if err != nil {
+		t.Fatalf("loading the OpenAPI document: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("the OpenAPI document is not valid: %v", err)
+	}
+
+	// Requests are matched on their path only, whichever server they are sent to
+	doc.Servers = nil
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("routing the OpenAPI document: %v", err)
+	}
+
+	return &Validator{t: t, doc: doc, router: router, exercised: map[string]bool{}}
+}
+
+// Do sends the request to handler and checks the request and the response against the document.
+// The response is returned so the test can check what the handler did.
+func (v *Validator) Do(handler http.Handler, request *http.Request) *http.Response {
+	v.t.Helper()
+	return v.do(handler, request, true)
+}
+
+// DoInvalid sends a request that breaks the document on purpose, such as a body that is not JSON,
+// to test how the handler rejects it. Only the response is checked against the document.
+func (v *Validator) DoInvalid(handler http.Handler, request *http.Request) *http.Response {
+	v.t.Helper()
+	return v.do(handler, request, false)
+}
+
+func (v *Validator) do(handler http.Handler, request *http.Request, checkRequest bool) *http.Response {
+	v.t.Helper()
+
+	var body []byte
+	if request.Body != nil {
+		body, _ = io.ReadAll(request.Body)
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	route, pathParams, err := v.router.FindRoute(request)
+	if err != nil {
+		v.t.Fatalf("%s %s is not in the OpenAPI document: %v", request.Method, request.URL.Path, err)
+	}
+	v.exercised[route.Operation.OperationID] = true
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    request,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}
+	if checkRequest {
+		if err := openapi3filter.ValidateRequest(context.Background(), input); err != nil {
+			v.t.Errorf("%s %s does not match the OpenAPI document: %v", request.Method, request.URL.Path, err)
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	response := recorder.Result()
+	responseBody := recorder.Body.Bytes()
+
+	// A server sniffs the content type of a body written without one, the recorder does not
+	// when the status was written first
+	if response.Header.Get("Content-Type") == "" && len(responseBody) > 0 {
+		response.Header.Set("Content-Type", http.DetectContentType(responseBody))
+	}
+
+	output := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 response.StatusCode,
+		Header:                 response.Header,
+		Body:                   io.NopCloser(bytes.NewReader(responseBody)),
+	}
+	if err := openapi3filter.ValidateResponse(context.Background(), output); err != nil {
+		v.t.Errorf("the %d response to %s %s does not match the OpenAPI document: %v\n%s",
+			response.StatusCode, request.Method, request.URL.Path, err, responseBody)
+	}
+	return response
+}
+
+// Transport returns an http.RoundTripper that sends every request to handler through Do, so a
+// client's requests and the handler's responses are both checked against the document
+func (v *Validator) Transport(handler http.Handler) http.RoundTripper {
+	return roundTripper(func(request *http.Request) (*http.Response, error) {
+		return v.Do(handler, request), nil
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// CheckCoverage fails the test if any documented operation with one of the tags was not exercised
+func (v *Validator) CheckCoverage(tags ...string) {
+	v.t.Helper()
+
+	wanted := map[string]bool{}
+	for _, tag := range tags {
+		wanted[tag] = true
+	}
+
+	var missing []string
+	for path, item := range v.doc.Paths {
+		for method, operation := range item.Operations() {
+			for _, tag := range operation.Tags {
+				if wanted[tag] && !v.exercised[operation.OperationID] {
+					missing = append(missing, method+" "+path)
+				}
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		v.t.Errorf("documented operations not exercised:\n  %s", strings.Join(missing, "\n  "))
+	}
+}
+
+// CheckRoutes fails the test if a route of the router under /api/v1 is not documented. A route
+// matching any method, such as the gateway's proxies, must have documented paths under it.
+func (v *Validator) CheckRoutes(router *mux.Router) {
+	v.t.Helper()
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/api/v1/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			for documented := range v.doc.Paths {
+				if documented == path || strings.HasPrefix(documented, path+"/") {
+					return nil
+				}
+			}
+			v.t.Errorf("route %s is not in the OpenAPI document", path)
+			return nil
+		}
+
+		item := v.doc.Paths.Find(path)
+		for _, method := range methods {
+			if item == nil || item.GetOperation(method) == nil {
+				v.t.Errorf("route %s %s is not in the OpenAPI document", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		v.t.Fatal(err)
+	}
+}
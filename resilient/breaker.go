@@ -0,0 +1,136 @@
+package resilient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the service while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets every call through and counts consecutive failures
+	StateClosed State = iota
+	// StateOpen rejects every call until the open timeout has passed
+	StateOpen
+	// StateHalfOpen lets a single probe call through to decide whether to close or open again
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerStats is a snapshot of a circuit breaker's state and counters
+type BreakerStats struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Successes           int64  `json:"successes"`
+	Failures            int64  `json:"failures"`
+	Rejected            int64  `json:"rejected"`
+	Opened              int64  `json:"opened"`
+}
+
+// Breaker stops calls to a service after FailureThreshold consecutive failures. Once OpenTimeout
+// has passed it lets one probe call through: a success closes the breaker, a failure opens it again.
+// A FailureThreshold of zero never opens the breaker, calls are only counted.
+type Breaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	openTimeout      time.Duration
+	now              func() time.Time
+
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	stats    BreakerStats
+}
+
+// NewBreaker returns a closed circuit breaker
+func NewBreaker(failureThreshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// Allow reports whether a call may go ahead, it returns ErrCircuitOpen if it may not.
+// Every allowed call must be followed by a call to Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			b.stats.Rejected++
+			return ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		b.probing = false
+		fallthrough
+	case StateHalfOpen:
+		if b.probing {
+			b.stats.Rejected++
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record reports the outcome of a call that was allowed
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.stats.Successes++
+		b.failures = 0
+		b.state = StateClosed
+		b.probing = false
+		return
+	}
+
+	b.stats.Failures++
+	b.failures++
+	if b.failureThreshold > 0 && (b.state == StateHalfOpen || b.failures >= b.failureThreshold) {
+		b.state = StateOpen
+		b.openedAt = b.now()
+		b.probing = false
+		b.stats.Opened++
+	}
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Stats returns a snapshot of the breaker's state and counters
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	stats.State = b.state.String()
+	stats.ConsecutiveFailures = b.failures
+	return stats
+}
@@ -0,0 +1,101 @@
+package resilient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+)
+
+// newTestBreaker returns a breaker that opens after three failures for ten seconds, on a fake clock
+func newTestBreaker() (*Breaker, *clock.Fake) {
+	fake := clock.NewFake(time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC))
+	b := NewBreaker(3, 10*time.Second)
+	b.now = fake.Now
+	return b, fake
+}
+
+// call lets a call through the breaker if it allows it and records its outcome
+func call(b *Breaker, success bool) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	b.Record(success)
+	return nil
+}
+
+func TestBreakerStates(t *testing.T) {
+	b, fake := newTestBreaker()
+
+	// Each step makes a call after advancing the clock, the call must be let through unless
+	// rejected is set, and the breaker must then be in state
+	tests := []struct {
+		name     string
+		advance  time.Duration
+		success  bool
+		rejected bool
+		state    State
+	}{
+		{"first failure", 0, false, false, StateClosed},
+		{"success resets the failures", 0, true, false, StateClosed},
+		{"failure after the success", 0, false, false, StateClosed},
+		{"second failure", 0, false, false, StateClosed},
+		{"third failure opens", 0, false, false, StateOpen},
+		{"call while open", 0, true, true, StateOpen},
+		{"call just before the open timeout", 10*time.Second - time.Nanosecond, true, true, StateOpen},
+		{"failed probe opens again", time.Nanosecond, false, false, StateOpen},
+		{"call after the probe failed", 9 * time.Second, true, true, StateOpen},
+		{"successful probe closes", time.Second, true, false, StateClosed},
+		{"failure after closing", 0, false, false, StateClosed},
+	}
+	for _, test := range tests {
+		fake.Advance(test.advance)
+		err := call(b, test.success)
+		if test.rejected != errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("%s: the call returned %v, want rejected %v", test.name, err, test.rejected)
+		}
+		if state := b.State(); state != test.state {
+			t.Errorf("%s: the breaker is %s, want %s", test.name, state, test.state)
+		}
+	}
+
+	want := BreakerStats{State: "closed", ConsecutiveFailures: 1, Successes: 2, Failures: 6, Rejected: 3, Opened: 2}
+	if stats := b.Stats(); stats != want {
+		t.Errorf("Stats returned %+v, want %+v", stats, want)
+	}
+}
+
+func TestBreakerLetsOneProbeThrough(t *testing.T) {
+	b, fake := newTestBreaker()
+	for i := 0; i < 3; i++ {
+		call(b, false)
+	}
+	fake.Advance(10 * time.Second)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("the probe was rejected: %v", err)
+	}
+	if state := b.State(); state != StateHalfOpen {
+		t.Errorf("the breaker is %s while probing, want half-open", state)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("a second call during the probe returned %v, want %v", err, ErrCircuitOpen)
+	}
+	b.Record(true)
+	if err := b.Allow(); err != nil {
+		t.Errorf("a call after the probe succeeded returned %v", err)
+	}
+}
+
+func TestBreakerWithoutThresholdNeverOpens(t *testing.T) {
+	b := NewBreaker(0, 10*time.Second)
+	for i := 0; i < 100; i++ {
+		if err := call(b, false); err != nil {
+			t.Fatalf("call %d returned %v", i+1, err)
+		}
+	}
+	if stats := b.Stats(); stats.State != "closed" || stats.Failures != 100 || stats.Opened != 0 {
+		t.Errorf("Stats returned %+v, want 100 failures on a closed breaker", stats)
+	}
+}
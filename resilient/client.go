@@ -0,0 +1,197 @@
+// Package resilient provides the HTTP client used for calls between the car-pooling programs.
+// Every attempt has a timeout, idempotent requests are retried with exponential backoff and
+// jitter, and a circuit breaker per downstream service fails calls fast while it is down.
+// The state of every breaker is published through expvar under "circuit_breakers", and together
+// with the number of retries as Prometheus metrics.
+package resilient
+
+import (
+	"expvar"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Config controls the timeouts, retries and circuit breaker of a Client
+type Config struct {
+	Timeout          time.Duration     // limit for a single attempt
+	MaxRetries       int               // retries after the first attempt, idempotent requests only
+	BaseBackoff      time.Duration     // wait before the first retry, doubled for every retry after it
+	MaxBackoff       time.Duration     // upper limit for the wait between retries
+	FailureThreshold int               // consecutive failures that open the circuit breaker, zero never opens it
+	OpenTimeout      time.Duration     // how long the breaker stays open before a probe is let through
+	Transport        http.RoundTripper // optional, defaults to http.DefaultTransport
+	Header           http.Header       // optional, added to every request that does not set the header itself
+	RequestID        func() string     // optional, gives requests without an X-Request-ID one, kept for every retry
+}
+
+// DefaultConfig returns the settings used by the console and the services
+func DefaultConfig() Config {
+	return Config{
+		Timeout:          5 * time.Second,
+		MaxRetries:       3,
+		BaseBackoff:      100 * time.Millisecond,
+		MaxBackoff:       2 * time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+	}
+}
+
+// Client is an HTTP client for a single downstream service
+type Client struct {
+	name       string
+	config     Config
+	httpClient *http.Client
+	breaker    *Breaker
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*Breaker{}
+)
+
+func init() {
+	expvar.Publish("circuit_breakers", expvar.Func(func() interface{} {
+		breakersMu.Lock()
+		defer breakersMu.Unlock()
+
+		stats := map[string]BreakerStats{}
+		for name, breaker := range breakers {
+			stats[name] = breaker.Stats()
+		}
+		return stats
+	}))
+}
+
+// NewClient returns a client for the named service, the name identifies its circuit breaker in the metrics
+func NewClient(name string, config Config) *Client {
+	breaker := NewBreaker(config.FailureThreshold, config.OpenTimeout)
+
+	breakersMu.Lock()
+	breakers[name] = breaker
+	breakersMu.Unlock()
+
+	return &Client{
+		name:       name,
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout, Transport: config.Transport},
+		breaker:    breaker,
+	}
+}
+
+// Breaker returns the client's circuit breaker
+func (c *Client) Breaker() *Breaker {
+	return c.breaker
+}
+
+// Get sends a GET request, it is retried if it fails
+func (c *Client) Get(url string) (*http.Response, error) {
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(request)
+}
+
+// Post sends a POST request, it is not retried
+func (c *Client) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	return c.Do(request)
+}
+
+// Do sends a request through the circuit breaker. GET, HEAD and OPTIONS requests are retried
+// on connection errors and on 429, 502, 503 and 504 responses. Other methods are sent once,
+// since routes such as PUT /trips/{id}/enroll are not safe to repeat.
+func (c *Client) Do(request *http.Request) (*http.Response, error) {
+	for key, values := range c.config.Header {
+		if request.Header.Get(key) == "" {
+			request.Header[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+
+	if c.config.RequestID != nil && request.Header.Get("X-Request-ID") == "" {
+		request.Header.Set("X-Request-ID", c.config.RequestID())
+	}
+
+	retries := 0
+	if isIdempotent(request.Method) {
+		retries = c.config.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(request, attempt); err != nil {
+				return nil, err
+			}
+			clientRetries.WithLabelValues(c.name).Inc()
+			if request.GetBody != nil {
+				body, err := request.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				request.Body = body
+			}
+		}
+
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		response, err := c.httpClient.Do(request)
+		c.breaker.Record(err == nil && response.StatusCode < http.StatusInternalServerError)
+
+		if attempt >= retries || !shouldRetry(response, err) {
+			return response, err
+		}
+		if response != nil {
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+	}
+}
+
+// jitter returns a random wait of up to backoff, the tests replace it to see the backoff
+var jitter = func(backoff time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// wait sleeps before a retry using exponential backoff with full jitter
+func (c *Client) wait(request *http.Request, attempt int) error {
+	backoff := c.config.BaseBackoff << uint(attempt-1)
+	if backoff <= 0 || backoff > c.config.MaxBackoff {
+		backoff = c.config.MaxBackoff
+	}
+	delay := jitter(backoff)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-request.Context().Done():
+		return request.Context().Err()
+	}
+}
+
+func isIdempotent(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+func shouldRetry(response *http.Response, err error) bool {
+	if err != nil {
+		return err != ErrCircuitOpen
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package resilient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errConnection stands for a request that could not reach the service
+var errConnection = errors.New("connection refused")
+
+// fakeTransport answers each request with the next of its responses, given as status codes
+// where 0 is a connection error, and remembers the requests it was sent
+type fakeTransport struct {
+	responses []int
+	requests  []*http.Request
+	bodies    []string
+}
+
+func (f *fakeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body := ""
+	if request.Body != nil {
+		data, _ := io.ReadAll(request.Body)
+		body = string(data)
+	}
+	f.requests = append(f.requests, request)
+	f.bodies = append(f.bodies, body)
+
+	status := http.StatusOK
+	if len(f.responses) > 0 {
+		status, f.responses = f.responses[0], f.responses[1:]
+	}
+	if status == 0 {
+		return nil, errConnection
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(http.StatusText(status))),
+		Header:     http.Header{},
+		Request:    request,
+	}, nil
+}
+
+// newTestClient returns a client on the fake transport that retries three times without waiting
+// and never opens its breaker. The backoff of every retry is added to backoffs.
+func newTestClient(t *testing.T, transport *fakeTransport, configure ...func(*Config)) (*Client, *[]time.Duration) {
+	t.Helper()
+
+	var backoffs []time.Duration
+	defaultJitter := jitter
+	jitter = func(backoff time.Duration) time.Duration {
+		backoffs = append(backoffs, backoff)
+		return 0
+	}
+	t.Cleanup(func() { jitter = defaultJitter })
+
+	config := DefaultConfig()
+	config.FailureThreshold = 0
+	config.Transport = transport
+	for _, change := range configure {
+		change(&config)
+	}
+	return NewClient(t.Name(), config), &backoffs
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		responses []int
+		attempts  int
+		status    int // 0 when the call must fail with errConnection
+	}{
+		{"GET succeeds", "GET", []int{200}, 1, 200},
+		{"GET after a 503", "GET", []int{503, 200}, 2, 200},
+		{"GET after a connection error", "GET", []int{0, 200}, 2, 200},
+		{"GET after 429, 502 and 504", "GET", []int{429, 502, 504, 200}, 4, 200},
+		{"GET that keeps failing", "GET", []int{503, 503, 503, 503, 200}, 4, 503},
+		{"GET that keeps failing to connect", "GET", []int{0, 0, 0, 0, 200}, 4, 0},
+		{"GET with a 500", "GET", []int{500, 200}, 1, 500},
+		{"GET with a 404", "GET", []int{404, 200}, 1, 404},
+		{"HEAD after a 503", "HEAD", []int{503, 200}, 2, 200},
+		{"OPTIONS after a 503", "OPTIONS", []int{503, 200}, 2, 200},
+		{"POST with a 503", "POST", []int{503, 200}, 1, 503},
+		{"POST with a connection error", "POST", []int{0, 200}, 1, 0},
+		{"PUT with a 503", "PUT", []int{503, 200}, 1, 503},
+		{"DELETE with a connection error", "DELETE", []int{0, 200}, 1, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := &fakeTransport{responses: test.responses}
+			c, _ := newTestClient(t, transport)
+
+			request, _ := http.NewRequest(test.method, "http://trip-service/api/v1/trips/T1", nil)
+			response, err := c.Do(request)
+			if test.status == 0 {
+				if !errors.Is(err, errConnection) {
+					t.Errorf("Do returned %v, want %v", err, errConnection)
+				}
+			} else if err != nil || response.StatusCode != test.status {
+				t.Errorf("Do returned %v %v, want %d", response, err, test.status)
+			}
+			if len(transport.requests) != test.attempts {
+				t.Errorf("the request was sent %d times, want %d", len(transport.requests), test.attempts)
+			}
+		})
+	}
+}
+
+func TestPostIsNeverRetried(t *testing.T) {
+	transport := &fakeTransport{responses: []int{503, 200}}
+	c, backoffs := newTestClient(t, transport)
+
+	response, err := c.Post("http://trip-service/api/v1/owners/O1/cancel-trips", "application/json", strings.NewReader(`{"reason":"test"}`))
+	if err != nil || response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Post returned %v %v, want the 503", response, err)
+	}
+	if len(transport.requests) != 1 || len(*backoffs) != 0 {
+		t.Errorf("the POST was sent %d times after %d waits, want once", len(transport.requests), len(*backoffs))
+	}
+}
+
+func TestBackoffDoublesUpToTheLimit(t *testing.T) {
+	transport := &fakeTransport{responses: []int{503, 503, 503, 503, 503}}
+	c, backoffs := newTestClient(t, transport, func(config *Config) {
+		config.MaxRetries = 4
+		config.BaseBackoff = 100 * time.Millisecond
+		config.MaxBackoff = 300 * time.Millisecond
+	})
+
+	c.Get("http://trip-service/api/v1/trips")
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	if !reflect.DeepEqual(*backoffs, want) {
+		t.Errorf("the retries backed off up to %v, want %v", *backoffs, want)
+	}
+}
+
+func TestJitterStaysWithinTheBackoff(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if delay := jitter(100 * time.Millisecond); delay < 0 || delay > 100*time.Millisecond {
+			t.Fatalf("jitter returned %v for a backoff of 100ms", delay)
+		}
+	}
+}
+
+func TestRetriesResendTheBodyAndHeaders(t *testing.T) {
+	transport := &fakeTransport{responses: []int{503, 200}}
+	ids := 0
+	c, _ := newTestClient(t, transport, func(config *Config) {
+		config.Header = http.Header{"X-Client-ID": {"user-service"}}
+		config.RequestID = func() string { ids++; return fmt.Sprintf("request-%d", ids) }
+	})
+
+	// Retrying a request with a body is up to its method, so OPTIONS stands in for one here
+	request, _ := http.NewRequest("OPTIONS", "http://trip-service/api/v1/trips", strings.NewReader("body"))
+	if _, err := c.Do(request); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.requests) != 2 {
+		t.Fatalf("the request was sent %d times, want 2", len(transport.requests))
+	}
+	for i, sent := range transport.requests {
+		if sent.Header.Get("X-Client-ID") != "user-service" || sent.Header.Get("X-Request-ID") != "request-1" || transport.bodies[i] != "body" {
+			t.Errorf("attempt %d was sent with %v and body %q", i+1, sent.Header, transport.bodies[i])
+		}
+	}
+}
+
+func TestOpenBreakerStopsRetriesAndCalls(t *testing.T) {
+	transport := &fakeTransport{responses: []int{503, 503, 503, 503}}
+	c, _ := newTestClient(t, transport, func(config *Config) {
+		config.FailureThreshold = 2
+		config.OpenTimeout = time.Hour
+	})
+
+	if _, err := c.Get("http://trip-service/api/v1/trips"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Get returned %v once the breaker opened, want %v", err, ErrCircuitOpen)
+	}
+	if _, err := c.Post("http://trip-service/api/v1/trips/T1", "application/json", strings.NewReader("{}")); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Post returned %v while the breaker is open, want %v", err, ErrCircuitOpen)
+	}
+	if len(transport.requests) != 2 {
+		t.Errorf("the service was called %d times, want 2", len(transport.requests))
+	}
+	if state := c.Breaker().State(); state != StateOpen {
+		t.Errorf("the breaker is %s, want open", state)
+	}
+}
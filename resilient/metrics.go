@@ -0,0 +1,54 @@
+package resilient
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The clients' metrics are registered with the default registry, so the programs' /metrics
+// handler from the metrics package serves them with the rest
+var (
+	clientRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "carpool_client_retries_total",
+		Help: "Retries of calls to the downstream services by service.",
+	}, []string{"service"})
+
+	breakerState = prometheus.NewDesc("carpool_circuit_breaker_state",
+		"Circuit breakers by service and state, 1 for the state each breaker is in and 0 for the others.",
+		[]string{"service", "state"}, nil)
+	breakerOpened = prometheus.NewDesc("carpool_circuit_breaker_opened_total",
+		"Times the circuit breaker of each service opened.", []string{"service"}, nil)
+	breakerRejected = prometheus.NewDesc("carpool_circuit_breaker_rejected_total",
+		"Calls the circuit breaker of each service rejected without calling it.", []string{"service"}, nil)
+)
+
+func init() {
+	prometheus.MustRegister(breakerCollector{})
+}
+
+// breakerCollector reports the state of every circuit breaker when the metrics are scraped
+type breakerCollector struct{}
+
+func (breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerState
+	ch <- breakerOpened
+	ch <- breakerRejected
+}
+
+func (breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	for name, breaker := range breakers {
+		stats := breaker.Stats()
+		for _, state := range []State{StateClosed, StateOpen, StateHalfOpen} {
+			value := 0.0
+			if stats.State == state.String() {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(breakerState, prometheus.GaugeValue, value, name, state.String())
+		}
+		ch <- prometheus.MustNewConstMetric(breakerOpened, prometheus.CounterValue, float64(stats.Opened), name)
+		ch <- prometheus.MustNewConstMetric(breakerRejected, prometheus.CounterValue, float64(stats.Rejected), name)
+	}
+}
@@ -0,0 +1,98 @@
+package resilient
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// breakerMetrics returns the values the breaker of the named client reports, keyed by metric name
+// and the state label for carpool_circuit_breaker_state
+func breakerMetrics(t *testing.T, name string) map[string]float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["service"] != name {
+				continue
+			}
+			key := family.GetName()
+			if state, ok := labels["state"]; ok {
+				key += " " + state
+			}
+			switch {
+			case metric.GetGauge() != nil:
+				values[key] = metric.GetGauge().GetValue()
+			case metric.GetCounter() != nil:
+				values[key] = metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+func TestRetriesAreCounted(t *testing.T) {
+	transport := &fakeTransport{responses: []int{503, 0, 200}}
+	c, _ := newTestClient(t, transport)
+
+	if _, err := c.Get("http://trip-service/api/v1/trips"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Post("http://trip-service/api/v1/trips/T1", "application/json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if retries := testutil.ToFloat64(clientRetries.WithLabelValues(t.Name())); retries != 2 {
+		t.Errorf("counted %v retries, want 2", retries)
+	}
+}
+
+func TestBreakerStateIsExported(t *testing.T) {
+	transport := &fakeTransport{responses: []int{503, 503}}
+	c, _ := newTestClient(t, transport, func(config *Config) {
+		config.MaxRetries = 0
+		config.FailureThreshold = 2
+		config.OpenTimeout = time.Hour
+	})
+
+	want := map[string]float64{
+		"carpool_circuit_breaker_state closed":    1,
+		"carpool_circuit_breaker_state open":      0,
+		"carpool_circuit_breaker_state half-open": 0,
+		"carpool_circuit_breaker_opened_total":    0,
+		"carpool_circuit_breaker_rejected_total":  0,
+		"carpool_client_retries_total":            0,
+	}
+	check := func(when string) {
+		t.Helper()
+		got := breakerMetrics(t, t.Name())
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("%s %s is %v, want %v", when, key, got[key], value)
+			}
+		}
+	}
+	check("before any calls")
+
+	// Two failures open the breaker, which then rejects the next call
+	for i := 0; i < 3; i++ {
+		request, _ := http.NewRequest("GET", "http://trip-service/api/v1/trips", nil)
+		c.Do(request)
+	}
+	want["carpool_circuit_breaker_state closed"] = 0
+	want["carpool_circuit_breaker_state open"] = 1
+	want["carpool_circuit_breaker_opened_total"] = 1
+	want["carpool_circuit_breaker_rejected_total"] = 1
+	check("once the breaker opened")
+}
@@ -0,0 +1,94 @@
+// Package server runs the HTTP servers of the car-pooling programs with timeouts and a graceful
+// shutdown: on SIGINT or SIGTERM the server stops accepting connections and waits for the requests
+// in flight to finish, so an enrollment is never cut off half way, before the program flushes its store.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Config holds the listen address and timeouts of a server
+type Config struct {
+	Addr              string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration // how long in-flight requests get to finish on shutdown
+	TLS               *tls.Config   // serves HTTPS when set
+}
+
+// DefaultConfig returns the timeouts used unless they are configured
+func DefaultConfig(addr string) Config {
+	return Config{
+		Addr:              addr,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		ShutdownTimeout:   15 * time.Second,
+	}
+}
+
+// Run serves handler until the process receives SIGINT or SIGTERM, then shuts the server down
+// gracefully. It returns an error if the address cannot be bound, the server fails, or the
+// requests in flight do not finish within the shutdown timeout. A second signal during the
+// shutdown stops the process straight away.
+func Run(logger *slog.Logger, config Config, handler http.Handler) error {
+	listener, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return serve(ctx, stop, logger, config, listener, handler)
+}
+
+// serve serves handler on listener until ctx is done, then calls stop and shuts the server down gracefully
+func serve(ctx context.Context, stop func(), logger *slog.Logger, config Config, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		TLSConfig:         config.TLS,
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		if config.TLS != nil {
+			served <- server.ServeTLS(listener, "", "")
+		} else {
+			served <- server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	logger.Info("shutting down, waiting for requests in flight", "timeout", config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("requests still in flight after %s: %v", config.ShutdownTimeout, err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// slowHandler answers once release is closed, and closes started when a request arrives
+func slowHandler(started, release chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("enrolled"))
+	})
+}
+
+// startServe serves handler on a free port until the returned cancel is called, serve's result
+// is sent on the returned channel
+func startServe(t *testing.T, config Config, handler http.Handler) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, cancel, discard, config, listener, handler) }()
+	return "http://" + listener.Addr().String(), cancel, served
+}
+
+func TestShutdownWaitsForRequestsInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	url, cancel, served := startServe(t, DefaultConfig(""), slowHandler(started, release))
+
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-started
+	cancel()
+
+	// The server stops accepting connections but keeps running until the request finishes
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-served:
+		t.Fatalf("the server stopped with a request in flight: %v", err)
+	default:
+	}
+	if _, err := net.DialTimeout("tcp", strings.TrimPrefix(url, "http://"), time.Second); err == nil {
+		t.Error("the server accepted a connection while shutting down")
+	}
+
+	close(release)
+	if got := <-response; got != "enrolled" {
+		t.Errorf("the request in flight got %q, want it to finish", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("the shutdown returned %v", err)
+	}
+}
+
+func TestShutdownGivesUpAfterTheTimeout(t *testing.T) {
+	config := DefaultConfig("")
+	config.ShutdownTimeout = 50 * time.Millisecond
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	url, cancel, served := startServe(t, config, slowHandler(started, release))
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	if err := <-served; err == nil || !strings.Contains(err.Error(), "requests still in flight after 50ms") {
+		t.Errorf("the shutdown returned %v, want the requests still in flight", err)
+	}
+}
+
+func TestRunStopsOnSIGTERM(t *testing.T) {
+	// Find a free port for Run to listen on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ran := make(chan error, 1)
+	go func() {
+		ran <- Run(discard, DefaultConfig(addr), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	}()
+
+	// Once the server answers, Run is listening for the signal
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if resp, err := http.Get("http://" + addr); err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the server did not start")
+		}
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-ran:
+		if err != nil {
+			t.Errorf("Run returned %v after SIGTERM", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop after SIGTERM")
+	}
+}
+
+func TestRunFailsIfTheAddressIsTaken(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err := Run(discard, DefaultConfig(listener.Addr().String()), http.NotFoundHandler()); err == nil {
+		t.Error("Run served on an address that is already in use")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/gorilla/mux"
+)
+
+// auditTarget returns the entity changed by a mutating request to the Trip service
+func auditTarget(r *http.Request) (audit.Target, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return audit.Target{}, false
+	}
+	template, _ := route.GetPathTemplate()
+	id := mux.Vars(r)["id"]
+
+	switch {
+	case template == "/api/v1/admin/trips/import":
+		// An import can change any trip, so all of them are recorded
+		return audit.Target{Entity: "import", EntityID: "trips", Snapshot: func() interface{} {
+			return store.All()
+		}}, true
+	case strings.HasPrefix(template, "/api/v1/trips/{id}"):
+		return audit.Target{Entity: "trip", EntityID: id, Snapshot: func() interface{} {
+			if trip, ok := store.Get(id); ok {
+				return trip
+			}
+			return nil
+		}}, true
+	case strings.HasPrefix(template, "/api/v1/series/{id}"):
+		return audit.Target{Entity: "series", EntityID: id, Snapshot: func() interface{} {
+			if tripSeries, ok := store.GetSeries(id); ok {
+				return tripSeries
+			}
+			return nil
+		}}, true
+	case strings.HasPrefix(template, "/api/v1/owners/{id}"):
+		// Cancelling an owner's trips changes all of them, so the owner's trips are recorded together
+		return audit.Target{Entity: "owner", EntityID: id, Snapshot: func() interface{} {
+			trips := map[string]model.Trip{}
+			for tripID, trip := range store.All() {
+				if trip.CarOwnerID == id {
+					trips[tripID] = trip
+				}
+			}
+			return trips
+		}}, true
+	}
+	return audit.Target{}, false
+}
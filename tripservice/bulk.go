@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/bulk"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// bulkTrips exports and imports every trip for the admin endpoints, the store and the User
+// service client must be set up first
+func bulkTrips() bulk.Records[model.Trip] {
+	return bulk.Records[model.Trip]{
+		Name:    "trips",
+		Entity:  "trip",
+		Columns: model.TripColumns,
+		All: func() []model.Trip {
+			all := store.All()
+			trips := make([]model.Trip, 0, len(all))
+			for _, trip := range all {
+				trips = append(trips, trip)
+			}
+			sort.Slice(trips, func(i, j int) bool { return trips[i].ID < trips[j].ID })
+			return trips
+		},
+		Get:     store.Get,
+		ID:      func(trip model.Trip) string { return trip.ID },
+		CSV:     model.TripsCSV,
+		FromCSV: model.CSVRow.Trip,
+		Check:   checkImportedTrip,
+		PutAll:  store.PutAll,
+	}
+}
+
+// checkImportedTrip checks a trip before it is imported: its car owner must be able to publish
+// trips and its passengers must exist, so the users are imported first. The seats left are counted
+// again from the passengers.
+func checkImportedTrip(trip, _ *model.Trip) error {
+	if err := trip.CheckImport(); err != nil {
+		return err
+	}
+
+	carOwner, err := userClient.GetUser(trip.CarOwnerID)
+	if errors.Is(err, ErrUserNotFound) {
+		return fmt.Errorf("car owner %s does not exist", trip.CarOwnerID)
+	}
+	if err != nil {
+		return bulk.Unavailable(fmt.Errorf("could not check the car owner: %w", err))
+	}
+	if err := carOwner.CheckCarOwner(); err != nil {
+		return err
+	}
+
+	for _, passengerID := range trip.EnrolledPassengers {
+		_, err := userClient.GetUser(passengerID)
+		if errors.Is(err, ErrUserNotFound) {
+			return fmt.Errorf("passenger %s does not exist", passengerID)
+		}
+		if err != nil {
+			return bulk.Unavailable(fmt.Errorf("could not check the passengers: %w", err))
+		}
+	}
+
+	trip.StartTime = trip.StartTime.UTC()
+	trip.UpdateSeats()
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+	"github.com/Zachisastudent/ETI_Assignment-1/eventlog"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi/openapitest"
+	"github.com/gorilla/mux"
+)
+
+// testNow is the time the fake clock of the tests starts at, a Monday
+var testNow = time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+
+// userServiceStub is the stub User service of the last newTestRouter
+var userServiceStub http.Handler
+
+// newTestRouter sets up the service with an empty store kept in memory, a clock stopped at testNow
+// and a stub User service that knows car owner O1, passenger P1 and car owner O2 whose profile has
+// no car plate. The notifications left with it are sent to notified.
+func newTestRouter(t *testing.T, notified chan<- model.Notification) (*mux.Router, *clock.Fake) {
+	t.Helper()
+
+	fake := clock.NewFake(testNow)
+	clk = fake
+
+	users := map[string]model.User{
+		"O1": {ID: "O1", FirstName: "John", IsCarOwner: true, DriverLicense: "S1234567A", CarPlateNumber: "SBA1234A"},
+		"O2": {ID: "O2", FirstName: "Mary", IsCarOwner: true, DriverLicense: "T7654321B"},
+		"P1": {ID: "P1", FirstName: "Jane"},
+	}
+	userService := mux.NewRouter()
+	userService.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	userService.HandleFunc("/api/v1/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := users[mux.Vars(r)["id"]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "Invalid user ID")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	})
+	userService.HandleFunc("/api/v1/passengers/{id}/notifications", func(w http.ResponseWriter, r *http.Request) {
+		var notification model.Notification
+		json.NewDecoder(r.Body).Decode(&notification)
+		if notified != nil {
+			notified <- notification
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "Notification added for user %s", mux.Vars(r)["id"])
+	})
+	userServiceStub = userService
+	stub := httptest.NewServer(userService)
+	t.Cleanup(stub.Close)
+	userClient = newUserClient(stub.URL, http.DefaultTransport)
+
+	// Trips can be published right away and started an hour either side of their time
+	policy = newPolicy(config.PolicyConfig{StartWindowBefore: time.Hour, StartWindowAfter: time.Hour})
+	displayZone = time.UTC
+
+	events, err := eventlog.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.Clock = fake
+	if store, err = newTripStore(events); err != nil {
+		t.Fatal(err)
+	}
+	auditLog, err := audit.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auditLog.Close(); events.Close() })
+
+	return newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), events, auditLog), fake
+}
+
+func request(method, path, body string) *http.Request {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+func tripJSON(ownerID string, start time.Time) string {
+	return fmt.Sprintf(`{"car_owner_id":%q,"pickup_location":"Ang Mo Kio","start_time":%q,"destination":"Ngee Ann Polytechnic","total_seats":3}`,
+		ownerID, start.UTC().Format(time.RFC3339))
+}
+
+func TestContract(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+	v := openapitest.New(t)
+	v.CheckRoutes(r)
+
+	// The calls to the User service are checked against the document too
+	userClient = newUserClient("http://user-service", v.Transport(userServiceStub))
+
+	soon := testNow.Add(10 * time.Minute)
+	tomorrow := testNow.AddDate(0, 0, 1).Format("2006-01-02")
+	const series = `{"car_owner_id":"O1","pickup_location":"Ang Mo Kio","destination":"Ngee Ann Polytechnic","total_seats":3,"time_of_day":"08:15","time_zone":"UTC","days":["DAILY"]}`
+
+	const export = "id,car_owner_id,pickup_location,start_time,destination,total_seats\nT1,O1,Ang Mo Kio,2024-01-15T08:10:00Z,Ngee Ann Polytechnic,3\n"
+	importTrips := func(query, body string) *http.Request {
+		r := request("POST", "/api/v1/admin/trips/import"+query, body)
+		r.Header.Set("Content-Type", "text/csv")
+		return r
+	}
+
+	start := request("PUT", "/api/v1/trips/T1/start", "")
+	start.Header.Set("car-owner-id", "O1")
+	startByOther := request("PUT", "/api/v1/trips/T1/start", "")
+	startByOther.Header.Set("car-owner-id", "P1")
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{"create a trip", request("POST", "/api/v1/trips/T1", tripJSON("O1", soon)), http.StatusAccepted},
+		{"create a trip for a passenger", request("POST", "/api/v1/trips/T2", tripJSON("P1", soon)), http.StatusBadRequest},
+		{"create a trip for a missing owner", request("POST", "/api/v1/trips/T2", tripJSON("X1", soon)), http.StatusNotFound},
+		{"create a trip in the past", request("POST", "/api/v1/trips/T2", tripJSON("O1", soon.Add(-time.Hour))), http.StatusBadRequest},
+		{"update a trip", request("PUT", "/api/v1/trips/T1", tripJSON("O1", soon)), http.StatusAccepted},
+		{"get a trip", request("GET", "/api/v1/trips/T1", ""), http.StatusOK},
+		{"get a missing trip", request("GET", "/api/v1/trips/T9", ""), http.StatusNotFound},
+		{"list trips", request("GET", "/api/v1/trips", ""), http.StatusOK},
+		{"start a trip without passengers", start, http.StatusBadRequest},
+		{"enroll a passenger", request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`), http.StatusAccepted},
+		{"enroll a passenger twice", request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`), http.StatusBadRequest},
+		{"enroll in a missing trip", request("PUT", "/api/v1/trips/T9/enroll", `{"user_id":"P1"}`), http.StatusNotFound},
+		{"start someone else's trip", startByOther, http.StatusUnauthorized},
+		{"start a trip", start, http.StatusAccepted},
+		{"delete a started trip", request("DELETE", "/api/v1/trips/T1", ""), http.StatusBadRequest},
+		{"create another trip", request("POST", "/api/v1/trips/T2", tripJSON("O1", soon.Add(24*time.Hour))), http.StatusAccepted},
+		{"enroll in another trip", request("PUT", "/api/v1/trips/T2/enroll", `{"user_id":"P1"}`), http.StatusAccepted},
+		{"withdraw from another trip", request("PUT", "/api/v1/trips/T2/withdraw", `{"user_id":"P1"}`), http.StatusAccepted},
+		{"withdraw without enrolling", request("PUT", "/api/v1/trips/T2/withdraw", `{"user_id":"P1"}`), http.StatusBadRequest},
+		{"withdraw from a missing trip", request("PUT", "/api/v1/trips/T9/withdraw", `{"user_id":"P1"}`), http.StatusNotFound},
+		{"enroll in another trip again", request("PUT", "/api/v1/trips/T2/enroll", `{"user_id":"P1"}`), http.StatusAccepted},
+		{"delete a trip", request("DELETE", "/api/v1/trips/T2", ""), http.StatusOK},
+		{"delete a missing trip", request("DELETE", "/api/v1/trips/T2", ""), http.StatusNotFound},
+		{"create a series", request("POST", "/api/v1/series/S1", series), http.StatusAccepted},
+		{"create a series twice", request("POST", "/api/v1/series/S1", series), http.StatusConflict},
+		{"create a series without days", request("POST", "/api/v1/series/S2", strings.Replace(series, `"DAILY"`, `"XX"`, 1)), http.StatusBadRequest},
+		{"update a series", request("PUT", "/api/v1/series/S1", strings.Replace(series, "08:15", "09:30", 1)), http.StatusAccepted},
+		{"update a missing series", request("PUT", "/api/v1/series/S9", series), http.StatusNotFound},
+		{"get a series", request("GET", "/api/v1/series/S1", ""), http.StatusOK},
+		{"get a missing series", request("GET", "/api/v1/series/S9", ""), http.StatusNotFound},
+		{"list series", request("GET", "/api/v1/series", ""), http.StatusOK},
+		{"skip a date", request("POST", "/api/v1/series/S1/skip", fmt.Sprintf(`{"date":%q}`, tomorrow)), http.StatusAccepted},
+		{"skip a date of a missing series", request("POST", "/api/v1/series/S9/skip", fmt.Sprintf(`{"date":%q}`, tomorrow)), http.StatusNotFound},
+		{"list scheduled trips", request("GET", "/api/v1/owners/O1/scheduled-trips", ""), http.StatusOK},
+		{"export the trips", request("GET", "/api/v1/admin/trips/export?format=csv", ""), http.StatusOK},
+		{"import the trips as a dry run", importTrips("?dry_run=true&on_conflict=overwrite", export), http.StatusOK},
+		{"import the trips", importTrips("?on_conflict=skip", export), http.StatusAccepted},
+		{"import a taken ID", importTrips("", export), http.StatusConflict},
+		{"delete a series", request("DELETE", "/api/v1/series/S1", ""), http.StatusOK},
+		{"delete a missing series", request("DELETE", "/api/v1/series/S1", ""), http.StatusNotFound},
+		{"cancel an owner's trips", request("POST", "/api/v1/owners/O1/cancel-trips", `{"reason":"the account was deleted"}`), http.StatusOK},
+		{"get the policy", request("GET", "/api/v1/policy", ""), http.StatusOK},
+		{"query the audit log", request("GET", "/api/v1/audit?entity=trip&id=T1", ""), http.StatusOK},
+		{"export the audit log", request("GET", "/api/v1/audit?format=jsonl", ""), http.StatusOK},
+		{"liveness", request("GET", "/healthz", ""), http.StatusOK},
+		{"readiness", request("GET", "/readyz", ""), http.StatusOK},
+		{"metrics", request("GET", "/metrics", ""), http.StatusOK},
+	}
+	for _, test := range tests {
+		method, path := test.request.Method, test.request.URL.Path
+		response := v.Do(r, test.request)
+		if response.StatusCode != test.status {
+			body, _ := io.ReadAll(response.Body)
+			t.Errorf("%s: %s %s returned %d, want %d: %s", test.name, method, path, response.StatusCode, test.status, body)
+		}
+	}
+
+	v.CheckCoverage("trips", "series", "policy", "internal", "audit", "health")
+}
+
+func TestCancellingAnOwnersTripsNotifiesPassengers(t *testing.T) {
+	notified := make(chan model.Notification, 1)
+	r, _ := newTestRouter(t, notified)
+	v := openapitest.New(t)
+
+	v.Do(r, request("POST", "/api/v1/trips/T1", tripJSON("O1", testNow.Add(time.Hour))))
+	v.Do(r, request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`))
+	response := v.Do(r, request("POST", "/api/v1/owners/O1/cancel-trips", `{"reason":"the account was deleted"}`))
+
+	var cancelled []string
+	json.NewDecoder(response.Body).Decode(&cancelled)
+	if len(cancelled) != 1 || cancelled[0] != "T1" {
+		t.Errorf("cancelled %v, want [T1]", cancelled)
+	}
+	if trip, _ := store.Get("T1"); !trip.Cancelled {
+		t.Error("the trip was not cancelled")
+	}
+
+	select {
+	case notification := <-notified:
+		if notification.TripID != "T1" || !strings.Contains(notification.Message, "the account was deleted") {
+			t.Errorf("unexpected notification %+v", notification)
+		}
+	default:
+		t.Error("the passenger was not notified")
+	}
+}
+
+func TestDeletingAnOccurrenceNotifiesPassengers(t *testing.T) {
+	notified := make(chan model.Notification, 1)
+	r, _ := newTestRouter(t, notified)
+	v := openapitest.New(t)
+
+	v.Do(r, request("POST", "/api/v1/series/S1", `{"car_owner_id":"O1","pickup_location":"Ang Mo Kio","destination":"Ngee Ann Polytechnic",`+
+		`"total_seats":3,"time_of_day":"09:00","time_zone":"UTC","days":["DAILY"]}`))
+	v.Do(r, request("PUT", "/api/v1/trips/S1-20240116/enroll", `{"user_id":"P1"}`))
+	v.Do(r, request("DELETE", "/api/v1/trips/S1-20240116", ""))
+
+	if tripSeries, _ := store.GetSeries("S1"); len(tripSeries.SkippedDates) != 1 || tripSeries.SkippedDates[0] != "2024-01-16" {
+		t.Errorf("the series skips %v, want [2024-01-16]", tripSeries.SkippedDates)
+	}
+	select {
+	case notification := <-notified:
+		if notification.TripID != "S1-20240116" || !strings.Contains(notification.Message, "cancelled by the car owner") {
+			t.Errorf("unexpected notification %+v", notification)
+		}
+	default:
+		t.Error("the passenger was not notified")
+	}
+}
+
+func TestChangingASeriesNotifiesPassengers(t *testing.T) {
+	notified := make(chan model.Notification, 1)
+	r, _ := newTestRouter(t, notified)
+	v := openapitest.New(t)
+
+	series := `{"car_owner_id":"O1","pickup_location":"Ang Mo Kio","destination":"Ngee Ann Polytechnic",` +
+		`"total_seats":3,"time_of_day":"09:00","time_zone":"UTC","days":["DAILY"]}`
+	v.Do(r, request("POST", "/api/v1/series/S1", series))
+	v.Do(r, request("PUT", "/api/v1/trips/S1-20240116/enroll", `{"user_id":"P1"}`))
+
+	// More seats do not concern the passenger, a later time does
+	v.Do(r, request("PUT", "/api/v1/series/S1", strings.Replace(series, `"total_seats":3`, `"total_seats":4`, 1)))
+	select {
+	case notification := <-notified:
+		t.Errorf("the passenger was notified of more seats: %+v", notification)
+	default:
+	}
+
+	v.Do(r, request("PUT", "/api/v1/series/S1", strings.Replace(series, "09:00", "09:30", 1)))
+	select {
+	case notification := <-notified:
+		if notification.TripID != "S1-20240116" || !strings.Contains(notification.Message, "at 2024-01-16 09:30 UTC") {
+			t.Errorf("unexpected notification %+v", notification)
+		}
+	default:
+		t.Error("the passenger was not notified")
+	}
+	if trip, _ := store.Get("S1-20240116"); len(trip.EnrolledPassengers) != 1 || !trip.StartTime.Equal(time.Date(2024, 1, 16, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("the occurrence has passengers %v and leaves at %v", trip.EnrolledPassengers, trip.StartTime)
+	}
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+	"github.com/Zachisastudent/ETI_Assignment-1/eventlog"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi/openapitest"
+	"github.com/Zachisastudent/ETI_Assignment-1/resilient"
+	"github.com/gorilla/mux"
+)
+
+// tripStart is when trip T1 of the handler tests leaves, two hours after testNow
+var tripStart = testNow.Add(2 * time.Hour)
+
+const seriesJSON = `{"car_owner_id":"O1","pickup_location":"Ang Mo Kio","destination":"Ngee Ann Polytechnic","total_seats":3,"time_of_day":"09:00","time_zone":"UTC","days":["DAILY"]}`
+
+// userServiceDown points the User client at a service that is not running. The client neither
+// retries nor opens its circuit breaker, so the other tests are not held up.
+func userServiceDown() {
+	config := resilient.DefaultConfig()
+	config.MaxRetries = 0
+	config.FailureThreshold = 0
+	userClient = &UserClient{BaseURL: "http://127.0.0.1:1", HTTPClient: resilient.NewClient("user-service-down", config)}
+}
+
+// newHandlerTest sets up the service with the default policy, where every window is 30 minutes, trip T1
+// of car owner O1 with a single seat leaving at tripStart, and series S1 of O1 leaving daily at 09:00 UTC
+func newHandlerTest(t *testing.T) (*mux.Router, *clock.Fake, *openapitest.Validator) {
+	r, fake := newTestRouter(t, nil)
+	policy = newPolicy(config.Default().Policy)
+	v := openapitest.New(t)
+	v.Do(r, request("POST", "/api/v1/trips/T1", strings.Replace(tripJSON("O1", tripStart), `"total_seats":3`, `"total_seats":1`, 1)))
+	v.Do(r, request("POST", "/api/v1/series/S1", seriesJSON))
+	return r, fake, v
+}
+
+// enroll adds a passenger to a trip without checking the rules
+func enroll(tripID, userID string) {
+	store.Enroll(tripID, userID, func(model.Trip) error { return nil })
+}
+
+// checkResponse fails the test unless the response has the status and its body starts with want
+func checkResponse(t *testing.T, request string, response *http.Response, status int, want string) {
+	t.Helper()
+	body, _ := io.ReadAll(response.Body)
+	if response.StatusCode != status || !strings.HasPrefix(string(body), want) {
+		t.Errorf("%s returned %d %q, want %d %q", request, response.StatusCode, body, status, want)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	enrolled := func(*clock.Fake) { enroll("T1", "P1") }
+	at := func(now time.Time) func(*clock.Fake) {
+		return func(fake *clock.Fake) { fake.Set(now) }
+	}
+	series := func(from, to string) string { return strings.Replace(seriesJSON, from, to, 1) }
+
+	tests := []struct {
+		name   string
+		setup  func(fake *clock.Fake)
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{"get a trip", nil, "GET", "/api/v1/trips/T1", "", http.StatusOK, `{"id":"T1"`},
+		{"get a missing trip", nil, "GET", "/api/v1/trips/T9", "", http.StatusNotFound, "Invalid trip ID"},
+		{"list trips", nil, "GET", "/api/v1/trips", "", http.StatusOK, `{"S1-20240115":`},
+
+		{"create a trip 30 minutes ahead", nil, "POST", "/api/v1/trips/T2", tripJSON("O1", testNow.Add(30*time.Minute)), http.StatusAccepted, "Trip POST T2 successfully"},
+		{"create a trip 29 minutes ahead", nil, "POST", "/api/v1/trips/T2", tripJSON("O1", testNow.Add(29*time.Minute)),
+			http.StatusBadRequest, "Error - Trips must be scheduled at least 30m in the future"},
+		{"create a trip for a missing owner", nil, "POST", "/api/v1/trips/T2", tripJSON("X1", tripStart), http.StatusNotFound, "Error - Car owner does not exist"},
+		{"create a trip for a passenger", nil, "POST", "/api/v1/trips/T2", tripJSON("P1", tripStart), http.StatusBadRequest, "Error - Only car owners can create trips"},
+		{"create a trip for an incomplete owner", nil, "POST", "/api/v1/trips/T2", tripJSON("O2", tripStart), http.StatusBadRequest, "Error - Car owner profile incomplete"},
+		{"create a trip while the User service is down", func(*clock.Fake) { userServiceDown() },
+			"POST", "/api/v1/trips/T2", tripJSON("O1", tripStart), http.StatusBadGateway, "Error - Could not check the car owner"},
+		{"update a trip", nil, "PUT", "/api/v1/trips/T1", tripJSON("O1", tripStart), http.StatusAccepted, "Trip PUT T1 successfully"},
+		{"update a trip to fewer seats than passengers", func(*clock.Fake) { enroll("T1", "P1"); enroll("T1", "P2") },
+			"PUT", "/api/v1/trips/T1", strings.Replace(tripJSON("O1", tripStart), `"total_seats":3`, `"total_seats":1`, 1),
+			http.StatusBadRequest, "Error - Total seats cannot be fewer than the 2 passengers enrolled"},
+		{"update a started trip", func(*clock.Fake) { enroll("T1", "P1"); store.Start("T1", nil) },
+			"PUT", "/api/v1/trips/T1", tripJSON("O1", tripStart), http.StatusBadRequest, "Error - Trip is already started and cannot be changed"},
+		{"update a cancelled trip", func(*clock.Fake) { store.Cancel("T1", "the car owner is ill") },
+			"PUT", "/api/v1/trips/T1", tripJSON("O1", tripStart), http.StatusBadRequest, "Error - Trip has been cancelled and cannot be changed"},
+
+		{"cancel a trip 30 minutes ahead", at(tripStart.Add(-30 * time.Minute)), "DELETE", "/api/v1/trips/T1", "", http.StatusOK, "Trip T1 deleted"},
+		{"cancel a trip 29 minutes ahead", at(tripStart.Add(-29 * time.Minute)),
+			"DELETE", "/api/v1/trips/T1", "", http.StatusBadRequest, "Error - Trips cannot be canceled less than 30m before the scheduled time"},
+		{"cancel a started trip", func(*clock.Fake) { enroll("T1", "P1"); store.Start("T1", nil) },
+			"DELETE", "/api/v1/trips/T1", "", http.StatusBadRequest, "Error - Trip is already started and cannot be canceled"},
+		{"cancel a missing trip", nil, "DELETE", "/api/v1/trips/T9", "", http.StatusNotFound, "Invalid trip ID"},
+
+		{"enroll 30 minutes ahead", at(tripStart.Add(-30 * time.Minute)),
+			"PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`, http.StatusAccepted, "User P1 enrolled in trip T1 successfully"},
+		{"enroll 29 minutes ahead", at(tripStart.Add(-29 * time.Minute)),
+			"PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`, http.StatusBadRequest, "Error - Enrollment closes 30m before the scheduled time"},
+		{"enroll twice", enrolled, "PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`, http.StatusBadRequest, "Error - User already enrolled in this trip"},
+		{"enroll in a full trip", enrolled, "PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P2"}`, http.StatusBadRequest, "Error - No seats left on this trip"},
+		{"enroll in a cancelled trip", func(*clock.Fake) { store.Cancel("T1", "the car owner is ill") },
+			"PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`, http.StatusBadRequest, "Error - Trip has been cancelled"},
+		{"enroll in a missing trip", nil, "PUT", "/api/v1/trips/T9/enroll", `{"user_id":"P1"}`, http.StatusNotFound, "Invalid trip ID"},
+
+		{"withdraw 30 minutes ahead", func(fake *clock.Fake) { enroll("T1", "P1"); fake.Set(tripStart.Add(-30 * time.Minute)) },
+			"PUT", "/api/v1/trips/T1/withdraw", `{"user_id":"P1"}`, http.StatusAccepted, "User P1 withdrew from trip T1 successfully"},
+		{"withdraw 29 minutes ahead", func(fake *clock.Fake) { enroll("T1", "P1"); fake.Set(tripStart.Add(-29 * time.Minute)) },
+			"PUT", "/api/v1/trips/T1/withdraw", `{"user_id":"P1"}`, http.StatusBadRequest, "Error - Withdrawals close 30m before the scheduled time"},
+		{"withdraw without enrolling", nil, "PUT", "/api/v1/trips/T1/withdraw", `{"user_id":"P1"}`, http.StatusBadRequest, "Error - User is not enrolled in this trip"},
+		{"withdraw from a started trip", func(*clock.Fake) { enroll("T1", "P1"); store.Start("T1", nil) },
+			"PUT", "/api/v1/trips/T1/withdraw", `{"user_id":"P1"}`, http.StatusBadRequest, "Error - Trip is already started"},
+		{"withdraw from a missing trip", nil, "PUT", "/api/v1/trips/T9/withdraw", `{"user_id":"P1"}`, http.StatusNotFound, "Invalid trip ID"},
+
+		{"create a series", nil, "POST", "/api/v1/series/S2", seriesJSON, http.StatusAccepted, "Series POST S2 successfully"},
+		{"create a series twice", nil, "POST", "/api/v1/series/S1", seriesJSON, http.StatusConflict, "Error - Series already exists"},
+		{"create a series for a passenger", nil, "POST", "/api/v1/series/S2", series(`"O1"`, `"P1"`), http.StatusBadRequest, "Error - Only car owners can create trips"},
+		{"create a series at an invalid time", nil, "POST", "/api/v1/series/S2", series("09:00", "25:00"),
+			http.StatusBadRequest, "Error - time of day must be in the format 15:04"},
+		{"create a series on an unknown day", nil, "POST", "/api/v1/series/S2", series("DAILY", "XX"), http.StatusBadRequest, `Error - unknown recurrence day "XX"`},
+		{"create a series in an unknown zone", nil, "POST", "/api/v1/series/S2", series(`"UTC"`, `"Mars/Olympus"`),
+			http.StatusBadRequest, `Error - unknown time zone "Mars/Olympus"`},
+		{"create a series that has ended", nil, "POST", "/api/v1/series/S2", series(`"days"`, `"until":"2024-01-13","days"`),
+			http.StatusBadRequest, "Error - until date is in the past"},
+		{"update a series", nil, "PUT", "/api/v1/series/S1", series("09:00", "09:30"), http.StatusAccepted, "Series PUT S1 successfully"},
+		{"update a missing series", nil, "PUT", "/api/v1/series/S9", seriesJSON, http.StatusNotFound, "Invalid series ID"},
+		{"update the car owner of a series", nil, "PUT", "/api/v1/series/S1", series(`"O1"`, `"O2"`),
+			http.StatusBadRequest, "Error - The car owner of a series cannot be changed"},
+		{"update a series to fewer seats than an occurrence's passengers", func(*clock.Fake) { enroll("S1-20240116", "P1"); enroll("S1-20240116", "O2") },
+			"PUT", "/api/v1/series/S1", series(`"total_seats":3`, `"total_seats":1`),
+			http.StatusBadRequest, "Error - total seats cannot be fewer than the 2 passengers enrolled in trip S1-20240116"},
+		{"get a series", nil, "GET", "/api/v1/series/S1", "", http.StatusOK, `{"id":"S1"`},
+		{"get a missing series", nil, "GET", "/api/v1/series/S9", "", http.StatusNotFound, "Invalid series ID"},
+		{"list series", nil, "GET", "/api/v1/series", "", http.StatusOK, `{"S1":`},
+		{"delete a series", nil, "DELETE", "/api/v1/series/S1", "", http.StatusOK, "Series S1 deleted; cancelled trips: S1-20240115, S1-20240116"},
+		{"delete a missing series", nil, "DELETE", "/api/v1/series/S9", "", http.StatusNotFound, "Invalid series ID"},
+		{"skip a date", nil, "POST", "/api/v1/series/S1/skip", `{"date":"2024-01-16"}`, http.StatusAccepted, "Occurrence 2024-01-16 of series S1 skipped"},
+		{"skip today 29 minutes ahead", at(time.Date(2024, 1, 15, 8, 31, 0, 0, time.UTC)),
+			"POST", "/api/v1/series/S1/skip", `{"date":"2024-01-15"}`, http.StatusBadRequest, "Error - Trips cannot be canceled less than 30m before the scheduled time"},
+		{"skip a date of a missing series", nil, "POST", "/api/v1/series/S9/skip", `{"date":"2024-01-16"}`, http.StatusNotFound, "Invalid series ID"},
+
+		{"list scheduled trips", nil, "GET", "/api/v1/owners/O1/scheduled-trips", "", http.StatusOK, `["S1-20240115",`},
+		{"list the scheduled trips of a passenger", nil, "GET", "/api/v1/owners/P1/scheduled-trips", "", http.StatusOK, "[]"},
+		{"cancel an owner's trips", nil, "POST", "/api/v1/owners/O1/cancel-trips", `{"reason":"the account was deleted"}`, http.StatusOK, `["S1-20240115",`},
+		{"get the policy", nil, "GET", "/api/v1/policy", "", http.StatusOK, `{"cancel_cutoff":"30m0s"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, fake, v := newHandlerTest(t)
+			if test.setup != nil {
+				test.setup(fake)
+			}
+			checkResponse(t, test.method+" "+test.path, v.Do(r, request(test.method, test.path, test.body)), test.status, test.want)
+		})
+	}
+}
+
+func TestUpdatingATripKeepsItsOwnerAndStatus(t *testing.T) {
+	r, _, v := newHandlerTest(t)
+	enroll("T1", "P1")
+
+	// O2 cannot publish trips, but the update is checked against T1's own car owner O1
+	update := strings.Replace(tripJSON("O2", tripStart.Add(time.Hour)), "}", `,"started":true,"cancelled":true}`, 1)
+	checkResponse(t, "PUT T1", v.Do(r, request("PUT", "/api/v1/trips/T1", update)), http.StatusAccepted, "Trip PUT T1 successfully")
+	trip, _ := store.Get("T1")
+	if trip.CarOwnerID != "O1" || trip.Started || trip.Cancelled || !trip.IsEnrolled("P1") || !trip.StartTime.Equal(tripStart.Add(time.Hour)) {
+		t.Errorf("T1 was updated to %+v, want O1's trip with P1, moved an hour later and neither started nor cancelled", trip)
+	}
+
+	created := strings.Replace(tripJSON("O1", tripStart), "}", `,"started":true,"cancelled":true}`, 1)
+	checkResponse(t, "POST T2", v.Do(r, request("POST", "/api/v1/trips/T2", created)), http.StatusAccepted, "Trip POST T2 successfully")
+	if trip, _ := store.Get("T2"); trip.Started || trip.Cancelled {
+		t.Errorf("T2 was published started %v and cancelled %v, want neither", trip.Started, trip.Cancelled)
+	}
+}
+
+func TestATripCancelledWithItsOwnerCannotBeUpdated(t *testing.T) {
+	r, _, v := newHandlerTest(t)
+
+	v.Do(r, request("POST", "/api/v1/owners/O1/cancel-trips", `{"reason":"the account was deleted"}`))
+	checkResponse(t, "PUT T1", v.Do(r, request("PUT", "/api/v1/trips/T1", tripJSON("O1", tripStart))),
+		http.StatusBadRequest, "Error - Trip has been cancelled and cannot be changed")
+	if trip, _ := store.Get("T1"); !trip.Cancelled {
+		t.Error("the update brought T1 back")
+	}
+}
+
+func TestStartingATrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(fake *clock.Fake)
+		now        time.Time
+		carOwnerID string
+		status     int
+		want       string
+	}{
+		{"start 30 minutes early", nil, tripStart.Add(-30 * time.Minute), "O1", http.StatusAccepted, "Trip T1 started successfully"},
+		{"start 30 minutes late", nil, tripStart.Add(30 * time.Minute), "O1", http.StatusAccepted, "Trip T1 started successfully"},
+		{"start 31 minutes early", nil, tripStart.Add(-31 * time.Minute), "O1",
+			http.StatusBadRequest, "Error - Trips can only be started from 30m before until 30m after the scheduled time"},
+		{"start 31 minutes late", nil, tripStart.Add(31 * time.Minute), "O1",
+			http.StatusBadRequest, "Error - Trips can only be started from 30m before until 30m after the scheduled time"},
+		{"start someone else's trip", nil, tripStart, "P1", http.StatusUnauthorized, "Error - Only the car owner can start the trip"},
+		{"start a trip twice", func(*clock.Fake) { store.Start("T1", nil) }, tripStart, "O1", http.StatusBadRequest, "Error - Trip is already started"},
+		{"start a cancelled trip", func(*clock.Fake) { store.Cancel("T1", "the car owner is ill") }, tripStart, "O1",
+			http.StatusBadRequest, "Error - Trip has been cancelled"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, fake, v := newHandlerTest(t)
+			v.Do(r, request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`))
+			if test.setup != nil {
+				test.setup(fake)
+			}
+			fake.Set(test.now)
+
+			start := request("PUT", "/api/v1/trips/T1/start", "")
+			start.Header.Set("car-owner-id", test.carOwnerID)
+			checkResponse(t, "PUT /api/v1/trips/T1/start", v.Do(r, start), test.status, test.want)
+		})
+	}
+
+	t.Run("start a trip without passengers", func(t *testing.T) {
+		r, fake, v := newHandlerTest(t)
+		fake.Set(tripStart)
+		start := request("PUT", "/api/v1/trips/T1/start", "")
+		start.Header.Set("car-owner-id", "O1")
+		checkResponse(t, "PUT /api/v1/trips/T1/start", v.Do(r, start), http.StatusBadRequest, "Error - Trip cannot start without any enrolled passengers")
+	})
+	t.Run("start a missing trip", func(t *testing.T) {
+		r, _, v := newHandlerTest(t)
+		start := request("PUT", "/api/v1/trips/T9/start", "")
+		start.Header.Set("car-owner-id", "O1")
+		checkResponse(t, "PUT /api/v1/trips/T9/start", v.Do(r, start), http.StatusNotFound, "Invalid trip ID")
+	})
+}
+
+// TestInvalidRequests sends requests the OpenAPI document does not allow, they must be rejected with
+// a 400 starting with want
+func TestInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{"create a trip from invalid JSON", "POST", "/api/v1/trips/T2", `{"car_owner_id":`, "Invalid request payload"},
+		{"create a trip without seats", "POST", "/api/v1/trips/T2", strings.Replace(tripJSON("O1", tripStart), `"total_seats":3`, `"total_seats":0`, 1),
+			"Error - Total seats must be greater than zero"},
+		{"create a trip without a destination", "POST", "/api/v1/trips/T2", strings.Replace(tripJSON("O1", tripStart), `"destination":"Ngee Ann Polytechnic",`, "", 1),
+			"Error - Car owner, pickup location, destination and start time are required"},
+		{"update a trip without seats", "PUT", "/api/v1/trips/T1", strings.Replace(tripJSON("O1", tripStart), `"total_seats":3`, `"total_seats":0`, 1),
+			"Error - Total seats must be greater than zero"},
+		{"update a trip without a pickup location", "PUT", "/api/v1/trips/T1", strings.Replace(tripJSON("O1", tripStart), `"pickup_location":"Ang Mo Kio",`, "", 1),
+			"Error - Car owner, pickup location, destination and start time are required"},
+		{"enroll from invalid JSON", "PUT", "/api/v1/trips/T1/enroll", `["P1"]`, "Invalid request payload"},
+		{"enroll without a user", "PUT", "/api/v1/trips/T1/enroll", `{}`, "Error - User ID is required in the request payload"},
+		{"withdraw from invalid JSON", "PUT", "/api/v1/trips/T1/withdraw", `["P1"]`, "Invalid request payload"},
+		{"withdraw without a user", "PUT", "/api/v1/trips/T1/withdraw", `{}`, "Error - User ID is required in the request payload"},
+		{"create a series from invalid JSON", "POST", "/api/v1/series/S2", `{`, "Invalid request payload"},
+		{"create a series without seats", "POST", "/api/v1/series/S2", strings.Replace(seriesJSON, `"total_seats":3`, `"total_seats":0`, 1),
+			"Error - total seats must be greater than zero"},
+		{"skip from invalid JSON", "POST", "/api/v1/series/S1/skip", `"2024-01-16"`, "Invalid request payload"},
+		{"skip an invalid date", "POST", "/api/v1/series/S1/skip", `{"date":"16/01/2024"}`, "Error - Date must be in the format 2006-01-02"},
+		{"cancel an owner's trips from invalid JSON", "POST", "/api/v1/owners/O1/cancel-trips", `the account was deleted`, "Invalid request payload"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _, v := newHandlerTest(t)
+			checkResponse(t, test.method+" "+test.path, v.DoInvalid(r, request(test.method, test.path, test.body)), http.StatusBadRequest, test.want)
+		})
+	}
+}
+
+func TestEnrollingUpdatesTheSeatsLeft(t *testing.T) {
+	r, _, v := newHandlerTest(t)
+	v.Do(r, request("PUT", "/api/v1/trips/T1", tripJSON("O1", tripStart)))
+	v.Do(r, request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`))
+
+	if trip, _ := store.Get("T1"); trip.AvailableSeats != 2 || len(trip.EnrolledPassengers) != 1 {
+		t.Errorf("the trip has %d seats left and passengers %v, want 2 and [P1]", trip.AvailableSeats, trip.EnrolledPassengers)
+	}
+	if trip, _ := store.Get("T1"); trip.ID != "T1" {
+		t.Errorf("the trip was stored with ID %q", trip.ID)
+	}
+}
+
+func TestAFullTripTakesNoMorePassengers(t *testing.T) {
+	r, _, v := newHandlerTest(t)
+	v.Do(r, request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`))
+	checkResponse(t, "PUT T1/enroll", v.Do(r, request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P2"}`)),
+		http.StatusBadRequest, "Error - No seats left on this trip")
+
+	if trip, _ := store.Get("T1"); trip.AvailableSeats != 0 || len(trip.EnrolledPassengers) != 1 {
+		t.Errorf("the trip has %d seats left and passengers %v, want 0 and [P1]", trip.AvailableSeats, trip.EnrolledPassengers)
+	}
+}
+
+func TestWithdrawingFreesTheSeat(t *testing.T) {
+	r, _, v := newHandlerTest(t)
+	v.Do(r, request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`))
+	v.Do(r, request("PUT", "/api/v1/trips/T1/withdraw", `{"user_id":"P1"}`))
+
+	response := v.Do(r, request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P2"}`))
+	checkResponse(t, "PUT /api/v1/trips/T1/enroll", response, http.StatusAccepted, "User P2 enrolled in trip T1 successfully")
+	if trip, _ := store.Get("T1"); trip.AvailableSeats != 0 || len(trip.EnrolledPassengers) != 1 || trip.EnrolledPassengers[0] != "P2" {
+		t.Errorf("the trip has %d seats left and passengers %v, want 0 and [P2]", trip.AvailableSeats, trip.EnrolledPassengers)
+	}
+}
+
+// TestConcurrentEnrollmentsCannotOverbook enrolls many passengers at once in trip T1, which has a
+// single seat, and checks that only one of them gets it
+func TestConcurrentEnrollmentsCannotOverbook(t *testing.T) {
+	r, _, _ := newHandlerTest(t)
+
+	const passengers = 20
+	statuses := make(chan int, passengers)
+	var wg sync.WaitGroup
+	for i := 0; i < passengers; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, request("PUT", "/api/v1/trips/T1/enroll", fmt.Sprintf(`{"user_id":%q}`, userID)))
+			statuses <- recorder.Code
+		}(fmt.Sprintf("P%d", i%(passengers/2)))
+	}
+	wg.Wait()
+	close(statuses)
+
+	accepted := 0
+	for status := range statuses {
+		if status == http.StatusAccepted {
+			accepted++
+		}
+	}
+	if trip, _ := store.Get("T1"); accepted != 1 || len(trip.EnrolledPassengers) != 1 {
+		t.Errorf("%d enrollments were accepted and the trip has passengers %v, want 1", accepted, trip.EnrolledPassengers)
+	}
+}
+
+func TestReplayingEnrollmentsAndWithdrawals(t *testing.T) {
+	event := func(seq int64, eventType, data string) eventlog.Event {
+		return eventlog.Event{Seq: seq, Type: eventType, EntityID: "T1", Time: testNow, Data: json.RawMessage(data)}
+	}
+	// A log written before enrollments were checked under the store's lock can enroll P1 twice
+	state, err := projectTrips([]eventlog.Event{
+		event(1, TripPublished, `{"id":"T1","total_seats":3}`),
+		event(2, PassengerEnrolled, `{"user_id":"P1"}`),
+		event(3, PassengerEnrolled, `{"user_id":"P1"}`),
+		event(4, PassengerEnrolled, `{"user_id":"P2"}`),
+		event(5, PassengerWithdrew, `{"user_id":"P1"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trip := state.trips["T1"]; len(trip.EnrolledPassengers) != 1 || trip.EnrolledPassengers[0] != "P2" || trip.AvailableSeats != 2 {
+		t.Errorf("the replayed trip has passengers %v and %d seats left, want [P2] and 2", trip.EnrolledPassengers, trip.AvailableSeats)
+	}
+}
+
+func TestReplayingTheLogToAnEarlierTime(t *testing.T) {
+	r, fake, v := newHandlerTest(t)
+
+	// P1 enrolls half an hour after T1 is published and withdraws half an hour later
+	fake.Advance(30 * time.Minute)
+	v.Do(r, request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`))
+	fake.Advance(30 * time.Minute)
+	v.Do(r, request("PUT", "/api/v1/trips/T1/withdraw", `{"user_id":"P1"}`))
+
+	tests := []struct {
+		at         string
+		passengers []string
+		seats      int
+	}{
+		{testNow.Add(15 * time.Minute).Format(time.RFC3339), nil, 1},
+		{testNow.Add(45 * time.Minute).Format(time.RFC3339), []string{"P1"}, 0},
+		{"", nil, 1},
+	}
+	for _, test := range tests {
+		var printed bytes.Buffer
+		if err := printReplay(&printed, store.events, test.at); err != nil {
+			t.Fatal(err)
+		}
+		var state struct {
+			Trips  map[string]model.Trip       `json:"trips"`
+			Series map[string]model.TripSeries `json:"series"`
+		}
+		if err := json.Unmarshal(printed.Bytes(), &state); err != nil {
+			t.Fatal(err)
+		}
+		trip := state.Trips["T1"]
+		if fmt.Sprint(trip.EnrolledPassengers) != fmt.Sprint(test.passengers) || trip.AvailableSeats != test.seats {
+			t.Errorf("replayed at %q, T1 has passengers %v and %d seats left, want %v and %d",
+				test.at, trip.EnrolledPassengers, trip.AvailableSeats, test.passengers, test.seats)
+		}
+		if _, ok := state.Series["S1"]; !ok {
+			t.Errorf("replayed at %q, series S1 is missing", test.at)
+		}
+	}
+}
+
+func TestEnrollmentsAreAudited(t *testing.T) {
+	r, _, v := newHandlerTest(t)
+
+	enrollment := request("PUT", "/api/v1/trips/T1/enroll", `{"user_id":"P1"}`)
+	enrollment.Header.Set("X-Client-ID", "console")
+	enrollment.Header.Set("X-Request-ID", "req-1")
+	enrollment.Header.Set("X-Forwarded-For", "203.0.113.9")
+	v.Do(r, enrollment)
+
+	var records []audit.Record
+	json.NewDecoder(v.Do(r, request("GET", "/api/v1/audit?entity=trip&id=T1", "")).Body).Decode(&records)
+	if len(records) != 2 {
+		t.Fatalf("the audit log has %d records of T1, want the publication and the enrollment", len(records))
+	}
+	record := records[1]
+	if record.Actor != "console" || record.SourceIP != "203.0.113.9" || record.RequestID != "req-1" || record.Status != http.StatusAccepted {
+		t.Errorf("unexpected audit record %+v", record)
+	}
+	var before, after model.Trip
+	json.Unmarshal(record.Before, &before)
+	json.Unmarshal(record.After, &after)
+	if len(before.EnrolledPassengers) != 0 || len(after.EnrolledPassengers) != 1 || after.EnrolledPassengers[0] != "P1" {
+		t.Errorf("the audit log recorded passengers %v before and %v after, want none and [P1]", before.EnrolledPassengers, after.EnrolledPassengers)
+	}
+}
+
+func TestChangesToAMissingTripAreRejected(t *testing.T) {
+	newHandlerTest(t)
+
+	// A change to a trip that does not exist would otherwise leave a half-built trip in the view
+	for name, change := range map[string]func() error{
+		"start":  func() error { return store.Start("T9", nil) },
+		"cancel": func() error { return store.Cancel("T9", "the car owner is ill") },
+		"delete": func() error { return store.Delete("T9", nil) },
+	} {
+		if err := change(); !errors.Is(err, errTripNotFound) {
+			t.Errorf("%s returned %v, want %v", name, err, errTripNotFound)
+		}
+	}
+	if _, ok := store.Get("T9"); ok {
+		t.Error("a change to the missing trip T9 created it")
+	}
+	if err := store.SkipSeriesDate("S9", "2024-01-16"); !errors.Is(err, errSeriesNotFound) {
+		t.Errorf("skipping a date of a missing series returned %v, want %v", err, errSeriesNotFound)
+	}
+}
+
+func TestRecurringTripsArePublishedUpToTheHorizon(t *testing.T) {
+	r, fake, v := newHandlerTest(t)
+
+	// The occurrences are published up to two weeks ahead, and topped up by the publisher as time goes on
+	checkPublished := func(first, last string) {
+		t.Helper()
+		publishAllSeries()
+		ids := upcomingOccurrences("S1")
+		if len(ids) != 15 || ids[0] != first || ids[len(ids)-1] != last {
+			t.Errorf("published %v, want 15 trips from %s to %s", ids, first, last)
+		}
+	}
+	checkPublished("S1-20240115", "S1-20240129")
+	fake.Advance(24 * time.Hour)
+	checkPublished("S1-20240116", "S1-20240130")
+
+	for day := 15; day <= 30; day++ {
+		if _, ok := store.Get(fmt.Sprintf("S1-202401%02d", day)); !ok {
+			t.Errorf("the occurrence on January %d was not published", day)
+		}
+	}
+
+	// Listing the trips does not publish anything
+	fake.Advance(24 * time.Hour)
+	v.Do(r, request("GET", "/api/v1/trips", ""))
+	if _, ok := store.Get("S1-20240131"); ok {
+		t.Error("listing the trips published the occurrence on January 31")
+	}
+}
+
+func TestADayAddedBackToASeriesIsPublishedAgain(t *testing.T) {
+	r, _, v := newHandlerTest(t)
+	v.Do(r, request("PUT", "/api/v1/trips/S1-20240116/enroll", `{"user_id":"P1"}`))
+
+	// Tuesday the 16th is dropped, which cancels its occurrence, and then added back
+	v.Do(r, request("PUT", "/api/v1/series/S1", strings.Replace(seriesJSON, `["DAILY"]`, `["MO","WE","TH","FR","SA","SU"]`, 1)))
+	if trip, _ := store.Get("S1-20240116"); !trip.Cancelled {
+		t.Fatal("the occurrence on the dropped day was not cancelled")
+	}
+	v.Do(r, request("PUT", "/api/v1/series/S1", seriesJSON))
+
+	if trip, _ := store.Get("S1-20240116"); trip.Cancelled || len(trip.EnrolledPassengers) != 0 || trip.AvailableSeats != 3 {
+		t.Errorf("the occurrence is %+v, want it published again with every seat free", trip)
+	}
+}
+
+// TestImportingTrips imports trips into the service of newHandlerTest. The response must match the
+// status and contain want, and afterwards T2 must exist if stored is true.
+func TestImportingTrips(t *testing.T) {
+	const header = "id,car_owner_id,pickup_location,start_time,destination,enrolled_passengers,total_seats,started\n"
+	// T2 left yesterday with P1, past trips are imported as they are
+	imported := func(from, to string) string {
+		return strings.Replace(`{"id":"T2","car_owner_id":"O1","pickup_location":"Bishan","start_time":"2024-01-14T08:00:00Z",`+
+			`"destination":"Orchard","available_seats":3,"enrolled_passengers":["P1"],"total_seats":2,"started":true,"cancelled":false}`, from, to, 1)
+	}
+	taken := imported(`"T2"`, `"T1"`)
+
+	tests := []struct {
+		name        string
+		setup       func()
+		query       string
+		contentType string
+		body        string
+		status      int
+		want        string
+		stored      bool
+	}{
+		{"import a past trip", nil, "", "application/json", "[" + imported("", "") + "]",
+			http.StatusAccepted, `"rows":[{"row":1,"id":"T2","result":"created"}]`, true},
+		{"import trips from CSV", nil, "", "text/csv", header + "T2,O1,Bishan,2024-01-14T08:00:00Z,Orchard,P1,2,true\n",
+			http.StatusAccepted, `"created":1`, true},
+		{"dry run", nil, "?dry_run=1", "application/json", "[" + imported("", "") + "]", http.StatusOK, `"dry_run":true`, false},
+		{"import a taken ID", nil, "", "application/json", "[" + imported("", "") + "," + taken + "]",
+			http.StatusConflict, `{"row":2,"id":"T1","result":"failed","error":"trip T1 already exists"}`, false},
+		{"overwrite a taken ID", nil, "?on_conflict=overwrite", "application/json", "[" + imported("", "") + "," + taken + "]",
+			http.StatusAccepted, `"created":1,"updated":1`, true},
+		{"import a trip of a missing car owner", nil, "", "application/json", "[" + imported(`"O1"`, `"X1"`) + "]",
+			http.StatusBadRequest, `"error":"car owner X1 does not exist"`, false},
+		{"import a trip of a passenger", nil, "", "application/json", "[" + imported(`"car_owner_id":"O1"`, `"car_owner_id":"P1"`) + "]",
+			http.StatusBadRequest, `"error":"only car owners can create trips"`, false},
+		{"import a trip with a missing passenger", nil, "", "application/json", "[" + imported(`["P1"]`, `["P9"]`) + "]",
+			http.StatusBadRequest, `"error":"passenger P9 does not exist"`, false},
+		{"import more passengers than seats", nil, "", "application/json", "[" + imported(`["P1"]`, `["P1","O2","O1"]`) + "]",
+			http.StatusBadRequest, `"error":"more passengers are enrolled than the trip has seats"`, false},
+		{"import a trip while the User service is down", userServiceDown, "", "application/json", "[" + imported("", "") + "]",
+			http.StatusBadGateway, "Error - Could not check the car owner", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _, v := newHandlerTest(t)
+			if test.setup != nil {
+				test.setup()
+			}
+
+			req := request("POST", "/api/v1/admin/trips/import"+test.query, test.body)
+			req.Header.Set("Content-Type", test.contentType)
+			response := v.Do(r, req)
+			body, _ := io.ReadAll(response.Body)
+			if response.StatusCode != test.status || !strings.Contains(string(body), test.want) {
+				t.Errorf("the import returned %d %s, want %d %s", response.StatusCode, body, test.status, test.want)
+			}
+			if trip, stored := store.Get("T2"); stored != test.stored {
+				t.Errorf("T2 stored is %v, want %v", stored, test.stored)
+			} else if stored && trip.AvailableSeats != 1 {
+				t.Errorf("T2 was stored with %d seats left, want 1", trip.AvailableSeats)
+			}
+		})
+	}
+}
+
+func TestAnImportFailsIfATripChangesWhileItIsChecked(t *testing.T) {
+	r, _, v := newHandlerTest(t)
+
+	// T1 is updated by another request while the import checks its car owner with the User service
+	updated := false
+	userClient = newUserClient("http://user-service", v.Transport(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !updated {
+			updated = true
+			v.Do(r, request("PUT", "/api/v1/trips/T1", tripJSON("O1", tripStart.Add(time.Hour))))
+		}
+		userServiceStub.ServeHTTP(w, req)
+	})))
+
+	body := `[{"id":"T1","car_owner_id":"O1","pickup_location":"Bishan","start_time":"2024-01-14T08:00:00Z","destination":"Orchard","total_seats":2},` +
+		`{"id":"T2","car_owner_id":"O1","pickup_location":"Bishan","start_time":"2024-01-14T08:00:00Z","destination":"Orchard","total_seats":2}]`
+	response := v.Do(r, request("POST", "/api/v1/admin/trips/import?on_conflict=overwrite", body))
+	text, _ := io.ReadAll(response.Body)
+	if want := "Error - The trip T1 was changed during the import, nothing was imported"; response.StatusCode != http.StatusConflict || string(text) != want {
+		t.Errorf("the import returned %d %s, want %d %s", response.StatusCode, text, http.StatusConflict, want)
+	}
+	if _, stored := store.Get("T2"); stored {
+		t.Error("T2 was imported")
+	}
+	if trip, _ := store.Get("T1"); !trip.StartTime.Equal(tripStart.Add(time.Hour)) {
+		t.Errorf("T1 leaves at %v, want the update to %v kept", trip.StartTime, tripStart.Add(time.Hour))
+	}
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/Zachisastudent/ETI_Assignment-1/certs"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+	"github.com/Zachisastudent/ETI_Assignment-1/eventlog"
+	"github.com/Zachisastudent/ETI_Assignment-1/health"
+	"github.com/Zachisastudent/ETI_Assignment-1/logging"
+	"github.com/Zachisastudent/ETI_Assignment-1/metrics"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/Zachisastudent/ETI_Assignment-1/server"
+	"github.com/gorilla/mux"
+)
+
+var (
+	store      *tripStore
+	userClient *UserClient
+)
+
+// clk tells the handlers the time, the tests replace it with a clock.Fake
+var clk = clock.System
+
+// displayZone is the time zone used when times are shown to people, set with display.time_zone.
+// Trip times are always stored and returned in UTC.
+var displayZone = time.Local
+
+func main() {
+	replay := flag.Bool("replay", false, "print the trips and series rebuilt from the event log and exit")
+	at := flag.String("at", "", "with -replay, the RFC 3339 time to rebuild the state at (default now)")
+	cfg, err := config.Load("trip-service", flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger, err := logging.New("trip-service", cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	policy = newPolicy(cfg.Policy)
+	displayZone, _ = cfg.Display.Location()
+	// HTTPS, with mutual TLS the service only accepts callers presenting a certificate from the CA
+	serverTLS, err := certs.ServerTLS(cfg.TLS, cfg.TLS.Mutual)
+	if err != nil {
+		logger.Error("setting up TLS", "error", err)
+		os.Exit(1)
+	}
+	clientTLS, err := certs.ClientTLS(cfg.TLS, true)
+	if err != nil {
+		logger.Error("setting up TLS", "error", err)
+		os.Exit(1)
+	}
+	userClient = newUserClient(cfg.UserService.URL, certs.Transport(clientTLS))
+
+	// The event log is the source of truth, the trips and series are rebuilt from it
+	eventLogPath, _ := cfg.TripService.EventLogPath()
+	events, err := eventlog.Open(eventLogPath)
+	if err != nil {
+		logger.Error("opening the event log", "path", eventLogPath, "error", err)
+		os.Exit(1)
+	}
+
+	if *replay {
+		if err := printReplay(os.Stdout, events, *at); err != nil {
+			logger.Error("replaying the event log", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	store, err = newTripStore(events)
+	if err != nil {
+		logger.Error("replaying the event log", "error", err)
+		os.Exit(1)
+	}
+
+	auditLog, err := audit.Open(cfg.TripService.AuditLog)
+	if err != nil {
+		logger.Error("opening the audit log", "path", cfg.TripService.AuditLog, "error", err)
+		os.Exit(1)
+	}
+
+	r := newRouter(logger, events, auditLog)
+
+	serverConfig := cfg.HTTP.Server(cfg.TripService.ListenAddr)
+	serverConfig.TLS = serverTLS
+	// Occurrences of the recurring trips are published as they come within the horizon
+	publishAllSeries()
+	publisherCtx, stopPublisher := context.WithCancel(context.Background())
+	go runSeriesPublisher(publisherCtx, seriesPublishInterval)
+
+	logger.Info("starting trip service", "addr", serverConfig.Addr, "tls", serverTLS != nil, "mutual_tls", cfg.TLS.Mutual)
+	runErr := server.Run(logger, serverConfig, r)
+	stopPublisher()
+
+	// No requests are running any more, so the logs can be flushed to disk
+	if err := errors.Join(auditLog.Close(), events.Close()); err != nil {
+		logger.Error("flushing the store", "error", err)
+		os.Exit(1)
+	}
+	if runErr != nil {
+		logger.Error("trip service stopped", "error", runErr)
+		os.Exit(1)
+	}
+	logger.Info("trip service stopped")
+}
+
+// newRouter returns the service's routes, the store and the User service client must be set up first
+func newRouter(logger *slog.Logger, events *eventlog.Log, auditLog *audit.Log) *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/api/v1/trips/{id}", getTrip).Methods("GET", "DELETE")
+	r.HandleFunc("/api/v1/trips", getAllTrips).Methods("GET")
+	r.HandleFunc("/api/v1/trips/{id}", createOrUpdateTrip).Methods("POST", "PUT")
+	// Add a new route for enrolling passengers
+	r.HandleFunc("/api/v1/trips/{id}/enroll", enrollPassenger).Methods("PUT")
+	r.HandleFunc("/api/v1/trips/{id}/withdraw", withdrawPassenger).Methods("PUT")
+	r.HandleFunc("/api/v1/trips/{id}/start", startTrip).Methods("PUT")
+
+	r.HandleFunc("/api/v1/series/{id}", getSeries).Methods("GET", "DELETE")
+	r.HandleFunc("/api/v1/series", getAllSeries).Methods("GET")
+	r.HandleFunc("/api/v1/series/{id}", createOrUpdateSeries).Methods("POST", "PUT")
+	r.HandleFunc("/api/v1/series/{id}/skip", skipSeriesOccurrence).Methods("POST")
+
+	r.HandleFunc("/api/v1/policy", getPolicy).Methods("GET")
+
+	// Every trip can be exported and imported again, the gateway only lets admins do it
+	trips := bulkTrips()
+	r.HandleFunc("/api/v1/admin/trips/export", trips.Export).Methods("GET")
+	r.HandleFunc("/api/v1/admin/trips/import", trips.Import).Methods("POST")
+
+	// Called by the User service when a car owner is downgraded or deleted
+	r.HandleFunc("/api/v1/owners/{id}/scheduled-trips", getScheduledTrips).Methods("GET")
+	r.HandleFunc("/api/v1/owners/{id}/cancel-trips", cancelOwnerTrips).Methods("POST")
+
+	// Circuit breaker state of the calls to the User service, and the Prometheus metrics
+	r.Handle("/debug/vars", expvar.Handler())
+	r.Handle("/metrics", metrics.Handler())
+
+	// Liveness and readiness for deployments, the service is ready when it can write its
+	// event log and reach the User service it calls
+	checker := health.NewChecker(
+		health.Check{Name: "event_log", Check: func(context.Context) error { return events.Check() }},
+		health.Check{Name: "user_service", Check: userClient.Healthy},
+	)
+	r.HandleFunc("/healthz", checker.Live).Methods("GET")
+	r.HandleFunc("/readyz", checker.Ready).Methods("GET")
+
+	// Every request is logged, timed and, if it changes anything, recorded in the audit log.
+	// The gateway only lets admins query the audit log.
+	r.HandleFunc("/api/v1/audit", auditLog.Handler).Methods("GET")
+	r.Use(logging.Middleware(logger), metrics.Middleware, auditLog.Middleware(auditTarget))
+
+	return r
+}
+
+// printReplay writes the trips and series as they were at the given time, or now if it is empty
+func printReplay(w io.Writer, events *eventlog.Log, at string) error {
+	until := clk.Now()
+	if at != "" {
+		var err error
+		if until, err = time.Parse(time.RFC3339, at); err != nil {
+			return err
+		}
+	}
+
+	state, err := projectTrips(events.Until(until))
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{
+		"at":     until.UTC(),
+		"trips":  state.trips,
+		"series": state.series,
+	})
+}
+
+// writeJSON writes v as the JSON body of the response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeStoreError writes the response for a change that could not be recorded in the event log
+func writeStoreError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "Error - Could not record the change: %v", err)
+}
+
+// writeRuleError writes the response to a request that breaks one of the car-pooling rules
+func writeRuleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, model.ErrNotTripOwner) {
+		w.WriteHeader(http.StatusUnauthorized)
+	} else {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	fmt.Fprint(w, model.ErrorMessage(err))
+}
+
+// getTrip handles GET and DELETE requests for a specific trip
+func getTrip(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+
+	if trip, ok := store.Get(tripID); ok {
+		if r.Method == "GET" {
+			writeJSON(w, trip)
+		} else if r.Method == "DELETE" {
+			// Check that the trip has not started and is still before the cancellation cut-off
+			var ruleErr error
+			err := store.Delete(tripID, func(stored model.Trip) error {
+				trip = stored
+				ruleErr = trip.CheckCancel(policy, clk.Now())
+				return ruleErr
+			})
+			switch {
+			case errors.Is(err, errTripNotFound):
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, "Invalid trip ID")
+				return
+			case ruleErr != nil:
+				writeRuleError(w, ruleErr)
+				return
+			case err != nil:
+				writeStoreError(w, err)
+				return
+			}
+
+			notifyPassengers(tripID, trip, fmt.Sprintf("Trip %s to %s at %s has been cancelled by the car owner",
+				tripID, trip.Destination, trip.StartTime.In(displayZone).Format("2006-01-02 15:04 MST")))
+			fmt.Fprintf(w, "Trip %s deleted", tripID)
+		}
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid trip ID")
+	}
+}
+
+// getAllTrips handles GET requests to retrieve all trips
+func getAllTrips(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, store.All())
+}
+
+// createOrUpdateTrip handles POST and PUT requests to create or update a trip
+func createOrUpdateTrip(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+	var trip model.Trip
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&trip); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+	trip.ID = tripID
+
+	// An update keeps the car owner of the stored trip, so that is the one to check
+	if existingTrip, ok := store.Get(tripID); ok {
+		trip.CarOwnerID = existingTrip.CarOwnerID
+	}
+	logging.SetUserID(r, trip.CarOwnerID)
+	if !checkCarOwner(w, trip.CarOwnerID) {
+		return
+	}
+	trip.StartTime = trip.StartTime.UTC()
+
+	var ruleErr error
+	err := store.Update(tripID, func(stored model.Trip, exists bool) (model.Trip, error) {
+		// A trip is only started or cancelled by its own requests, and an update keeps the car
+		// owner, passengers and series of the stored trip
+		trip.Started, trip.Cancelled = false, false
+		if exists {
+			trip.CarOwnerID = stored.CarOwnerID
+			trip.EnrolledPassengers = stored.EnrolledPassengers
+			trip.SeriesID = stored.SeriesID
+			trip.Started, trip.Cancelled = stored.Started, stored.Cancelled
+			ruleErr = stored.CheckUpdate(trip, policy, clk.Now())
+		} else {
+			// Check that the trip is complete and far enough in the future
+			ruleErr = trip.CheckPublish(policy, clk.Now())
+		}
+		trip.UpdateSeats()
+		return trip, ruleErr
+	})
+	switch {
+	case ruleErr != nil:
+		writeRuleError(w, ruleErr)
+		return
+	case err != nil:
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Trip %s %s successfully", r.Method, tripID)
+}
+
+// checkCarOwner asks the User service whether the user can publish trips.
+// If they cannot, or the User service cannot be reached, the error response is written and false is returned.
+func checkCarOwner(w http.ResponseWriter, carOwnerID string) bool {
+	carOwner, err := userClient.GetUser(carOwnerID)
+	if errors.Is(err, ErrUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Error - Car owner does not exist")
+		return false
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "Error - Could not check the car owner: %v", err)
+		return false
+	}
+
+	// Check that the user has a complete car owner profile
+	if err := carOwner.CheckCarOwner(); err != nil {
+		writeRuleError(w, err)
+		return false
+	}
+
+	return true
+}
+
+// enrollPassenger handles the enrollment of passengers in a trip
+func enrollPassenger(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+
+	var enrollmentData map[string]string
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&enrollmentData); err != nil {
+		rejectEnrollment("invalid_request")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+
+	userID, exists := enrollmentData["user_id"]
+	if !exists {
+		rejectEnrollment("invalid_request")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Error - User ID is required in the request payload")
+		return
+	}
+	logging.SetUserID(r, userID)
+
+	// Check that the trip is open for enrollment, the user is not on it yet and a seat is left,
+	// and add the user to the EnrolledPassengers of the trip in the same step
+	var ruleErr error
+	err := store.Enroll(tripID, userID, func(trip model.Trip) error {
+		ruleErr = trip.CheckEnroll(userID, policy, clk.Now())
+		return ruleErr
+	})
+	switch {
+	case errors.Is(err, errTripNotFound):
+		rejectEnrollment("trip_not_found")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid trip ID")
+	case ruleErr != nil:
+		rejectEnrollment(enrollmentRejection(ruleErr))
+		writeRuleError(w, ruleErr)
+	case err != nil:
+		rejectEnrollment("store_error")
+		writeStoreError(w, err)
+	default:
+		enrollments.WithLabelValues("accepted", "").Inc()
+
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "User %s enrolled in trip %s successfully", userID, tripID)
+	}
+}
+
+// withdrawPassenger handles a passenger withdrawing from a trip they are enrolled in
+func withdrawPassenger(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+
+	var withdrawalData map[string]string
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&withdrawalData); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+
+	userID, exists := withdrawalData["user_id"]
+	if !exists {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Error - User ID is required in the request payload")
+		return
+	}
+	logging.SetUserID(r, userID)
+
+	// Check that the user is on a trip that has not started and the withdrawal is before the cut-off
+	var ruleErr error
+	err := store.Withdraw(tripID, userID, func(trip model.Trip) error {
+		ruleErr = trip.CheckWithdraw(userID, policy, clk.Now())
+		return ruleErr
+	})
+	switch {
+	case errors.Is(err, errTripNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid trip ID")
+	case ruleErr != nil:
+		writeRuleError(w, ruleErr)
+	case err != nil:
+		writeStoreError(w, err)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "User %s withdrew from trip %s successfully", userID, tripID)
+	}
+}
+
+// startTrip handles the starting of a trip
+func startTrip(w http.ResponseWriter, r *http.Request) {
+	tripID := mux.Vars(r)["id"]
+
+	// Retrieve the car owner ID from the request header
+	carOwnerID := r.Header.Get("car-owner-id")
+	logging.SetUserID(r, carOwnerID)
+
+	// Check that the car owner is starting a trip with passengers within its start window
+	var ruleErr error
+	err := store.Start(tripID, func(trip model.Trip) error {
+		ruleErr = trip.CheckStart(carOwnerID, policy, clk.Now())
+		return ruleErr
+	})
+	switch {
+	case errors.Is(err, errTripNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid trip ID")
+		return
+	case ruleErr != nil:
+		writeRuleError(w, ruleErr)
+		return
+	case err != nil:
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Trip %s started successfully", tripID)
+}
+
+// getScheduledTrips handles GET requests for the IDs of an owner's scheduled trips
+func getScheduledTrips(w http.ResponseWriter, r *http.Request) {
+	ownerID := mux.Vars(r)["id"]
+
+	writeJSON(w, scheduledTripsOwnedBy(ownerID))
+}
+
+// cancelOwnerTrips handles POST requests to cancel every scheduled trip and recurring series of an owner
+func cancelOwnerTrips(w http.ResponseWriter, r *http.Request) {
+	ownerID := mux.Vars(r)["id"]
+
+	var cancelData map[string]string
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&cancelData); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+
+	for seriesID, tripSeries := range store.AllSeries() {
+		if tripSeries.CarOwnerID == ownerID {
+			if err := store.DeleteSeries(seriesID); err != nil && !errors.Is(err, errSeriesNotFound) {
+				writeStoreError(w, err)
+				return
+			}
+		}
+	}
+
+	cancelled := scheduledTripsOwnedBy(ownerID)
+	for _, tripID := range cancelled {
+		if err := cancelScheduledTrip(tripID, cancelData["reason"]); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+	}
+
+	writeJSON(w, cancelled)
+}
+
+// scheduledTripsOwnedBy returns the IDs of trips owned by the user that have not started or departed yet
+func scheduledTripsOwnedBy(userID string) []string {
+	tripIDs := []string{}
+	for tripID, trip := range store.All() {
+		if trip.CarOwnerID == userID && !trip.Started && !trip.Cancelled && trip.StartTime.After(clk.Now()) {
+			tripIDs = append(tripIDs, tripID)
+		}
+	}
+	sort.Strings(tripIDs)
+	return tripIDs
+}
+
+// cancelScheduledTrip marks a trip as cancelled and notifies its passengers
+func cancelScheduledTrip(tripID, reason string) error {
+	trip, ok := store.Get(tripID)
+	if !ok {
+		return nil
+	}
+	// A trip deleted in the meantime has nobody left to tell
+	if err := store.Cancel(tripID, reason); errors.Is(err, errTripNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	notifyPassengers(tripID, trip, fmt.Sprintf("Trip %s to %s at %s has been cancelled because %s",
+		tripID, trip.Destination, trip.StartTime.In(displayZone).Format("2006-01-02 15:04 MST"), reason))
+	return nil
+}
+
+// notifyPassengers leaves a notification with the User service for every passenger enrolled in the trip
+func notifyPassengers(tripID string, trip model.Trip, message string) {
+	for _, passengerID := range trip.EnrolledPassengers {
+		notification := model.Notification{
+			TripID:    tripID,
+			Message:   message,
+			CreatedAt: clk.Now(),
+		}
+		if err := userClient.Notify(passengerID, notification); err != nil {
+			slog.Error("notifying passenger", "user_id", passengerID, "trip_id", tripID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+var (
+	tripsPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "carpool_trips_published_total",
+		Help: "Trips published, including the occurrences of recurring trips.",
+	})
+
+	enrollments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "carpool_enrollments_total",
+		Help: "Enrollment requests by result (accepted or rejected) and the reason for rejections.",
+	}, []string{"result", "reason"})
+)
+
+func init() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "carpool_scheduled_trips",
+		Help: "Trips that have not started, departed or been cancelled.",
+	}, func() float64 {
+		return float64(len(scheduledTrips()))
+	})
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "carpool_available_seats",
+		Help: "Seats left on the scheduled trips.",
+	}, func() float64 {
+		seats := 0
+		for _, trip := range scheduledTrips() {
+			seats += trip.SeatsLeft()
+		}
+		return float64(seats)
+	})
+}
+
+// scheduledTrips returns the trips counted by the gauges, none before the store is loaded
+func scheduledTrips() []model.Trip {
+	if store == nil {
+		return nil
+	}
+
+	var trips []model.Trip
+	for _, trip := range store.All() {
+		if !trip.Started && !trip.Cancelled && trip.StartTime.After(clk.Now()) {
+			trips = append(trips, trip)
+		}
+	}
+	return trips
+}
+
+// rejectEnrollment counts an enrollment request that was turned down
+func rejectEnrollment(reason string) {
+	enrollments.WithLabelValues("rejected", reason).Inc()
+}
+
+// enrollmentRejection returns the reason an enrollment that broke a rule is counted under
+func enrollmentRejection(err error) string {
+	switch {
+	case errors.Is(err, model.ErrTripCancelled):
+		return "trip_cancelled"
+	case errors.Is(err, model.ErrEnrollmentClosed):
+		return "enrollment_closed"
+	case errors.Is(err, model.ErrAlreadyEnrolled):
+		return "already_enrolled"
+	case errors.Is(err, model.ErrTripFull):
+		return "trip_full"
+	}
+	return "invalid_request"
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// policy holds the trip timing rules every handler checks trips against, it is set from the
+// policy section of the configuration when the service starts
+var policy model.Policy
+
+// newPolicy returns the trip timing rules of the configured policy
+func newPolicy(p config.PolicyConfig) model.Policy {
+	return model.Policy{
+		PublishLeadTime:   p.PublishLeadTime,
+		StartWindowBefore: p.StartWindowBefore,
+		StartWindowAfter:  p.StartWindowAfter,
+		CancelCutoff:      p.CancelCutoff,
+		EnrollmentCutoff:  p.EnrollmentCutoff,
+	}
+}
+
+// getPolicy handles GET requests to retrieve the trip timing policy
+func getPolicy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, policy)
+}
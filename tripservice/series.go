@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/logging"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/gorilla/mux"
+)
+
+// seriesHorizon is how far ahead recurring trips are published as concrete trips
+const seriesHorizon = 14 * 24 * time.Hour
+
+// seriesPublishInterval is how often the occurrences that have come within the horizon are published
+const seriesPublishInterval = time.Minute
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// getSeries handles GET and DELETE requests for a specific recurring trip series
+func getSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+
+	if tripSeries, ok := store.GetSeries(seriesID); ok {
+		if r.Method == "GET" {
+			writeJSON(w, tripSeries)
+		} else if r.Method == "DELETE" {
+			var cancelled []string
+			for _, tripID := range upcomingOccurrences(seriesID) {
+				if err := cancelScheduledTrip(tripID, "the recurring trip was cancelled by the car owner"); err != nil {
+					writeStoreError(w, err)
+					return
+				}
+				cancelled = append(cancelled, tripID)
+			}
+			if err := store.DeleteSeries(seriesID); errors.Is(err, errSeriesNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, "Invalid series ID")
+				return
+			} else if err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			fmt.Fprintf(w, "Series %s deleted", seriesID)
+			if len(cancelled) > 0 {
+				fmt.Fprintf(w, "; cancelled trips: %s", strings.Join(cancelled, ", "))
+			}
+		}
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid series ID")
+	}
+}
+
+// getAllSeries handles GET requests to retrieve all recurring trip series
+func getAllSeries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, store.AllSeries())
+}
+
+// createOrUpdateSeries handles POST and PUT requests to create or update a recurring trip series.
+// Updates are applied to upcoming occurrences only, trips that have already departed are left as they were.
+func createOrUpdateSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	var tripSeries model.TripSeries
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&tripSeries); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+	tripSeries.ID = seriesID
+
+	// A series keeps its car owner, as the trips published from it do
+	existingSeries, exists := store.GetSeries(seriesID)
+	if r.Method == "PUT" && exists && tripSeries.CarOwnerID != existingSeries.CarOwnerID {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Error - The car owner of a series cannot be changed")
+		return
+	}
+
+	logging.SetUserID(r, tripSeries.CarOwnerID)
+	if !checkCarOwner(w, tripSeries.CarOwnerID) {
+		return
+	}
+
+	if err := validateSeries(tripSeries); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error - %v", err)
+		return
+	}
+
+	if r.Method == "POST" && exists {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, "Error - Series already exists")
+		return
+	}
+	if r.Method == "PUT" && !exists {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid series ID")
+		return
+	}
+	if exists {
+		tripSeries.SkippedDates = existingSeries.SkippedDates
+	}
+	if tripSeries.TimeZone == "" {
+		tripSeries.TimeZone = displayZone.String()
+	}
+
+	// The upcoming occurrences that still run need a seat for each of their passengers
+	for _, tripID := range upcomingOccurrences(seriesID) {
+		trip, _ := store.Get(tripID)
+		if _, ok := occurrenceStart(tripSeries, trip.StartTime.In(seriesLocation(tripSeries))); ok {
+			if err := checkOccurrenceSeats(tripSeries, trip); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "Error - %v", err)
+				return
+			}
+		}
+	}
+
+	if err := store.PutSeries(seriesID, tripSeries); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	// Bring the upcoming occurrences in line with the new template
+	for _, tripID := range upcomingOccurrences(seriesID) {
+		trip, _ := store.Get(tripID)
+		startTime, ok := occurrenceStart(tripSeries, trip.StartTime.In(seriesLocation(tripSeries)))
+		if !ok {
+			if err := cancelScheduledTrip(tripID, "the recurring trip no longer runs on this day"); err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			continue
+		}
+
+		// The occurrence is changed as it is under the store's lock, so it keeps passengers who
+		// enrolled since it was checked
+		var before, after model.Trip
+		var seatsErr error
+		err := store.Update(tripID, func(stored model.Trip, exists bool) (model.Trip, error) {
+			if !exists {
+				return stored, errTripNotFound
+			}
+			before, after = stored, stored
+			after.PickupLocation = tripSeries.PickupLocation
+			after.AltPickupLocation = tripSeries.AltPickupLocation
+			after.Destination = tripSeries.Destination
+			after.StartTime = startTime
+			after.TotalSeats = tripSeries.TotalSeats
+			after.UpdateSeats()
+			seatsErr = checkOccurrenceSeats(tripSeries, stored)
+			return after, seatsErr
+		})
+		switch {
+		case errors.Is(err, errTripNotFound):
+			// The occurrence was deleted since it was listed
+			continue
+		case seatsErr != nil:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Error - %v", seatsErr)
+			return
+		case err != nil:
+			writeStoreError(w, err)
+			return
+		}
+
+		if !after.StartTime.Equal(before.StartTime) || after.PickupLocation != before.PickupLocation ||
+			after.AltPickupLocation != before.AltPickupLocation || after.Destination != before.Destination {
+			notifyPassengers(tripID, after, fmt.Sprintf("Trip %s has been changed by the car owner, it now leaves %s for %s at %s",
+				tripID, after.PickupLocation, after.Destination, after.StartTime.In(displayZone).Format("2006-01-02 15:04 MST")))
+		}
+	}
+
+	materialiseSeries(seriesID, tripSeries)
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Series %s %s successfully", r.Method, seriesID)
+}
+
+// skipSeriesOccurrence handles POST requests to skip a single date of a recurring trip series
+func skipSeriesOccurrence(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+
+	tripSeries, ok := store.GetSeries(seriesID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid series ID")
+		return
+	}
+
+	var skipData map[string]string
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&skipData); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", skipData["date"], seriesLocation(tripSeries))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Error - Date must be in the format 2006-01-02")
+		return
+	}
+
+	// An occurrence that has already been published is cancelled, which is subject to the cut-off
+	tripID := occurrenceID(seriesID, date)
+	trip, published := store.Get(tripID)
+	cancel := published && !trip.Started && !trip.Cancelled
+	if cancel {
+		if err := trip.CheckCancel(policy, clk.Now()); err != nil {
+			writeRuleError(w, err)
+			return
+		}
+	}
+
+	if err := store.SkipSeriesDate(seriesID, date.Format("2006-01-02")); errors.Is(err, errSeriesNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid series ID")
+		return
+	} else if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if cancel {
+		if err := cancelScheduledTrip(tripID, "the car owner skipped this occurrence"); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Occurrence %s of series %s skipped", date.Format("2006-01-02"), seriesID)
+}
+
+// checkOccurrenceSeats checks that an occurrence of the series has a seat for each of its passengers
+func checkOccurrenceSeats(tripSeries model.TripSeries, trip model.Trip) error {
+	if tripSeries.TotalSeats < len(trip.EnrolledPassengers) {
+		return fmt.Errorf("total seats cannot be fewer than the %d passengers enrolled in trip %s", len(trip.EnrolledPassengers), trip.ID)
+	}
+	return nil
+}
+
+// validateSeries checks that a recurring trip template describes at least one valid occurrence
+func validateSeries(tripSeries model.TripSeries) error {
+	if _, err := time.Parse("15:04", tripSeries.TimeOfDay); err != nil {
+		return errors.New("time of day must be in the format 15:04")
+	}
+	if _, err := parseRecurrenceDays(tripSeries.Days); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(tripSeries.TimeZone); err != nil {
+		return fmt.Errorf("unknown time zone %q", tripSeries.TimeZone)
+	}
+	if tripSeries.Until != "" {
+		until, err := time.ParseInLocation("2006-01-02", tripSeries.Until, seriesLocation(tripSeries))
+		if err != nil {
+			return errors.New("until date must be in the format 2006-01-02")
+		}
+		if until.AddDate(0, 0, 1).Before(clk.Now()) {
+			return errors.New("until date is in the past")
+		}
+	}
+	if tripSeries.TotalSeats <= 0 {
+		return errors.New("total seats must be greater than zero")
+	}
+	return nil
+}
+
+// parseRecurrenceDays turns RRULE-style day codes into the set of weekdays the series runs on
+func parseRecurrenceDays(days []string) (map[time.Weekday]bool, error) {
+	weekdays := map[time.Weekday]bool{}
+	for _, day := range days {
+		code := strings.ToUpper(strings.TrimSpace(day))
+		switch code {
+		case "WEEKDAYS":
+			for wd := time.Monday; wd <= time.Friday; wd++ {
+				weekdays[wd] = true
+			}
+		case "DAILY":
+			for wd := time.Sunday; wd <= time.Saturday; wd++ {
+				weekdays[wd] = true
+			}
+		default:
+			wd, ok := weekdayCodes[code]
+			if !ok {
+				return nil, fmt.Errorf("unknown recurrence day %q", day)
+			}
+			weekdays[wd] = true
+		}
+	}
+	if len(weekdays) == 0 {
+		return nil, errors.New("at least one recurrence day is required")
+	}
+	return weekdays, nil
+}
+
+// occurrenceStart returns the start time of the series on the given day in the series' time zone,
+// or false if the series does not run that day
+func occurrenceStart(tripSeries model.TripSeries, day time.Time) (time.Time, bool) {
+	weekdays, err := parseRecurrenceDays(tripSeries.Days)
+	if err != nil || !weekdays[day.Weekday()] {
+		return time.Time{}, false
+	}
+
+	date := day.Format("2006-01-02")
+	if tripSeries.Until != "" && date > tripSeries.Until {
+		return time.Time{}, false
+	}
+	for _, skipped := range tripSeries.SkippedDates {
+		if skipped == date {
+			return time.Time{}, false
+		}
+	}
+
+	clock, err := time.Parse("15:04", tripSeries.TimeOfDay)
+	if err != nil {
+		return time.Time{}, false
+	}
+	startTime := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, seriesLocation(tripSeries))
+	return startTime.UTC(), true
+}
+
+// seriesLocation returns the time zone the series' time of day is expressed in
+func seriesLocation(tripSeries model.TripSeries) *time.Location {
+	if tripSeries.TimeZone != "" {
+		if zone, err := time.LoadLocation(tripSeries.TimeZone); err == nil {
+			return zone
+		}
+	}
+	return displayZone
+}
+
+// occurrenceID returns the trip ID used for the occurrence of a series on the given day
+func occurrenceID(seriesID string, day time.Time) string {
+	return seriesID + "-" + day.Format("20060102")
+}
+
+// publishAllSeries publishes the occurrences of every recurring trip series that have come within the horizon
+func publishAllSeries() {
+	for seriesID, tripSeries := range store.AllSeries() {
+		materialiseSeries(seriesID, tripSeries)
+	}
+}
+
+// runSeriesPublisher keeps the recurring trip series topped up to the publishing horizon until ctx is done
+func runSeriesPublisher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			publishAllSeries()
+		}
+	}
+}
+
+// materialiseSeries publishes the occurrences of the series that fall within the horizon and are not yet
+// published, or were cancelled on a day the series runs on again
+func materialiseSeries(seriesID string, tripSeries model.TripSeries) {
+	// Only car owners publish trips
+	if carOwner, err := userClient.GetUser(tripSeries.CarOwnerID); err != nil || !carOwner.IsCarOwner {
+		return
+	}
+
+	now := clk.Now().In(seriesLocation(tripSeries))
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	for ; day.Before(now.Add(seriesHorizon)); day = day.AddDate(0, 0, 1) {
+		startTime, ok := occurrenceStart(tripSeries, day)
+		if !ok || !policy.CanPublish(startTime, now) {
+			continue
+		}
+
+		tripID := occurrenceID(seriesID, day)
+		err := store.PublishOccurrence(tripID, model.Trip{
+			ID:                tripID,
+			CarOwnerID:        tripSeries.CarOwnerID,
+			PickupLocation:    tripSeries.PickupLocation,
+			AltPickupLocation: tripSeries.AltPickupLocation,
+			StartTime:         startTime,
+			Destination:       tripSeries.Destination,
+			AvailableSeats:    tripSeries.TotalSeats,
+			TotalSeats:        tripSeries.TotalSeats,
+			SeriesID:          seriesID,
+		})
+		if err != nil {
+			slog.Error("publishing recurring trip", "trip_id", tripID, "series_id", seriesID, "error", err)
+			return
+		}
+	}
+}
+
+// upcomingOccurrences returns the IDs of the published occurrences of a series that have not started or departed yet
+func upcomingOccurrences(seriesID string) []string {
+	var tripIDs []string
+	for tripID, trip := range store.All() {
+		if trip.SeriesID == seriesID && !trip.Started && !trip.Cancelled && trip.StartTime.After(clk.Now()) {
+			tripIDs = append(tripIDs, tripID)
+		}
+	}
+	sort.Strings(tripIDs)
+	return tripIDs
+}
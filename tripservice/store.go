@@ -0,0 +1,441 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/eventlog"
+	"github.com/Zachisastudent/ETI_Assignment-1/metrics"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// Events recorded in the Trip service's event log
+const (
+	TripPublished     = "TripPublished"
+	TripUpdated       = "TripUpdated"
+	PassengerEnrolled = "PassengerEnrolled"
+	PassengerWithdrew = "PassengerWithdrew"
+	TripStarted       = "TripStarted"
+	TripCancelled     = "TripCancelled"
+	TripDeleted       = "TripDeleted"
+	SeriesPublished   = "SeriesPublished"
+	SeriesUpdated     = "SeriesUpdated"
+	SeriesDateSkipped = "SeriesDateSkipped"
+	SeriesDeleted     = "SeriesDeleted"
+)
+
+// Returned by the store for a change to a trip or recurring trip series that does not exist
+var (
+	errTripNotFound   = errors.New("trip not found")
+	errSeriesNotFound = errors.New("series not found")
+)
+
+// tripStore is the view of the trips and recurring trip series projected from the event log.
+// Every change is appended to the log first and then applied to the view.
+type tripStore struct {
+	mu     sync.RWMutex
+	events *eventlog.Log
+	trips  map[string]model.Trip
+	series map[string]model.TripSeries
+}
+
+// newTripStore rebuilds the current view from every event in the log
+func newTripStore(events *eventlog.Log) (*tripStore, error) {
+	s, err := projectTrips(events.Events())
+	if err != nil {
+		return nil, err
+	}
+	s.events = events
+	return s, nil
+}
+
+// projectTrips replays the events into a new view, the view cannot record changes
+func projectTrips(events []eventlog.Event) (*tripStore, error) {
+	s := &tripStore{
+		trips:  map[string]model.Trip{},
+		series: map[string]model.TripSeries{},
+	}
+	for _, event := range events {
+		if err := s.apply(event); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// apply updates the view with a single event
+func (s *tripStore) apply(event eventlog.Event) error {
+	tripID := event.EntityID
+	trip := s.trips[tripID]
+
+	switch event.Type {
+	case TripPublished, TripUpdated:
+		var updatedTrip model.Trip
+		if err := event.Decode(&updatedTrip); err != nil {
+			return err
+		}
+		s.trips[tripID] = updatedTrip
+	case PassengerEnrolled:
+		var enrollment struct {
+			UserID string `json:"user_id"`
+		}
+		if err := event.Decode(&enrollment); err != nil {
+			return err
+		}
+		// Logs written before enrollments were checked under the store's lock can enroll a passenger twice
+		if !trip.IsEnrolled(enrollment.UserID) {
+			trip.EnrolledPassengers = append(trip.EnrolledPassengers, enrollment.UserID)
+		}
+		trip.UpdateSeats()
+		s.trips[tripID] = trip
+	case PassengerWithdrew:
+		var withdrawal struct {
+			UserID string `json:"user_id"`
+		}
+		if err := event.Decode(&withdrawal); err != nil {
+			return err
+		}
+		var passengers []string
+		for _, passengerID := range trip.EnrolledPassengers {
+			if passengerID != withdrawal.UserID {
+				passengers = append(passengers, passengerID)
+			}
+		}
+		trip.EnrolledPassengers = passengers
+		trip.UpdateSeats()
+		s.trips[tripID] = trip
+	case TripStarted:
+		trip.Started = true
+		s.trips[tripID] = trip
+	case TripCancelled:
+		trip.Cancelled = true
+		s.trips[tripID] = trip
+	case TripDeleted:
+		delete(s.trips, tripID)
+	case SeriesPublished, SeriesUpdated:
+		var tripSeries model.TripSeries
+		if err := event.Decode(&tripSeries); err != nil {
+			return err
+		}
+		s.series[event.EntityID] = tripSeries
+	case SeriesDateSkipped:
+		var skip struct {
+			Date string `json:"date"`
+		}
+		if err := event.Decode(&skip); err != nil {
+			return err
+		}
+		tripSeries := s.series[event.EntityID]
+		tripSeries.SkippedDates = append(tripSeries.SkippedDates, skip.Date)
+		s.series[event.EntityID] = tripSeries
+	case SeriesDeleted:
+		delete(s.series, event.EntityID)
+	default:
+		return fmt.Errorf("unknown event type %q in event %d", event.Type, event.Seq)
+	}
+	return nil
+}
+
+// record appends an event to the log and applies it to the view
+func (s *tripStore) record(eventType, entityID string, data interface{}) error {
+	return s.recordAll([]eventlog.Change{{Type: eventType, EntityID: entityID, Data: data}})
+}
+
+// recordAll appends the events to the log together and applies them to the view
+func (s *tripStore) recordAll(changes []eventlog.Change) error {
+	if s.events == nil {
+		return fmt.Errorf("the view was replayed from the event log and is read only")
+	}
+
+	events, err := s.events.AppendAll(changes)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := s.apply(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the trip with the given ID
+func (s *tripStore) Get(tripID string) (model.Trip, bool) {
+	defer metrics.StoreTimer("get")()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trip, ok := s.trips[tripID]
+	return trip, ok
+}
+
+// All returns a copy of every trip keyed by ID
+func (s *tripStore) All() map[string]model.Trip {
+	defer metrics.StoreTimer("all")()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[string]model.Trip, len(s.trips))
+	for tripID, trip := range s.trips {
+		all[tripID] = trip
+	}
+	return all
+}
+
+// Put publishes a new trip or updates an existing one
+func (s *tripStore) Put(tripID string, trip model.Trip) error {
+	defer metrics.StoreTimer("put")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.trips[tripID]; ok {
+		return s.record(TripUpdated, tripID, trip)
+	}
+	if err := s.record(TripPublished, tripID, trip); err != nil {
+		return err
+	}
+	tripsPublished.Inc()
+	return nil
+}
+
+// PutAll publishes or updates the trips as a single change. check is called with the trips as
+// they are under the store's lock, and nothing is stored if it returns an error.
+func (s *tripStore) PutAll(trips []model.Trip, check func(stored func(tripID string) (model.Trip, bool)) error) error {
+	defer metrics.StoreTimer("put_all")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := check(func(tripID string) (model.Trip, bool) {
+		trip, ok := s.trips[tripID]
+		return trip, ok
+	}); err != nil {
+		return err
+	}
+
+	changes := make([]eventlog.Change, len(trips))
+	published := 0
+	for i, trip := range trips {
+		changes[i] = eventlog.Change{Type: TripPublished, EntityID: trip.ID, Data: trip}
+		if _, ok := s.trips[trip.ID]; ok {
+			changes[i].Type = TripUpdated
+		} else {
+			published++
+		}
+	}
+	if err := s.recordAll(changes); err != nil {
+		return err
+	}
+	tripsPublished.Add(float64(published))
+	return nil
+}
+
+// Update publishes or updates the trip returned by change. change is given the trip stored under
+// tripID as it is under the store's lock, and whether there is one, and nothing is stored if it
+// returns an error.
+func (s *tripStore) Update(tripID string, change func(stored model.Trip, exists bool) (model.Trip, error)) error {
+	defer metrics.StoreTimer("update")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.trips[tripID]
+	trip, err := change(stored, exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return s.record(TripUpdated, tripID, trip)
+	}
+	if err := s.record(TripPublished, tripID, trip); err != nil {
+		return err
+	}
+	tripsPublished.Inc()
+	return nil
+}
+
+// PublishOccurrence publishes an occurrence of a recurring trip series unless it is already
+// published. An occurrence that was cancelled, because its day was dropped from the series, is
+// published again in its place.
+func (s *tripStore) PublishOccurrence(tripID string, trip model.Trip) error {
+	defer metrics.StoreTimer("publish_occurrence")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existingTrip, ok := s.trips[tripID]
+	if ok && !existingTrip.Cancelled {
+		return nil
+	}
+	if ok {
+		return s.record(TripUpdated, tripID, trip)
+	}
+	if err := s.record(TripPublished, tripID, trip); err != nil {
+		return err
+	}
+	tripsPublished.Inc()
+	return nil
+}
+
+// Enroll adds a passenger to a trip if check, given the trip as it is under the store's lock,
+// returns nil. Otherwise the error from check is returned, or errTripNotFound for a missing trip.
+// Two passengers enrolling at once can therefore not both take the last seat.
+func (s *tripStore) Enroll(tripID, userID string, check func(trip model.Trip) error) error {
+	defer metrics.StoreTimer("enroll")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTrip(tripID, check); err != nil {
+		return err
+	}
+	return s.record(PassengerEnrolled, tripID, map[string]string{"user_id": userID})
+}
+
+// Withdraw removes a passenger from a trip if check, given the trip as it is under the store's
+// lock, returns nil. Otherwise the error from check is returned, or errTripNotFound for a missing trip.
+func (s *tripStore) Withdraw(tripID, userID string, check func(trip model.Trip) error) error {
+	defer metrics.StoreTimer("withdraw")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTrip(tripID, check); err != nil {
+		return err
+	}
+	return s.record(PassengerWithdrew, tripID, map[string]string{"user_id": userID})
+}
+
+// Start marks a trip as started if check, given the trip as it is under the store's lock, returns
+// nil. Otherwise the error from check is returned, or errTripNotFound for a missing trip.
+func (s *tripStore) Start(tripID string, check func(trip model.Trip) error) error {
+	defer metrics.StoreTimer("start")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTrip(tripID, check); err != nil {
+		return err
+	}
+	return s.record(TripStarted, tripID, nil)
+}
+
+// Cancel marks a trip as cancelled, the reason is kept in the event log. It returns
+// errTripNotFound for a missing trip.
+func (s *tripStore) Cancel(tripID, reason string) error {
+	defer metrics.StoreTimer("cancel")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTrip(tripID, nil); err != nil {
+		return err
+	}
+	return s.record(TripCancelled, tripID, map[string]string{"reason": reason})
+}
+
+// Delete removes a trip if check, given the trip as it is under the store's lock, returns nil.
+// Otherwise the error from check is returned, or errTripNotFound for a missing trip. Deleting an
+// occurrence of a recurring trip series also skips its date, so the series does not publish it again.
+func (s *tripStore) Delete(tripID string, check func(trip model.Trip) error) error {
+	defer metrics.StoreTimer("delete")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTrip(tripID, check); err != nil {
+		return err
+	}
+
+	var changes []eventlog.Change
+	trip := s.trips[tripID]
+	if tripSeries, ok := s.series[trip.SeriesID]; ok {
+		date := trip.StartTime.In(seriesLocation(tripSeries)).Format("2006-01-02")
+		changes = append(changes, eventlog.Change{Type: SeriesDateSkipped, EntityID: trip.SeriesID, Data: map[string]string{"date": date}})
+	}
+	changes = append(changes, eventlog.Change{Type: TripDeleted, EntityID: tripID})
+	return s.recordAll(changes)
+}
+
+// checkTrip returns errTripNotFound for a missing trip, or the error from check, if there is one,
+// given the trip. The store's lock must be held.
+func (s *tripStore) checkTrip(tripID string, check func(trip model.Trip) error) error {
+	trip, ok := s.trips[tripID]
+	if !ok {
+		return errTripNotFound
+	}
+	if check == nil {
+		return nil
+	}
+	return check(trip)
+}
+
+// GetSeries returns the recurring trip series with the given ID
+func (s *tripStore) GetSeries(seriesID string) (model.TripSeries, bool) {
+	defer metrics.StoreTimer("get_series")()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tripSeries, ok := s.series[seriesID]
+	return tripSeries, ok
+}
+
+// AllSeries returns a copy of every recurring trip series keyed by ID
+func (s *tripStore) AllSeries() map[string]model.TripSeries {
+	defer metrics.StoreTimer("all_series")()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[string]model.TripSeries, len(s.series))
+	for seriesID, tripSeries := range s.series {
+		all[seriesID] = tripSeries
+	}
+	return all
+}
+
+// PutSeries publishes a new recurring trip series or updates an existing one
+func (s *tripStore) PutSeries(seriesID string, tripSeries model.TripSeries) error {
+	defer metrics.StoreTimer("put_series")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[seriesID]; ok {
+		return s.record(SeriesUpdated, seriesID, tripSeries)
+	}
+	return s.record(SeriesPublished, seriesID, tripSeries)
+}
+
+// SkipSeriesDate keeps a recurring trip series from publishing an occurrence on the date, given as
+// 2006-01-02. It returns errSeriesNotFound for a missing series.
+func (s *tripStore) SkipSeriesDate(seriesID, date string) error {
+	defer metrics.StoreTimer("skip_series_date")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[seriesID]; !ok {
+		return errSeriesNotFound
+	}
+	return s.record(SeriesDateSkipped, seriesID, map[string]string{"date": date})
+}
+
+// DeleteSeries removes a recurring trip series, its published trips are kept. It returns
+// errSeriesNotFound for a missing series.
+func (s *tripStore) DeleteSeries(seriesID string) error {
+	defer metrics.StoreTimer("delete_series")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[seriesID]; !ok {
+		return errSeriesNotFound
+	}
+	return s.record(SeriesDeleted, seriesID, nil)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/Zachisastudent/ETI_Assignment-1/resilient"
+)
+
+// ErrUserNotFound is returned by the UserClient when the User service has no such user
+var ErrUserNotFound = errors.New("user not found")
+
+// UserClient calls the User service's API on behalf of the Trip service
+type UserClient struct {
+	BaseURL    string
+	HTTPClient *resilient.Client
+}
+
+func newUserClient(baseURL string, transport http.RoundTripper) *UserClient {
+	// The calls are made on behalf of this service, which is recorded as the actor in the audit log
+	config := resilient.DefaultConfig()
+	config.Header = http.Header{"X-Client-ID": {"trip-service"}}
+	config.Transport = transport
+
+	return &UserClient{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: resilient.NewClient("user-service", config),
+	}
+}
+
+// Healthy checks that the User service is up
+func (c *UserClient) Healthy(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/healthz", nil)
+	if err != nil {
+		return err
+	}
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(response)
+	}
+	return nil
+}
+
+// GetUser retrieves a user's profile
+func (c *UserClient) GetUser(userID string) (model.User, error) {
+	response, err := c.HTTPClient.Get(c.BaseURL + "/api/v1/users/" + url.PathEscape(userID))
+	if err != nil {
+		return model.User{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return model.User{}, ErrUserNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return model.User{}, responseError(response)
+	}
+
+	var user model.User
+	if err := json.NewDecoder(response.Body).Decode(&user); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// Notify leaves a notification for a user
+func (c *UserClient) Notify(userID string, notification model.Notification) error {
+	jsonBody, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.HTTPClient.Post(c.BaseURL+"/api/v1/passengers/"+url.PathEscape(userID)+"/notifications", "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return ErrUserNotFound
+	}
+	if response.StatusCode != http.StatusAccepted {
+		return responseError(response)
+	}
+	return nil
+}
+
+// responseError turns an unexpected response from the User service into an error
+func responseError(response *http.Response) error {
+	body, _ := ioutil.ReadAll(response.Body)
+	return fmt.Errorf("user service returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+}
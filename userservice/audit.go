@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/gorilla/mux"
+)
+
+// auditTarget returns the entity changed by a mutating request to the User service
+func auditTarget(r *http.Request) (audit.Target, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return audit.Target{}, false
+	}
+	template, _ := route.GetPathTemplate()
+	userID := mux.Vars(r)["id"]
+
+	switch {
+	case template == "/api/v1/admin/users/import":
+		// An import can change any user, so all of them are recorded
+		return audit.Target{Entity: "import", EntityID: "users", Snapshot: func() interface{} {
+			return store.All()
+		}}, true
+	case strings.HasSuffix(template, "/notifications"):
+		return audit.Target{Entity: "notifications", EntityID: userID, Snapshot: func() interface{} {
+			return store.Notifications(userID)
+		}}, true
+	case strings.HasPrefix(template, "/api/v1/users/{id}"):
+		return audit.Target{Entity: "user", EntityID: userID, Snapshot: func() interface{} {
+			if user, ok := store.Get(userID); ok {
+				return user
+			}
+			return nil
+		}}, true
+	}
+	return audit.Target{}, false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/bulk"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// bulkUsers exports and imports every user for the admin endpoints, the store and the Trip
+// service client must be set up first
+func bulkUsers() bulk.Records[model.User] {
+	return bulk.Records[model.User]{
+		Name:    "users",
+		Entity:  "user",
+		Columns: model.UserColumns,
+		All: func() []model.User {
+			all := store.All()
+			users := make([]model.User, 0, len(all))
+			for _, user := range all {
+				users = append(users, user)
+			}
+			sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+			return users
+		},
+		Get:     store.Get,
+		ID:      func(user model.User) string { return user.ID },
+		CSV:     model.UsersCSV,
+		FromCSV: model.CSVRow.User,
+		Check:   checkImportedUser,
+		PutAll:  store.PutAll,
+	}
+}
+
+// checkImportedUser checks a user before it is imported. A user without a creation time is created
+// now or keeps the one of the user it overwrites, and a car owner who is imported as a passenger
+// must not have scheduled trips, as when the profile is updated.
+func checkImportedUser(user, existing *model.User) error {
+	if err := user.CheckImport(); err != nil {
+		return err
+	}
+
+	if existing == nil {
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = clk.Now()
+		}
+		return nil
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = existing.CreatedAt
+	}
+	if existing.IsCarOwner && !user.IsCarOwner {
+		scheduled, err := tripClient.ScheduledTripsOwnedBy(user.ID)
+		if err != nil {
+			return bulk.Unavailable(fmt.Errorf("could not check the user's trips: %w", err))
+		}
+		if len(scheduled) > 0 {
+			return fmt.Errorf("user still has scheduled trips: %s", strings.Join(scheduled, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/eventlog"
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi/openapitest"
+	"github.com/gorilla/mux"
+)
+
+// testNow is the time the fake clock of the tests starts at
+var testNow = time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+
+// scheduledTrips are the trips the stub Trip service reports for each owner
+var scheduledTrips map[string][]string
+
+// newTestRouter sets up the service with an empty store kept in memory, a clock stopped at testNow,
+// accounts kept for a year and a stub Trip service that reports the owners' scheduledTrips
+func newTestRouter(t *testing.T) (*mux.Router, *clock.Fake) {
+	t.Helper()
+
+	fake := clock.NewFake(testNow)
+	clk = fake
+	accountRetention = 365 * 24 * time.Hour
+	scheduledTrips = map[string][]string{}
+
+	tripService := mux.NewRouter()
+	tripService.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	tripService.HandleFunc("/api/v1/owners/{id}/scheduled-trips", func(w http.ResponseWriter, r *http.Request) {
+		tripIDs := scheduledTrips[mux.Vars(r)["id"]]
+		if tripIDs == nil {
+			tripIDs = []string{}
+		}
+		json.NewEncoder(w).Encode(tripIDs)
+	})
+	tripService.HandleFunc("/api/v1/owners/{id}/cancel-trips", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]string{"T1"})
+	})
+	stub := httptest.NewServer(tripService)
+	t.Cleanup(stub.Close)
+	tripClient = newTripClient(stub.URL, http.DefaultTransport)
+
+	events, err := eventlog.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.Clock = fake
+	if store, err = newUserStore(events); err != nil {
+		t.Fatal(err)
+	}
+	auditLog, err := audit.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auditLog.Close(); events.Close() })
+
+	return newRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), events, auditLog), fake
+}
+
+func request(method, path, body string) *http.Request {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+func TestContract(t *testing.T) {
+	r, _ := newTestRouter(t)
+	accountRetention = 0 // TestHandlers covers the retention period
+	v := openapitest.New(t)
+	v.CheckRoutes(r)
+
+	const passenger = `{"first_name":"Jane","last_name":"Tan","mobile_number":"91234567","email":"jane@example.com","is_car_owner":false}`
+	const imported = `{"id":"U1","first_name":"Jane","last_name":"Tan","mobile_number":"91234567","email":"jane@example.com","is_car_owner":false,"created_at":"2024-01-15T08:00:00Z"}`
+	const owner = `{"first_name":"John","last_name":"Lim","mobile_number":"98765432","email":"john@example.com","is_car_owner":true,"driver_license":"S1234567A","car_plate_number":"SBA1234A"}`
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"create a passenger", "POST", "/api/v1/users/U1", passenger, http.StatusAccepted},
+		{"create a car owner", "POST", "/api/v1/users/U2", owner, http.StatusAccepted},
+		{"create with an invalid license", "POST", "/api/v1/users/U3", strings.Replace(owner, "S1234567A", "S1", 1), http.StatusBadRequest},
+		{"update a user", "PUT", "/api/v1/users/U1", passenger, http.StatusAccepted},
+		{"get a user", "GET", "/api/v1/users/U1", "", http.StatusOK},
+		{"get a missing user", "GET", "/api/v1/users/U9", "", http.StatusNotFound},
+		{"list users", "GET", "/api/v1/users", "", http.StatusOK},
+		{"become a car owner", "POST", "/api/v1/users/U1/become-owner", `{"driver_license":"T7654321B","car_plate_number":"SGX88"}`, http.StatusAccepted},
+		{"become a car owner when missing", "POST", "/api/v1/users/U9/become-owner", `{"driver_license":"T7654321B","car_plate_number":"SGX88"}`, http.StatusNotFound},
+		{"become a passenger", "POST", "/api/v1/users/U1/become-passenger", "", http.StatusAccepted},
+		{"become a passenger when not an owner", "POST", "/api/v1/users/U1/become-passenger", "", http.StatusBadRequest},
+		{"leave a notification", "POST", "/api/v1/passengers/U1/notifications", `{"trip_id":"T1","message":"Trip T1 was cancelled"}`, http.StatusAccepted},
+		{"leave a notification for a missing user", "POST", "/api/v1/passengers/U9/notifications", `{"trip_id":"T1","message":"Trip T1 was cancelled"}`, http.StatusNotFound},
+		{"list notifications", "GET", "/api/v1/users/U1/notifications", "", http.StatusOK},
+		{"list notifications of a missing user", "GET", "/api/v1/users/U9/notifications", "", http.StatusNotFound},
+		{"export the users", "GET", "/api/v1/admin/users/export", "", http.StatusOK},
+		{"import the users as a dry run", "POST", "/api/v1/admin/users/import?dry_run=true&on_conflict=overwrite", "[" + imported + "]", http.StatusOK},
+		{"import the users", "POST", "/api/v1/admin/users/import?on_conflict=skip", "[" + imported + "]", http.StatusAccepted},
+		{"import a taken ID", "POST", "/api/v1/admin/users/import", "[" + imported + "]", http.StatusConflict},
+		{"delete a car owner", "DELETE", "/api/v1/users/U2", "", http.StatusOK},
+		{"delete a missing user", "DELETE", "/api/v1/users/U2", "", http.StatusNotFound},
+		{"query the audit log", "GET", "/api/v1/audit?entity=user&id=U1", "", http.StatusOK},
+		{"export the audit log", "GET", "/api/v1/audit?format=jsonl", "", http.StatusOK},
+		{"liveness", "GET", "/healthz", "", http.StatusOK},
+		{"readiness", "GET", "/readyz", "", http.StatusOK},
+		{"metrics", "GET", "/metrics", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		response := v.Do(r, request(test.method, test.path, test.body))
+		if response.StatusCode != test.status {
+			body, _ := io.ReadAll(response.Body)
+			t.Errorf("%s: %s %s returned %d, want %d: %s", test.name, test.method, test.path, response.StatusCode, test.status, body)
+		}
+	}
+
+	v.CheckCoverage("users", "notifications", "audit", "health")
+}
+
+func TestDeletingAnOwnerCancelsTheirTrips(t *testing.T) {
+	r, fake := newTestRouter(t)
+	v := openapitest.New(t)
+
+	v.Do(r, request("POST", "/api/v1/users/U2", `{"first_name":"John","last_name":"Lim","mobile_number":"98765432","email":"john@example.com","is_car_owner":true,"driver_license":"S1234567A","car_plate_number":"SBA1234A"}`))
+	fake.Advance(accountRetention)
+	response := v.Do(r, request("DELETE", "/api/v1/users/U2", ""))
+	body, _ := io.ReadAll(response.Body)
+	if want := "User U2 deleted; cancelled trips: T1"; string(body) != want {
+		t.Errorf("got %q, want %q", body, want)
+	}
+	if _, ok := store.Get("U2"); ok {
+		t.Error("the user was not deleted")
+	}
+}
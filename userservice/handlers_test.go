@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/Zachisastudent/ETI_Assignment-1/openapi/openapitest"
+	"github.com/Zachisastudent/ETI_Assignment-1/resilient"
+)
+
+const (
+	passengerJSON = `{"first_name":"Jane","last_name":"Tan","mobile_number":"91234567","email":"jane@example.com","is_car_owner":false}`
+	ownerJSON     = `{"first_name":"John","last_name":"Lim","mobile_number":"98765432","email":"john@example.com","is_car_owner":true,"driver_license":"S1234567A","car_plate_number":"SBA1234A"}`
+	year          = 365 * 24 * time.Hour
+)
+
+// tripServiceDown points the Trip client at a service that is not running. The client neither
+// retries nor opens its circuit breaker, so the other tests are not held up.
+func tripServiceDown() {
+	config := resilient.DefaultConfig()
+	config.MaxRetries = 0
+	config.FailureThreshold = 0
+	tripClient = &TripClient{BaseURL: "http://127.0.0.1:1", HTTPClient: resilient.NewClient("trip-service-down", config)}
+}
+
+// TestHandlers sends each request to a service that has passenger U1 and car owner O1, both created
+// at testNow, after running the test's setup. The response must match the status and start with want.
+func TestHandlers(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(fake *clock.Fake)
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{"get a user", nil, "GET", "/api/v1/users/U1", "", http.StatusOK, `{"id":"U1"`},
+		{"get a missing user", nil, "GET", "/api/v1/users/U9", "", http.StatusNotFound, "Invalid user ID"},
+		{"list users", nil, "GET", "/api/v1/users", "", http.StatusOK, `{"O1":`},
+
+		{"create a user", nil, "POST", "/api/v1/users/U2", passengerJSON, http.StatusAccepted, "User POST U2 successfully"},
+		{"create a car owner without a license", nil, "POST", "/api/v1/users/U2", strings.Replace(ownerJSON, `"S1234567A"`, `""`, 1),
+			http.StatusBadRequest, "Error - Driver's license and car plate number are required for car owners"},
+		{"create a car owner with an invalid license", nil, "POST", "/api/v1/users/U2", strings.Replace(ownerJSON, "S1234567A", "S1", 1),
+			http.StatusBadRequest, `Error - Invalid driver's license number "S1"`},
+		{"create a car owner with an invalid plate", nil, "POST", "/api/v1/users/U2", strings.Replace(ownerJSON, "SBA1234A", "1234", 1),
+			http.StatusBadRequest, `Error - Invalid car plate number "1234"`},
+		{"update a user", nil, "PUT", "/api/v1/users/U1", passengerJSON, http.StatusAccepted, "User PUT U1 successfully"},
+		{"drop the car owner profile with scheduled trips", func(*clock.Fake) { scheduledTrips["O1"] = []string{"T1", "T2"} },
+			"PUT", "/api/v1/users/O1", passengerJSON, http.StatusConflict, "Error - User still has scheduled trips: T1, T2"},
+		{"drop the car owner profile while the Trip service is down", func(*clock.Fake) { tripServiceDown() },
+			"PUT", "/api/v1/users/O1", passengerJSON, http.StatusBadGateway, "Error - Could not check the user's trips"},
+
+		{"delete a user a day before a year", func(fake *clock.Fake) { fake.Advance(year - 24*time.Hour) },
+			"DELETE", "/api/v1/users/U1", "", http.StatusBadRequest, "Error - Account cannot be deleted before 1 year"},
+		{"delete a user after a year", func(fake *clock.Fake) { fake.Advance(year) },
+			"DELETE", "/api/v1/users/U1", "", http.StatusOK, "User U1 deleted"},
+		{"delete a car owner after a year", func(fake *clock.Fake) { fake.Advance(year) },
+			"DELETE", "/api/v1/users/O1", "", http.StatusOK, "User O1 deleted; cancelled trips: T1"},
+		{"delete a car owner while the Trip service is down", func(fake *clock.Fake) { fake.Advance(year); tripServiceDown() },
+			"DELETE", "/api/v1/users/O1", "", http.StatusBadGateway, "Error - Could not cancel the user's trips"},
+		{"delete a missing user", nil, "DELETE", "/api/v1/users/U9", "", http.StatusNotFound, "Invalid user ID"},
+
+		{"become a car owner", nil, "POST", "/api/v1/users/U1/become-owner", `{"driver_license":"t7654321b","car_plate_number":"sgx 88"}`,
+			http.StatusAccepted, "User U1 is now a car owner"},
+		{"become a car owner when missing", nil, "POST", "/api/v1/users/U9/become-owner", `{"driver_license":"T7654321B","car_plate_number":"SGX88"}`,
+			http.StatusNotFound, "Invalid user ID"},
+
+		{"become a passenger", nil, "POST", "/api/v1/users/O1/become-passenger", "", http.StatusAccepted, "User O1 is now a passenger"},
+		{"become a passenger when not a car owner", nil, "POST", "/api/v1/users/U1/become-passenger", "", http.StatusBadRequest, "Error - User is not a car owner"},
+		{"become a passenger with scheduled trips", func(*clock.Fake) { scheduledTrips["O1"] = []string{"T1"} },
+			"POST", "/api/v1/users/O1/become-passenger", "", http.StatusConflict, "Error - User still has scheduled trips: T1"},
+		{"become a passenger while the Trip service is down", func(*clock.Fake) { tripServiceDown() },
+			"POST", "/api/v1/users/O1/become-passenger", "", http.StatusBadGateway, "Error - Could not check the user's trips"},
+		{"become a passenger when missing", nil, "POST", "/api/v1/users/U9/become-passenger", "", http.StatusNotFound, "Invalid user ID"},
+
+		{"list notifications", nil, "GET", "/api/v1/users/U1/notifications", "", http.StatusOK, "[]"},
+		{"list notifications of a missing user", nil, "GET", "/api/v1/users/U9/notifications", "", http.StatusNotFound, "Invalid user ID"},
+		{"leave a notification", nil, "POST", "/api/v1/passengers/U1/notifications", `{"trip_id":"T1","message":"Trip T1 was cancelled"}`,
+			http.StatusAccepted, "Notification added for user U1"},
+		{"leave a notification for a missing user", nil, "POST", "/api/v1/passengers/U9/notifications", `{"trip_id":"T1","message":"Trip T1 was cancelled"}`,
+			http.StatusNotFound, "Invalid user ID"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, fake := newTestRouter(t)
+			v := openapitest.New(t)
+			v.Do(r, request("POST", "/api/v1/users/U1", passengerJSON))
+			v.Do(r, request("POST", "/api/v1/users/O1", ownerJSON))
+			if test.setup != nil {
+				test.setup(fake)
+			}
+
+			response := v.Do(r, request(test.method, test.path, test.body))
+			body, _ := io.ReadAll(response.Body)
+			if response.StatusCode != test.status || !strings.HasPrefix(string(body), test.want) {
+				t.Errorf("%s %s returned %d %q, want %d %q", test.method, test.path, response.StatusCode, body, test.status, test.want)
+			}
+		})
+	}
+}
+
+// TestInvalidRequests sends requests the OpenAPI document does not allow to the same service as
+// TestHandlers, they must be rejected with a 400 starting with want
+func TestInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{"create a user from invalid JSON", "POST", "/api/v1/users/U2", `{"first_name":`, "Invalid request payload"},
+		{"become a car owner from invalid JSON", "POST", "/api/v1/users/U1/become-owner", `[]`, "Invalid request payload"},
+		{"become a car owner without a plate", "POST", "/api/v1/users/U1/become-owner", `{"driver_license":"T7654321B"}`,
+			"Error - Driver's license and car plate number are required for car owners"},
+		{"leave a notification from invalid JSON", "POST", "/api/v1/passengers/U1/notifications", `"T1"`, "Invalid request payload"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := newTestRouter(t)
+			v := openapitest.New(t)
+			v.Do(r, request("POST", "/api/v1/users/U1", passengerJSON))
+
+			response := v.DoInvalid(r, request(test.method, test.path, test.body))
+			body, _ := io.ReadAll(response.Body)
+			if response.StatusCode != http.StatusBadRequest || !strings.HasPrefix(string(body), test.want) {
+				t.Errorf("%s %s returned %d %q, want 400 %q", test.method, test.path, response.StatusCode, body, test.want)
+			}
+		})
+	}
+}
+
+func TestUsersAreStampedWithTheClock(t *testing.T) {
+	r, fake := newTestRouter(t)
+	v := openapitest.New(t)
+
+	v.Do(r, request("POST", "/api/v1/users/U1", passengerJSON))
+	fake.Advance(time.Hour)
+	v.Do(r, request("PUT", "/api/v1/users/U1", passengerJSON))
+	v.Do(r, request("POST", "/api/v1/passengers/U1/notifications", `{"trip_id":"T1","message":"Trip T1 was cancelled"}`))
+
+	// An update keeps the time the account was created, or the retention period would start over
+	if user, _ := store.Get("U1"); !user.CreatedAt.Equal(testNow) {
+		t.Errorf("the user was created at %v, want %v", user.CreatedAt, testNow)
+	}
+	if notifications := store.Notifications("U1"); len(notifications) != 1 || !notifications[0].CreatedAt.Equal(testNow.Add(time.Hour)) {
+		t.Errorf("unexpected notifications %+v", notifications)
+	}
+}
+
+func TestAUserCreatedByAPutIsStampedWithTheClock(t *testing.T) {
+	r, fake := newTestRouter(t)
+	v := openapitest.New(t)
+
+	// The creation time in the body is ignored, or the retention period could be skipped
+	body := strings.Replace(passengerJSON, "{", `{"created_at":"2000-01-01T00:00:00Z",`, 1)
+	v.Do(r, request("PUT", "/api/v1/users/U1", body))
+	fake.Advance(time.Hour)
+	v.Do(r, request("PUT", "/api/v1/users/U1", body))
+
+	if user, _ := store.Get("U1"); !user.CreatedAt.Equal(testNow) {
+		t.Errorf("the user was created at %v, want %v", user.CreatedAt, testNow)
+	}
+}
+
+func TestBecomingACarOwnerNormalisesTheDetails(t *testing.T) {
+	r, _ := newTestRouter(t)
+	v := openapitest.New(t)
+
+	v.Do(r, request("POST", "/api/v1/users/U1", passengerJSON))
+	v.Do(r, request("POST", "/api/v1/users/U1/become-owner", `{"driver_license":" t7654321b","car_plate_number":"sgx 88"}`))
+
+	user, _ := store.Get("U1")
+	if !user.IsCarOwner || user.DriverLicense != "T7654321B" || user.CarPlateNumber != "SGX88" {
+		t.Errorf("unexpected car owner profile %+v", user)
+	}
+}
+
+func TestChangesToAMissingUserAreRejected(t *testing.T) {
+	newTestRouter(t)
+
+	// A change to a user that does not exist would otherwise leave a half-built user in the view
+	for name, change := range map[string]func() error{
+		"become a car owner": func() error { return store.BecomeCarOwner("U9", "S1234567A", "SBA1234A") },
+		"become a passenger": func() error { return store.BecomePassenger("U9") },
+		"delete":             func() error { return store.Delete("U9") },
+		"add a notification": func() error { return store.AddNotification("U9", model.Notification{Message: "hello"}) },
+	} {
+		if err := change(); !errors.Is(err, errUserNotFound) {
+			t.Errorf("%s returned %v, want %v", name, err, errUserNotFound)
+		}
+	}
+	if _, ok := store.Get("U9"); ok {
+		t.Error("a change to the missing user U9 created them")
+	}
+}
+
+// TestImportingUsers imports users into the service of TestHandlers. The response must match the
+// status and contain want, and afterwards U2 must exist if stored is true. Requests the OpenAPI
+// document does not allow are marked invalid.
+func TestImportingUsers(t *testing.T) {
+	const (
+		imported = `{"id":"U2","first_name":"Ali","last_name":"Abu","mobile_number":"91230000","email":"ali@example.com","is_car_owner":false}`
+		header   = "id,first_name,last_name,mobile_number,email,is_car_owner,driver_license,car_plate_number\n"
+	)
+	passenger := strings.Replace(imported, `"U2"`, `"O1"`, 1)
+
+	tests := []struct {
+		name        string
+		setup       func()
+		query       string
+		contentType string
+		body        string
+		invalid     bool
+		status      int
+		want        string
+		stored      bool
+	}{
+		{"import a user", nil, "", "application/json", "[" + imported + "]", false,
+			http.StatusAccepted, `"created":1,"updated":0,"skipped":0,"failed":0,"rows":[{"row":1,"id":"U2","result":"created"}]`, true},
+		{"import users from CSV", nil, "", "text/csv", header + "U2,Ali,Abu,91230000,ali@example.com,false,,\n", false,
+			http.StatusAccepted, `"created":1`, true},
+		{"dry run", nil, "?dry_run=true", "application/json", "[" + imported + "]", false,
+			http.StatusOK, `{"dry_run":true,"on_conflict":"fail","created":1`, false},
+		{"import a taken ID", nil, "", "application/json", "[" + imported + "," + passenger + "]", false,
+			http.StatusConflict, `{"row":2,"id":"O1","result":"failed","error":"user O1 already exists"}`, false},
+		{"skip a taken ID", nil, "?on_conflict=skip", "application/json", "[" + imported + "," + passenger + "]", false,
+			http.StatusAccepted, `"created":1,"updated":0,"skipped":1`, true},
+		{"overwrite a taken ID", nil, "?on_conflict=overwrite", "application/json", "[" + imported + "," + passenger + "]", false,
+			http.StatusAccepted, `"created":1,"updated":1`, true},
+		{"overwrite a car owner with scheduled trips", func() { scheduledTrips["O1"] = []string{"T1"} }, "?on_conflict=overwrite",
+			"application/json", "[" + imported + "," + passenger + "]", false,
+			http.StatusBadRequest, `"error":"user still has scheduled trips: T1"`, false},
+		{"overwrite a car owner while the Trip service is down", tripServiceDown, "?on_conflict=overwrite",
+			"application/json", "[" + passenger + "]", false, http.StatusBadGateway, "Error - Could not check the user's trips", false},
+		{"import an invalid user", nil, "", "application/json", "[" + imported + `,{"id":"U3","first_name":"Bo"}]`, true,
+			http.StatusBadRequest, `{"row":2,"id":"U3","result":"failed","error":"first name, last name, mobile number and email are required"}`, false},
+		{"import an ID twice", nil, "", "application/json", "[" + imported + "," + imported + "]", false,
+			http.StatusBadRequest, `"error":"the id U2 appears more than once"`, false},
+		{"import a car owner without a license", nil, "", "text/csv", header + "U2,Ali,Abu,91230000,ali@example.com,true,,SGX88\n", false,
+			http.StatusBadRequest, `"error":"driver's license and car plate number are required for car owners"`, false},
+		{"import a row that cannot be read", nil, "", "text/csv", header + "U2,Ali,Abu,91230000,ali@example.com,maybe,,\n", false,
+			http.StatusBadRequest, `"error":"invalid is_car_owner \"maybe\", want true or false"`, false},
+		{"import CSV with an unknown column", nil, "", "text/csv", "id,name\nU2,Ali\n", false,
+			http.StatusBadRequest, `Invalid request payload: unknown CSV column "name"`, false},
+		{"import invalid JSON", nil, "", "application/json", `{"id":"U2"}`, true, http.StatusBadRequest, "Invalid request payload", false},
+		{"import with an invalid conflict strategy", nil, "?on_conflict=merge", "application/json", "[]", true,
+			http.StatusBadRequest, `Error - Invalid on_conflict "merge", want fail, skip or overwrite`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := newTestRouter(t)
+			v := openapitest.New(t)
+			v.Do(r, request("POST", "/api/v1/users/U1", passengerJSON))
+			v.Do(r, request("POST", "/api/v1/users/O1", ownerJSON))
+			if test.setup != nil {
+				test.setup()
+			}
+
+			req := request("POST", "/api/v1/admin/users/import"+test.query, test.body)
+			req.Header.Set("Content-Type", test.contentType)
+			var response *http.Response
+			if test.invalid {
+				response = v.DoInvalid(r, req)
+			} else {
+				response = v.Do(r, req)
+			}
+			body, _ := io.ReadAll(response.Body)
+			if response.StatusCode != test.status || !strings.Contains(string(body), test.want) {
+				t.Errorf("the import returned %d %s, want %d %s", response.StatusCode, body, test.status, test.want)
+			}
+			if _, stored := store.Get("U2"); stored != test.stored {
+				t.Errorf("U2 stored is %v, want %v", stored, test.stored)
+			}
+		})
+	}
+}
+
+func TestExportedUsersCanBeImported(t *testing.T) {
+	r, fake := newTestRouter(t)
+	v := openapitest.New(t)
+	v.Do(r, request("POST", "/api/v1/users/U1", passengerJSON))
+	v.Do(r, request("POST", "/api/v1/users/O1", ownerJSON))
+
+	for _, format := range []string{"json", "csv"} {
+		response := v.Do(r, request("GET", "/api/v1/admin/users/export?format="+format, ""))
+		export, _ := io.ReadAll(response.Body)
+		if disposition := response.Header.Get("Content-Disposition"); disposition != `attachment; filename="users.`+format+`"` {
+			t.Errorf("the %s export is downloaded as %q", format, disposition)
+		}
+
+		// A service started afresh gets the same users back, with the time they were created
+		r, _ = newTestRouter(t)
+		fake.Advance(time.Hour)
+		req := request("POST", "/api/v1/admin/users/import", string(export))
+		req.Header.Set("Content-Type", response.Header.Get("Content-Type"))
+		if response := v.Do(r, req); response.StatusCode != http.StatusAccepted {
+			body, _ := io.ReadAll(response.Body)
+			t.Fatalf("importing the %s export returned %d %s", format, response.StatusCode, body)
+		}
+		owner, _ := store.Get("O1")
+		if len(store.All()) != 2 || !owner.IsCarOwner || owner.DriverLicense != "S1234567A" || !owner.CreatedAt.Equal(testNow) {
+			t.Errorf("the %s export was imported as %+v", format, store.All())
+		}
+	}
+
+	if response := v.DoInvalid(r, request("GET", "/api/v1/admin/users/export?format=xml", "")); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("exporting as xml returned %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/audit"
+	"github.com/Zachisastudent/ETI_Assignment-1/certs"
+	"github.com/Zachisastudent/ETI_Assignment-1/clock"
+	"github.com/Zachisastudent/ETI_Assignment-1/config"
+	"github.com/Zachisastudent/ETI_Assignment-1/eventlog"
+	"github.com/Zachisastudent/ETI_Assignment-1/health"
+	"github.com/Zachisastudent/ETI_Assignment-1/logging"
+	"github.com/Zachisastudent/ETI_Assignment-1/metrics"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+	"github.com/Zachisastudent/ETI_Assignment-1/server"
+	"github.com/gorilla/mux"
+)
+
+var (
+	store      *userStore
+	tripClient *TripClient
+)
+
+// clk tells the handlers the time, the tests replace it with a clock.Fake
+var clk = clock.System
+
+// accountRetention is how long accounts are kept before they can be deleted, set with policy.account_retention
+var accountRetention time.Duration
+
+func main() {
+	replay := flag.Bool("replay", false, "print the users rebuilt from the event log and exit")
+	at := flag.String("at", "", "with -replay, the RFC 3339 time to rebuild the state at (default now)")
+	cfg, err := config.Load("user-service", flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger, err := logging.New("user-service", cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// HTTPS, with mutual TLS the service only accepts callers presenting a certificate from the CA
+	serverTLS, err := certs.ServerTLS(cfg.TLS, cfg.TLS.Mutual)
+	if err != nil {
+		logger.Error("setting up TLS", "error", err)
+		os.Exit(1)
+	}
+	clientTLS, err := certs.ClientTLS(cfg.TLS, true)
+	if err != nil {
+		logger.Error("setting up TLS", "error", err)
+		os.Exit(1)
+	}
+	tripClient = newTripClient(cfg.TripService.URL, certs.Transport(clientTLS))
+	accountRetention = cfg.Policy.AccountRetention
+
+	// The event log is the source of truth, the users and notifications are rebuilt from it
+	eventLogPath, _ := cfg.UserService.EventLogPath()
+	events, err := eventlog.Open(eventLogPath)
+	if err != nil {
+		logger.Error("opening the event log", "path", eventLogPath, "error", err)
+		os.Exit(1)
+	}
+
+	if *replay {
+		if err := printReplay(os.Stdout, events, *at); err != nil {
+			logger.Error("replaying the event log", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	store, err = newUserStore(events)
+	if err != nil {
+		logger.Error("replaying the event log", "error", err)
+		os.Exit(1)
+	}
+
+	auditLog, err := audit.Open(cfg.UserService.AuditLog)
+	if err != nil {
+		logger.Error("opening the audit log", "path", cfg.UserService.AuditLog, "error", err)
+		os.Exit(1)
+	}
+
+	r := newRouter(logger, events, auditLog)
+
+	serverConfig := cfg.HTTP.Server(cfg.UserService.ListenAddr)
+	serverConfig.TLS = serverTLS
+	logger.Info("starting user service", "addr", serverConfig.Addr, "tls", serverTLS != nil, "mutual_tls", cfg.TLS.Mutual)
+	runErr := server.Run(logger, serverConfig, r)
+
+	// No requests are running any more, so the logs can be flushed to disk
+	if err := errors.Join(auditLog.Close(), events.Close()); err != nil {
+		logger.Error("flushing the store", "error", err)
+		os.Exit(1)
+	}
+	if runErr != nil {
+		logger.Error("user service stopped", "error", runErr)
+		os.Exit(1)
+	}
+	logger.Info("user service stopped")
+}
+
+// newRouter returns the service's routes, the store and the Trip service client must be set up first
+func newRouter(logger *slog.Logger, events *eventlog.Log, auditLog *audit.Log) *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/api/v1/users/{id}", getUser).Methods("GET", "DELETE")
+	r.HandleFunc("/api/v1/users", getAllUsers).Methods("GET")
+	r.HandleFunc("/api/v1/users/{id}", createOrUpdateUser).Methods("POST", "PUT")
+	r.HandleFunc("/api/v1/users/{id}/become-owner", becomeCarOwner).Methods("POST")
+	r.HandleFunc("/api/v1/users/{id}/become-passenger", becomePassenger).Methods("POST")
+	r.HandleFunc("/api/v1/users/{id}/notifications", getNotifications).Methods("GET")
+
+	// Called by the Trip service to tell passengers about cancelled trips, the gateway does not forward it
+	r.HandleFunc("/api/v1/passengers/{id}/notifications", addNotification).Methods("POST")
+
+	// Every user can be exported and imported again, the gateway only lets admins do it
+	users := bulkUsers()
+	r.HandleFunc("/api/v1/admin/users/export", users.Export).Methods("GET")
+	r.HandleFunc("/api/v1/admin/users/import", users.Import).Methods("POST")
+
+	// Circuit breaker state of the calls to the Trip service, and the Prometheus metrics
+	r.Handle("/debug/vars", expvar.Handler())
+	r.Handle("/metrics", metrics.Handler())
+
+	// Liveness and readiness for deployments, the service is ready when it can write its
+	// event log and reach the Trip service it calls
+	checker := health.NewChecker(
+		health.Check{Name: "event_log", Check: func(context.Context) error { return events.Check() }},
+		health.Check{Name: "trip_service", Check: tripClient.Healthy},
+	)
+	r.HandleFunc("/healthz", checker.Live).Methods("GET")
+	r.HandleFunc("/readyz", checker.Ready).Methods("GET")
+
+	// Every request is logged, timed and, if it changes anything, recorded in the audit log.
+	// The gateway only lets admins query the audit log.
+	r.HandleFunc("/api/v1/audit", auditLog.Handler).Methods("GET")
+	r.Use(logging.Middleware(logger), metrics.Middleware, auditLog.Middleware(auditTarget))
+
+	return r
+}
+
+// printReplay writes the users and notifications as they were at the given time, or now if it is empty
+func printReplay(w io.Writer, events *eventlog.Log, at string) error {
+	until := clk.Now()
+	if at != "" {
+		var err error
+		if until, err = time.Parse(time.RFC3339, at); err != nil {
+			return err
+		}
+	}
+
+	state, err := projectUsers(events.Until(until))
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{
+		"at":            until.UTC(),
+		"users":         state.users,
+		"notifications": state.notifications,
+	})
+}
+
+// writeJSON writes v as the JSON body of the response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeStoreError writes the response for a change that could not be recorded in the event log
+func writeStoreError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "Error - Could not record the change: %v", err)
+}
+
+// getUser handles GET and DELETE requests for a specific user
+func getUser(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	logging.SetUserID(r, userID)
+
+	if user, ok := store.Get(userID); ok {
+		if r.Method == "GET" {
+			writeJSON(w, user)
+		} else if r.Method == "DELETE" {
+			// Accounts are kept for the retention period
+			if err := user.CheckDelete(accountRetention, clk.Now()); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, model.ErrorMessage(err))
+				return
+			}
+
+			var cancelled []string
+			if user.IsCarOwner {
+				var err error
+				cancelled, err = tripClient.CancelTripsOwnedBy(userID, "the car owner's account was deleted")
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					fmt.Fprintf(w, "Error - Could not cancel the user's trips: %v", err)
+					return
+				}
+			}
+			if err := store.Delete(userID); errors.Is(err, errUserNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, "Invalid user ID")
+				return
+			} else if err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			fmt.Fprintf(w, "User %s deleted", userID)
+			if len(cancelled) > 0 {
+				fmt.Fprintf(w, "; cancelled trips: %s", strings.Join(cancelled, ", "))
+			}
+		}
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid user ID")
+	}
+}
+
+// getAllUsers handles GET requests to retrieve all users
+func getAllUsers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, store.All())
+}
+
+// createOrUpdateUser handles POST and PUT requests to create or update a user
+func createOrUpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	logging.SetUserID(r, userID)
+	var user model.User
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&user); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+	user.ID = userID
+
+	// Set the creation time if the user is being created, by a POST or a PUT, and an update keeps it
+	existingUser, exists := store.Get(userID)
+	if r.Method == "POST" || !exists {
+		user.CreatedAt = clk.Now()
+	} else {
+		user.CreatedAt = existingUser.CreatedAt
+	}
+
+	// Car owners need a valid driver's license and car plate number
+	if err := user.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, model.ErrorMessage(err))
+		return
+	}
+
+	// A car owner cannot drop the car owner profile while passengers are waiting on their trips
+	if exists && existingUser.IsCarOwner && !user.IsCarOwner {
+		if !checkNoScheduledTrips(w, userID) {
+			return
+		}
+	}
+
+	if err := store.Put(userID, user); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "User %s %s successfully", r.Method, userID)
+}
+
+// becomeCarOwner handles POST requests to upgrade a passenger profile to a car owner profile
+func becomeCarOwner(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	logging.SetUserID(r, userID)
+
+	if _, ok := store.Get(userID); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid user ID")
+		return
+	}
+
+	var ownerData map[string]string
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&ownerData); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+
+	license, plate, err := model.NormaliseCarOwnerDetails(ownerData["driver_license"], ownerData["car_plate_number"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, model.ErrorMessage(err))
+		return
+	}
+
+	// Only the car owner details change, the rest of the profile is kept
+	if err := store.BecomeCarOwner(userID, license, plate); errors.Is(err, errUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid user ID")
+		return
+	} else if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "User %s is now a car owner", userID)
+}
+
+// becomePassenger handles POST requests to downgrade a car owner profile back to a passenger profile
+func becomePassenger(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	logging.SetUserID(r, userID)
+
+	user, ok := store.Get(userID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid user ID")
+		return
+	}
+
+	if !user.IsCarOwner {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Error - User is not a car owner")
+		return
+	}
+
+	// Passengers enrolled in upcoming trips still need a driver
+	if !checkNoScheduledTrips(w, userID) {
+		return
+	}
+
+	if err := store.BecomePassenger(userID); errors.Is(err, errUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid user ID")
+		return
+	} else if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "User %s is now a passenger", userID)
+}
+
+// checkNoScheduledTrips asks the Trip service whether the owner still has scheduled trips.
+// If they do, or the Trip service cannot be reached, the error response is written and false is returned.
+func checkNoScheduledTrips(w http.ResponseWriter, userID string) bool {
+	scheduled, err := tripClient.ScheduledTripsOwnedBy(userID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "Error - Could not check the user's trips: %v", err)
+		return false
+	}
+	if len(scheduled) > 0 {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, "Error - User still has scheduled trips: %s", strings.Join(scheduled, ", "))
+		return false
+	}
+	return true
+}
+
+// getNotifications handles GET requests to retrieve the notifications left for a user
+func getNotifications(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	logging.SetUserID(r, userID)
+
+	if _, ok := store.Get(userID); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid user ID")
+		return
+	}
+
+	writeJSON(w, store.Notifications(userID))
+}
+
+// addNotification handles POST requests from the Trip service to leave a notification for a user
+func addNotification(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	logging.SetUserID(r, userID)
+
+	if _, ok := store.Get(userID); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid user ID")
+		return
+	}
+
+	var notification model.Notification
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&notification); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Invalid request payload")
+		return
+	}
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = clk.Now()
+	}
+
+	if err := store.AddNotification(userID, notification); errors.Is(err, errUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Invalid user ID")
+		return
+	} else if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Notification added for user %s", userID)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/eventlog"
+	"github.com/Zachisastudent/ETI_Assignment-1/metrics"
+	"github.com/Zachisastudent/ETI_Assignment-1/model"
+)
+
+// Events recorded in the User service's event log
+const (
+	UserRegistered      = "UserRegistered"
+	UserUpdated         = "UserUpdated"
+	UserBecameCarOwner  = "UserBecameCarOwner"
+	UserBecamePassenger = "UserBecamePassenger"
+	UserDeleted         = "UserDeleted"
+	NotificationAdded   = "NotificationAdded"
+)
+
+// errUserNotFound is returned when a change is made to a user that is not registered
+var errUserNotFound = errors.New("user not found")
+
+// userStore is the view of the users and the notifications left for them projected from the event log.
+// Every change is appended to the log first and then applied to the view.
+type userStore struct {
+	mu            sync.RWMutex
+	events        *eventlog.Log
+	users         map[string]model.User
+	notifications map[string][]model.Notification
+}
+
+// newUserStore rebuilds the current view from every event in the log
+func newUserStore(events *eventlog.Log) (*userStore, error) {
+	s, err := projectUsers(events.Events())
+	if err != nil {
+		return nil, err
+	}
+	s.events = events
+	return s, nil
+}
+
+// projectUsers replays the events into a new view, the view cannot record changes
+func projectUsers(events []eventlog.Event) (*userStore, error) {
+	s := &userStore{
+		users:         map[string]model.User{},
+		notifications: map[string][]model.Notification{},
+	}
+	for _, event := range events {
+		if err := s.apply(event); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// apply updates the view with a single event
+func (s *userStore) apply(event eventlog.Event) error {
+	userID := event.EntityID
+	user := s.users[userID]
+
+	switch event.Type {
+	case UserRegistered, UserUpdated:
+		var updatedUser model.User
+		if err := event.Decode(&updatedUser); err != nil {
+			return err
+		}
+		s.users[userID] = updatedUser
+	case UserBecameCarOwner:
+		var ownerDetails struct {
+			DriverLicense  string `json:"driver_license"`
+			CarPlateNumber string `json:"car_plate_number"`
+		}
+		if err := event.Decode(&ownerDetails); err != nil {
+			return err
+		}
+		user.DriverLicense = ownerDetails.DriverLicense
+		user.CarPlateNumber = ownerDetails.CarPlateNumber
+		user.IsCarOwner = true
+		s.users[userID] = user
+	case UserBecamePassenger:
+		user.IsCarOwner = false
+		user.DriverLicense = ""
+		user.CarPlateNumber = ""
+		s.users[userID] = user
+	case UserDeleted:
+		delete(s.users, userID)
+		delete(s.notifications, userID)
+	case NotificationAdded:
+		var notification model.Notification
+		if err := event.Decode(&notification); err != nil {
+			return err
+		}
+		s.notifications[userID] = append(s.notifications[userID], notification)
+	default:
+		return fmt.Errorf("unknown event type %q in event %d", event.Type, event.Seq)
+	}
+	return nil
+}
+
+// record appends an event to the log and applies it to the view
+func (s *userStore) record(eventType, userID string, data interface{}) error {
+	return s.recordAll([]eventlog.Change{{Type: eventType, EntityID: userID, Data: data}})
+}
+
+// recordAll appends the events to the log together and applies them to the view
+func (s *userStore) recordAll(changes []eventlog.Change) error {
+	if s.events == nil {
+		return fmt.Errorf("the view was replayed from the event log and is read only")
+	}
+
+	events, err := s.events.AppendAll(changes)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := s.apply(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the user with the given ID
+func (s *userStore) Get(userID string) (model.User, bool) {
+	defer metrics.StoreTimer("get")()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	return user, ok
+}
+
+// All returns a copy of every user keyed by ID
+func (s *userStore) All() map[string]model.User {
+	defer metrics.StoreTimer("all")()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[string]model.User, len(s.users))
+	for userID, user := range s.users {
+		all[userID] = user
+	}
+	return all
+}
+
+// Put registers a new user or updates an existing one
+func (s *userStore) Put(userID string, user model.User) error {
+	defer metrics.StoreTimer("put")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; ok {
+		return s.record(UserUpdated, userID, user)
+	}
+	return s.record(UserRegistered, userID, user)
+}
+
+// PutAll registers or updates the users as a single change. check is called with the users as
+// they are under the store's lock, and nothing is stored if it returns an error.
+func (s *userStore) PutAll(users []model.User, check func(stored func(userID string) (model.User, bool)) error) error {
+	defer metrics.StoreTimer("put_all")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := check(func(userID string) (model.User, bool) {
+		user, ok := s.users[userID]
+		return user, ok
+	}); err != nil {
+		return err
+	}
+
+	changes := make([]eventlog.Change, len(users))
+	for i, user := range users {
+		changes[i] = eventlog.Change{Type: UserRegistered, EntityID: user.ID, Data: user}
+		if _, ok := s.users[user.ID]; ok {
+			changes[i].Type = UserUpdated
+		}
+	}
+	return s.recordAll(changes)
+}
+
+// BecomeCarOwner adds the car owner details to a user's profile
+func (s *userStore) BecomeCarOwner(userID, driverLicense, carPlateNumber string) error {
+	defer metrics.StoreTimer("become_car_owner")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return errUserNotFound
+	}
+	return s.record(UserBecameCarOwner, userID, map[string]string{
+		"driver_license":   driverLicense,
+		"car_plate_number": carPlateNumber,
+	})
+}
+
+// BecomePassenger removes the car owner details from a user's profile
+func (s *userStore) BecomePassenger(userID string) error {
+	defer metrics.StoreTimer("become_passenger")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return errUserNotFound
+	}
+	return s.record(UserBecamePassenger, userID, nil)
+}
+
+// Delete removes a user and the notifications left for them
+func (s *userStore) Delete(userID string) error {
+	defer metrics.StoreTimer("delete")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return errUserNotFound
+	}
+	return s.record(UserDeleted, userID, nil)
+}
+
+// Notifications returns the notifications left for a user, oldest first
+func (s *userStore) Notifications(userID string) []model.Notification {
+	defer metrics.StoreTimer("notifications")()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]model.Notification{}, s.notifications[userID]...)
+}
+
+// AddNotification leaves a notification for a user
+func (s *userStore) AddNotification(userID string, notification model.Notification) error {
+	defer metrics.StoreTimer("add_notification")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return errUserNotFound
+	}
+	return s.record(NotificationAdded, userID, notification)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Zachisastudent/ETI_Assignment-1/resilient"
+)
+
+// TripClient calls the Trip service's owner endpoints on behalf of the User service
+type TripClient struct {
+	BaseURL    string
+	HTTPClient *resilient.Client
+}
+
+func newTripClient(baseURL string, transport http.RoundTripper) *TripClient {
+	// The calls are made on behalf of this service, which is recorded as the actor in the audit log
+	config := resilient.DefaultConfig()
+	config.Header = http.Header{"X-Client-ID": {"user-service"}}
+	config.Transport = transport
+
+	return &TripClient{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: resilient.NewClient("trip-service", config),
+	}
+}
+
+// Healthy checks that the Trip service is up
+func (c *TripClient) Healthy(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/healthz", nil)
+	if err != nil {
+		return err
+	}
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(response)
+	}
+	return nil
+}
+
+// ScheduledTripsOwnedBy returns the IDs of the owner's trips that have not started or departed yet
+func (c *TripClient) ScheduledTripsOwnedBy(ownerID string) ([]string, error) {
+	response, err := c.HTTPClient.Get(c.BaseURL + "/api/v1/owners/" + url.PathEscape(ownerID) + "/scheduled-trips")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, responseError(response)
+	}
+
+	var tripIDs []string
+	if err := json.NewDecoder(response.Body).Decode(&tripIDs); err != nil {
+		return nil, err
+	}
+	return tripIDs, nil
+}
+
+// CancelTripsOwnedBy cancels the owner's scheduled trips and recurring series, the passengers
+// are notified with the reason given. It returns the IDs of the cancelled trips.
+func (c *TripClient) CancelTripsOwnedBy(ownerID, reason string) ([]string, error) {
+	jsonBody, err := json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.HTTPClient.Post(c.BaseURL+"/api/v1/owners/"+url.PathEscape(ownerID)+"/cancel-trips", "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, responseError(response)
+	}
+
+	var tripIDs []string
+	if err := json.NewDecoder(response.Body).Decode(&tripIDs); err != nil {
+		return nil, err
+	}
+	return tripIDs, nil
+}
+
+// responseError turns an unexpected response from the Trip service into an error
+func responseError(response *http.Response) error {
+	body, _ := ioutil.ReadAll(response.Body)
+	return fmt.Errorf("trip service returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+}